@@ -7,6 +7,7 @@ import (
 	"runtime/pprof"
 
 	"mcgaunn.com/logwild/pkg/cmd"
+	"mcgaunn.com/logwild/pkg/cmd/exitcode"
 )
 
 func main() {
@@ -25,7 +26,7 @@ func main() {
 		defer pprof.StopCPUProfile()
 	}
 	if err := cmd.NewRootCmd().Execute(); err != nil {
-		os.Exit(1)
+		os.Exit(exitcode.Code(err))
 	}
 	// after running, if requested, generate a memory profile
 	if memProfile != "" {
@@ -0,0 +1,127 @@
+package http
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"mcgaunn.com/logwild/pkg/coordinator"
+	"mcgaunn.com/logwild/pkg/report"
+	"mcgaunn.com/logwild/pkg/scenario"
+)
+
+func newWorker(t *testing.T) *httptest.Server {
+	t.Helper()
+	tmpfile, err := os.CreateTemp("", "worker.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpfile.Close()
+	t.Cleanup(func() { os.Remove(tmpfile.Name()) })
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = tmpfile.Name()
+	srv.registerHandlers()
+	ts := httptest.NewServer(srv.router)
+	t.Cleanup(ts.Close)
+	t.Cleanup(srv.StopScenarios)
+	return ts
+}
+
+func TestCoordinatorSplitsScenarioAcrossWorkers(t *testing.T) {
+	sc, err := scenario.Parse([]byte(`
+name: spread
+streams:
+  - name: web
+    rate: 301
+phases:
+  - name: steady
+    duration: 1500ms
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var urls []string
+	for i := 0; i < 3; i++ {
+		urls = append(urls, newWorker(t).URL)
+	}
+	co, err := coordinator.New(sc, urls,
+		coordinator.WithStartDelay(300*time.Millisecond),
+		coordinator.WithPollInterval(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := co.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Workers) != 3 {
+		t.Fatalf("expected a result from every worker, got %+v", res.Workers)
+	}
+	var sum int64
+	for u, wr := range res.Workers {
+		n := wr.Streams["web"].LogCount
+		if n < 100 || n > 200 {
+			t.Errorf("expected worker %s to write about a third of the events, wrote %d", u, n)
+		}
+		sum += n
+	}
+	if total := res.Streams["web"].LogCount; total != sum {
+		t.Errorf("expected the total to sum the workers, got %d and %d", total, sum)
+	}
+
+	rep := co.Report(report.SLO{})
+	if rep.LogCount != sum || len(rep.Errors) != 3 {
+		t.Errorf("expected a report over all three workers' sinks, got %d events from %v", rep.LogCount, rep.Errors)
+	}
+	if first := rep.Series[0]; first.Target != 301 {
+		t.Errorf("expected the first second to target the whole rate, got %+v", first)
+	}
+	if rep.StartedAt.Before(res.StartAt) || rep.StartedAt.Sub(res.StartAt) > 200*time.Millisecond {
+		t.Errorf("expected the workers to start at %s, started at %s", res.StartAt, rep.StartedAt)
+	}
+}
+
+func TestCoordinatorStopsWorkersTogether(t *testing.T) {
+	sc, err := scenario.Parse([]byte(`
+name: long
+streams:
+  - name: web
+    rate: 100
+phases:
+  - name: steady
+    duration: 1h
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	urls := []string{newWorker(t).URL, newWorker(t).URL}
+	co, err := coordinator.New(sc, urls, coordinator.WithStartDelay(100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 700*time.Millisecond)
+	defer cancel()
+	res, err := co.Run(ctx)
+	if err == nil {
+		t.Fatal("expected the run to end with its context")
+	}
+	for u, wr := range res.Workers {
+		if wr.Streams["web"].LogCount == 0 {
+			t.Errorf("expected worker %s to have written before it was stopped", u)
+		}
+	}
+}
+
+func TestCoordinatorReportsUnreachableWorkers(t *testing.T) {
+	sc := scenario.Preset(scenario.PresetNames()[0])
+	ts := newWorker(t)
+	co, err := coordinator.New(sc, []string{ts.URL, "http://127.0.0.1:1"}, coordinator.WithStartDelay(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := co.Run(context.Background()); err == nil {
+		t.Fatal("expected an error for the unreachable worker")
+	}
+}
@@ -0,0 +1,128 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// eventInterval is how often progress events are sent.
+const eventInterval = time.Second
+
+// JobProgress is a job's progress, sent every second as a progress event.
+type JobProgress struct {
+	ID string `json:"id"`
+	// Phase is the job's state: scheduled while it waits for its next run,
+	// running otherwise.
+	Phase string `json:"phase"`
+	// Run is the run in progress, counting from 1.
+	Run   int       `json:"run"`
+	Time  time.Time `json:"time"`
+	Lines int64     `json:"lines"`
+	Bytes int64     `json:"bytes"`
+	// Rate is the lines per second achieved since the previous event.
+	Rate    float64 `json:"rate"`
+	Target  int64   `json:"target"`
+	Errors  int64   `json:"errors"`
+	Dropped int64   `json:"dropped"`
+}
+
+// progressTracker turns a job's status into progress events, working out
+// the rate from the previous one.
+type progressTracker struct {
+	j         *job
+	lastLines int64
+	lastAt    time.Time
+}
+
+func (pt *progressTracker) next() JobProgress {
+	st := pt.j.status()
+	now := time.Now()
+	p := JobProgress{
+		ID:      st.ID,
+		Phase:   st.State,
+		Run:     st.Runs,
+		Time:    now,
+		Lines:   st.Stats.LogCount,
+		Bytes:   st.Bytes,
+		Target:  st.PerSecond,
+		Errors:  st.Stats.WriteErrors,
+		Dropped: st.Stats.Dropped,
+	}
+	if !pt.lastAt.IsZero() {
+		p.Rate = float64(p.Lines-pt.lastLines) / now.Sub(pt.lastAt).Seconds()
+	}
+	pt.lastLines, pt.lastAt = p.Lines, now
+	return p
+}
+
+// writeEvent writes v as a server-sent event called name.
+func writeEvent(w io.Writer, name string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+	return err
+}
+
+// JobEvents godoc
+// @Summary Stream the progress of a log generation job
+// @Description sends a progress event every second with lines, bytes, achieved rate, errors and phase, then a summary event with the job's final status when it ends
+// @Tags HTTP API
+// @Produce text/event-stream
+// @Param id path string true "job id"
+// @Success 200 {object} api.JobProgress
+// @Router /api/jobs/{id}/events [get]
+func (s *Server) jobEventsHandler(w http.ResponseWriter, r *http.Request) {
+	_, span := s.tracer.Start(r.Context(), "jobEventsHandler")
+	defer span.End()
+	j := s.jobs.get(jobID(r))
+	if j == nil {
+		s.ErrorResponse(w, r, span, "job not found", http.StatusNotFound)
+		return
+	}
+	rc := http.NewResponseController(w)
+	// the stream lasts as long as the job, beyond the server's write timeout;
+	// not every writer supports this, in which case the timeout stands
+	_ = rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	pt := &progressTracker{j: j}
+	send := func(name string, v any) bool {
+		if err := writeEvent(w, name, v); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+	tickr := time.NewTicker(eventInterval)
+	defer tickr.Stop()
+	for ok := send("progress", pt.next()); ok; {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-j.done:
+			send("summary", j.status())
+			return
+		case <-tickr.C:
+			ok = send("progress", pt.next())
+		}
+	}
+}
+
+// countingSink counts the bytes written to a job's sink.
+type countingSink struct {
+	w io.Writer
+	n *atomic.Int64
+}
+
+func (cs *countingSink) Write(p []byte) (int, error) {
+	n, err := cs.w.Write(p)
+	cs.n.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,74 @@
+package http
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestJobEventsStreamProgressAndSummary(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = tmpfile.Name()
+	srv.registerHandlers()
+	ts := httptest.NewServer(srv.router)
+	defer ts.Close()
+	rr := serveJobRequest(t, srv.jobCreateHandler, "POST", "/api/jobs?per_second=100&burst_dur=2", nil)
+	var created JobResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Get(ts.URL + "/api/jobs/" + created.ID + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %s", ct)
+	}
+	var progress []JobProgress
+	var summary JobResponse
+	var event string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data := []byte(strings.TrimPrefix(line, "data: "))
+			switch event {
+			case "progress":
+				var p JobProgress
+				if err := json.Unmarshal(data, &p); err != nil {
+					t.Fatal(err)
+				}
+				progress = append(progress, p)
+			case "summary":
+				if err := json.Unmarshal(data, &summary); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+	if len(progress) < 2 {
+		t.Fatalf("expected progress every second, got %+v", progress)
+	}
+	if last := progress[len(progress)-1]; last.Phase != JobRunning || last.Lines == 0 || last.Bytes == 0 || last.Rate < 50 || last.Target != 100 {
+		t.Errorf("expected progress of the running job, got %+v", last)
+	}
+	if summary.State != JobFinished || summary.Stats.LogCount < progress[len(progress)-1].Lines || summary.Bytes == 0 {
+		t.Errorf("expected a summary of the finished job, got %+v", summary)
+	}
+}
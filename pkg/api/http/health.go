@@ -14,7 +14,7 @@ import (
 // @Router /healthz [get]
 // @Success 200 {string} string "OK"
 func (s *Server) healthzHandler(w http.ResponseWriter, r *http.Request) {
-	if atomic.LoadInt32(&healthy) == 1 {
+	if atomic.LoadInt32(&s.healthy) == 1 {
 		s.JSONResponse(w, r, map[string]string{"status": "OK"})
 		return
 	}
@@ -30,7 +30,7 @@ func (s *Server) healthzHandler(w http.ResponseWriter, r *http.Request) {
 // @Router /readyz [get]
 // @Success 200 {string} string "OK"
 func (s *Server) readyzHandler(w http.ResponseWriter, r *http.Request) {
-	if atomic.LoadInt32(&ready) == 1 {
+	if atomic.LoadInt32(&s.ready) == 1 {
 		s.JSONResponse(w, r, map[string]string{"status": "OK"})
 		return
 	}
@@ -46,7 +46,7 @@ func (s *Server) readyzHandler(w http.ResponseWriter, r *http.Request) {
 // @Router /readyz/enable [post]
 // @Success 202 {string} string "OK"
 func (s *Server) enableReadyHandler(w http.ResponseWriter, r *http.Request) {
-	atomic.StoreInt32(&ready, 1)
+	atomic.StoreInt32(&s.ready, 1)
 	w.WriteHeader(http.StatusAccepted)
 }
 
@@ -59,6 +59,6 @@ func (s *Server) enableReadyHandler(w http.ResponseWriter, r *http.Request) {
 // @Router /readyz/disable [post]
 // @Success 202 {string} string "OK"
 func (s *Server) disableReadyHandler(w http.ResponseWriter, r *http.Request) {
-	atomic.StoreInt32(&ready, 0)
+	atomic.StoreInt32(&s.ready, 0)
 	w.WriteHeader(http.StatusAccepted)
 }
@@ -3,6 +3,7 @@ package http
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"math/rand"
 	"net/http"
 	"time"
@@ -62,16 +63,33 @@ func (s *Server) JSONResponseCode(w http.ResponseWriter, r *http.Request, result
 	w.Write(prettyJSON(body))
 }
 
-func (s *Server) ErrorResponse(w http.ResponseWriter, r *http.Request, span trace.Span, error string, code int) {
-	data := struct {
-		Code    int    `json:"code"`
-		Message string `json:"message"`
-	}{
-		Code:    code,
-		Message: error,
+// ErrorResponse sends message with code as both the status and the code in
+// the body.
+func (s *Server) ErrorResponse(w http.ResponseWriter, r *http.Request, span trace.Span, message string, code int) {
+	s.errorResponse(w, span, ErrorResponseBody{Code: code, Message: message})
+}
+
+// ParamErrorResponse sends a 400 for bad request params, listing each bad
+// param when err is a *ValidationError.
+func (s *Server) ParamErrorResponse(w http.ResponseWriter, r *http.Request, span trace.Span, err error) {
+	data := ErrorResponseBody{Code: http.StatusBadRequest, Message: err.Error()}
+	var ve *ValidationError
+	if errors.As(err, &ve) {
+		data.Fields = ve.Fields
 	}
+	s.errorResponse(w, span, data)
+}
 
-	span.SetStatus(codes.Error, error)
+// ErrorResponseBody is the body of every error response.
+type ErrorResponseBody struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	// Fields says what is wrong with each bad param.
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+func (s *Server) errorResponse(w http.ResponseWriter, span trace.Span, data ErrorResponseBody) {
+	span.SetStatus(codes.Error, data.Message)
 
 	body, err := json.Marshal(data)
 	if err != nil {
@@ -82,7 +100,7 @@ func (s *Server) ErrorResponse(w http.ResponseWriter, r *http.Request, span trac
 
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.Header().Set("X-Content-Type-Options", "nosniff")
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(data.Code)
 	w.Write(prettyJSON(body))
 }
 
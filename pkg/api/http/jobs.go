@@ -0,0 +1,562 @@
+package http
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"mcgaunn.com/logwild/pkg/logmaker"
+	"mcgaunn.com/logwild/pkg/metrics"
+)
+
+// continuousJobID is the id of the job started by log-continuous.
+const continuousJobID = "continuous"
+
+// states a job can be in
+const (
+	JobScheduled = "scheduled"
+	JobRunning   = "running"
+	JobFinished  = "finished"
+	JobStopped   = "stopped"
+	JobFailed    = "failed"
+)
+
+// errEndlessRecurring is returned for a recurring job whose runs would never
+// end.
+var errEndlessRecurring = errors.New("a recurring schedule needs a burst_dur above 0")
+
+// errJobFinished is returned for changes to a job that won't run again.
+var errJobFinished = errors.New("job has finished")
+
+// job is one LogMaker run in the background, whose settings can be changed
+// while it runs.
+type job struct {
+	id       string
+	mu       sync.Mutex
+	lm       *logmaker.LogMaker
+	sink     io.WriteCloser
+	sinkPath string
+	// out is sink, counting the bytes written to it
+	out   io.Writer
+	bytes atomic.Int64
+	// tap passes what the job writes on to tails
+	tap        *logmaker.Tap
+	metrics    *metrics.Stream
+	manifest   *os.File
+	format     string
+	schedule   startSchedule
+	startedAt  time.Time
+	finishedAt time.Time
+	// nextStart is set while the job waits for its next run
+	nextStart      time.Time
+	scheduledStart time.Time
+	actualStart    time.Time
+	runs           int
+	stopped        bool
+	err            error
+	cancel         context.CancelFunc
+	done           chan struct{}
+}
+
+// maxFinishedJobs is how many finished jobs are kept for their stats and
+// reports before the oldest are forgotten.
+const maxFinishedJobs = 100
+
+// jobRegistry keeps every job the server started until it is deleted. Jobs
+// that have finished are kept too, but only the latest keepFinished of them,
+// so a server that is sent jobs for days doesn't grow without bound.
+type jobRegistry struct {
+	mu     sync.Mutex
+	jobs   map[string]*job
+	lastID int
+	// keepFinished defaults to maxFinishedJobs
+	keepFinished int
+}
+
+// JobResponse describes a job.
+type JobResponse struct {
+	ID          string            `json:"id"`
+	State       string            `json:"state"`
+	StartedAt   time.Time         `json:"started_at"`
+	FinishedAt  *time.Time        `json:"finished_at,omitempty"`
+	PerSecond   int64             `json:"per_second"`
+	MessageSize int64             `json:"message_size"`
+	LevelMix    logmaker.LevelMix `json:"level_mix"`
+	Format      string            `json:"format"`
+	// Schedule is the start_at or cron schedule the job was created with.
+	Schedule string `json:"schedule,omitempty"`
+	// NextStart is when a scheduled job starts its next run.
+	NextStart *time.Time `json:"next_start,omitempty"`
+	// Runs counts the runs started so far; a recurring job's stats are
+	// summed over all of them.
+	Runs int `json:"runs"`
+	// start times of the latest run
+	startTimes
+	Stats logmaker.Stats `json:"stats"`
+	// Bytes is how much the job has written.
+	Bytes int64  `json:"bytes"`
+	Error string `json:"error,omitempty"`
+}
+
+func (jr *jobRegistry) add(j *job) {
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+	if jr.jobs == nil {
+		jr.jobs = map[string]*job{}
+	}
+	jr.jobs[j.id] = j
+	jr.evict()
+}
+
+// evict forgets the oldest finished jobs beyond keepFinished. jr.mu must be
+// held.
+func (jr *jobRegistry) evict() {
+	var finished []*job
+	for _, j := range jr.jobs {
+		select {
+		case <-j.done:
+			finished = append(finished, j)
+		default:
+		}
+	}
+	keep := cmp.Or(jr.keepFinished, maxFinishedJobs)
+	if len(finished) <= keep {
+		return
+	}
+	// finishedAt is no longer written once done is closed
+	sort.Slice(finished, func(a, b int) bool { return finished[a].finishedAt.Before(finished[b].finishedAt) })
+	for _, j := range finished[:len(finished)-keep] {
+		delete(jr.jobs, j.id)
+	}
+}
+
+// newID hands out ids for jobs that weren't given one.
+func (jr *jobRegistry) newID() string {
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+	jr.lastID++
+	return fmt.Sprintf("job-%d", jr.lastID)
+}
+
+func (jr *jobRegistry) get(id string) *job {
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+	return jr.jobs[id]
+}
+
+func (jr *jobRegistry) remove(id string) {
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+	delete(jr.jobs, id)
+}
+
+// list returns the jobs in the order they were started.
+func (jr *jobRegistry) list() []*job {
+	jr.mu.Lock()
+	jobs := make([]*job, 0, len(jr.jobs))
+	for _, j := range jr.jobs {
+		jobs = append(jobs, j)
+	}
+	jr.mu.Unlock()
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].startedAt.Before(jobs[b].startedAt) })
+	return jobs
+}
+
+// startJob opens the configured output file, writes to it in format with a
+// LogMaker built from optFuncs, and runs it in the background until its burst
+// duration is over or it is stopped. A job with a schedule waits for its
+// start, and a recurring one runs again at every start after that. An empty
+// id gets a generated one.
+func (s *Server) startJob(id string, format string, sched startSchedule, optFuncs []logmaker.OptFunc, sensitive logmaker.SensitiveOpts) (*job, error) {
+	sink, err := logmaker.OpenSink(s.config.LogwildOutFile)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		id = s.jobs.newID()
+	}
+	stream := s.genMetrics.Stream(id, s.config.LogwildOutFile, format)
+	j := &job{id: id, sink: sink, sinkPath: s.config.LogwildOutFile, metrics: stream, format: format, schedule: sched, done: make(chan struct{})}
+	j.out = &countingSink{w: sink, n: &j.bytes}
+	j.tap = logmaker.NewTap()
+	h, err := j.handler(format)
+	if err != nil {
+		sink.Close()
+		return nil, err
+	}
+	if sensitive.Fraction > 0 {
+		if j.manifest, err = s.openSensitiveManifest(); err != nil {
+			sink.Close()
+			return nil, err
+		}
+		if j.manifest != nil {
+			sensitive.Manifest = j.manifest
+		}
+	}
+	optFuncs = append(optFuncs, logmaker.WithSensitive(sensitive), logmaker.WithLogger(slog.New(h)), logmaker.WithObserver(stream.Observer()))
+	j.lm = logmaker.NewLogMaker(optFuncs...)
+	if sched.recurring() && j.lm.BurstDuration <= 0 {
+		j.close()
+		return nil, errEndlessRecurring
+	}
+	stream.SetTarget(j.lm.PerSecondRate)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j.cancel = cancel
+	j.startedAt = time.Now()
+	if !sched.immediate() {
+		j.nextStart = sched.next(j.startedAt)
+	}
+	s.jobs.add(j)
+	s.genMetrics.JobStarted(metrics.KindJob)
+	go func() {
+		err := j.run(ctx)
+		s.genMetrics.JobFinished(metrics.KindJob)
+		j.mu.Lock()
+		j.err = err
+		j.finishedAt = time.Now()
+		j.nextStart = time.Time{}
+		j.mu.Unlock()
+		j.close()
+		if err != nil {
+			s.logger.Error("log generation job failed", "job", j.id, "err", err)
+		}
+		close(j.done)
+	}()
+	s.logger.Info("started log generation job", "job", j.id, "perSecondRate", j.lm.Options().PerSecondRate, "format", format)
+	return j, nil
+}
+
+// run runs the job's LogMaker at each start of its schedule: once, unless
+// the schedule is recurring. Being stopped while waiting to start is not an
+// error.
+func (j *job) run(ctx context.Context) error {
+	for {
+		next := j.schedule.next(time.Now())
+		if !j.schedule.immediate() {
+			j.mu.Lock()
+			j.nextStart = next
+			j.mu.Unlock()
+			if err := sleepUntil(ctx, next); err != nil {
+				return nil
+			}
+		}
+		j.mu.Lock()
+		if !j.schedule.immediate() {
+			j.scheduledStart = next
+		}
+		j.actualStart = time.Now()
+		j.nextStart = time.Time{}
+		j.runs++
+		j.mu.Unlock()
+		err := j.lm.Run(ctx)
+		if err != nil || ctx.Err() != nil || !j.schedule.recurring() {
+			return err
+		}
+	}
+}
+
+// handler writes to the job's sink in format, counting what is written and
+// passing it on to tails.
+func (j *job) handler(format string) (slog.Handler, error) {
+	h, err := logmaker.NewHandler(j.metrics.Writer(j.out), format)
+	if err != nil {
+		return nil, err
+	}
+	return j.tap.Handler(h, format)
+}
+
+// close releases what the job writes to.
+func (j *job) close() {
+	j.mu.Lock()
+	j.metrics.Close()
+	j.mu.Unlock()
+	j.sink.Close()
+	if j.manifest != nil {
+		j.manifest.Close()
+	}
+}
+
+// stop cancels the job and waits for events in flight to be written.
+func (j *job) stop() {
+	j.mu.Lock()
+	select {
+	case <-j.done:
+	default:
+		j.stopped = true
+	}
+	j.mu.Unlock()
+	j.cancel()
+	<-j.done
+}
+
+// update changes the settings of a job that hasn't finished. An unknown
+// format is an error, and leaves the job as it was. gm labels the metrics of
+// a job whose format changed.
+func (j *job) update(gm *metrics.Generator, optFuncs []logmaker.OptFunc, format string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	// finishedAt is set before the job closes its metrics, so a new stream
+	// can't be opened after that
+	if !j.finishedAt.IsZero() {
+		return errJobFinished
+	}
+	if format != "" && format != j.format {
+		if !slices.Contains(logmaker.Formats, format) {
+			return fmt.Errorf("unknown log format %q", format)
+		}
+		// metrics are labelled by format, so the job's continue under new
+		// labels
+		j.metrics.Close()
+		j.metrics = gm.Stream(j.id, j.sinkPath, format)
+		// the new handler shares the sink, so the output carries on in the
+		// same file in the new format
+		h, _ := j.handler(format)
+		optFuncs = append(optFuncs, logmaker.WithLogger(slog.New(h)), logmaker.WithObserver(j.metrics.Observer()))
+		j.format = format
+	}
+	j.lm.Update(optFuncs...)
+	j.metrics.SetTarget(j.lm.Options().PerSecondRate)
+	return nil
+}
+
+func (j *job) status() JobResponse {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	o := j.lm.Options()
+	res := JobResponse{
+		ID:          j.id,
+		State:       JobRunning,
+		StartedAt:   j.startedAt,
+		PerSecond:   o.PerSecondRate,
+		MessageSize: o.PerMessageSize,
+		LevelMix:    o.LevelMix,
+		Format:      j.format,
+		Schedule:    j.schedule.String(),
+		Runs:        j.runs,
+		startTimes:  newStartTimes(j.scheduledStart, j.actualStart),
+		Stats:       j.lm.Stats(),
+		Bytes:       j.bytes.Load(),
+	}
+	if !j.nextStart.IsZero() {
+		nextStart := j.nextStart
+		res.NextStart = &nextStart
+		res.State = JobScheduled
+	}
+	if !j.finishedAt.IsZero() {
+		finishedAt := j.finishedAt
+		res.FinishedAt = &finishedAt
+		switch {
+		case j.err != nil:
+			res.State = JobFailed
+			res.Error = j.err.Error()
+		case j.stopped:
+			res.State = JobStopped
+		default:
+			res.State = JobFinished
+		}
+	}
+	return res
+}
+
+// startContinuous starts the continuous job, which generates logs with the
+// configured settings until the server shuts down.
+func (s *Server) startContinuous() error {
+	optFuncs := s.buildLoggerOptionsFromConfig()
+	optFuncs = append(optFuncs,
+		logmaker.WithPerMessageSize(s.config.LogwildPerMessageSize),
+		logmaker.WithBurstDuration(0))
+	_, err := s.startJob(continuousJobID, s.config.LogwildFormat, startSchedule{}, optFuncs, s.sensitiveOptsFromConfig())
+	return err
+}
+
+// StopJobs stops every running job, waiting for events in flight to be
+// written.
+func (s *Server) StopJobs() {
+	for _, j := range s.jobs.list() {
+		j.stop()
+		s.logger.Info("stopped log generation job", "job", j.id, "logCount", j.lm.Stats().LogCount)
+	}
+}
+
+// updateOptsFromQueryParams reads the settings that can be changed on a
+// running job: per_second, where 0 pauses the job, message_size, level_mix
+// and format.
+func (s *Server) updateOptsFromQueryParams(p *params) ([]logmaker.OptFunc, string, error) {
+	var optFuncs []logmaker.OptFunc
+	if perSecond, ok := p.int("per_second", 0, maxPerSecond); ok {
+		optFuncs = append(optFuncs, logmaker.WithPerSecondRate(perSecond))
+	}
+	if size, ok := p.int("message_size", 1, maxMessageSize); ok {
+		optFuncs = append(optFuncs, logmaker.WithPerMessageSize(size))
+	}
+	p.parse("level_mix", func(v string) error {
+		mix, err := logmaker.ParseLevelMix(v)
+		if err == nil {
+			optFuncs = append(optFuncs, logmaker.WithLevelMix(mix))
+		}
+		return err
+	})
+	format, _ := p.oneOf("format", logmaker.Formats)
+	return optFuncs, format, p.err()
+}
+
+// JobCreate godoc
+// @Summary Start a log generation job
+// @Description starts generating logs in the background with the same parameters as /loggen, where burst_dur=0 runs until the job is deleted. with start_at the job waits to start, and with schedule it runs a burst at every time the schedule matches. with a JSON content type the settings come from a GenerationSpec body instead of query params
+// @Tags HTTP API
+// @Accept json
+// @Produce json
+// @Param start_at query string false "RFC 3339 time to start at"
+// @Param schedule query string false "cron expression, e.g. */15 * * * * or @every 15m, to run a burst at"
+// @Param spec body api.GenerationSpec false "generation settings"
+// @Success 201 {object} api.JobResponse
+// @Failure 400 {object} api.ErrorResponseBody
+// @Router /api/jobs [post]
+func (s *Server) jobCreateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, span := s.tracer.Start(r.Context(), "jobCreateHandler")
+	defer span.End()
+	p := newParams(r)
+	gen, burstField := generation{}, "burst_dur"
+	if hasSpec(r) {
+		gen, burstField = s.specGeneration(r, p), "burst_duration"
+	} else {
+		gen = s.queryGeneration(r, p)
+	}
+	optFuncs := s.buildLoggerOptionsFromConfig()
+	optFuncs = append(optFuncs, logmaker.WithPerMessageSize(s.config.LogwildPerMessageSize))
+	optFuncs = append(optFuncs, gen.optFuncs...)
+	// the job outlives the request, so only its trace is inherited
+	optFuncs = append(optFuncs, logmaker.WithParentContext(context.WithoutCancel(ctx)))
+	if err := p.err(); err != nil {
+		s.ParamErrorResponse(w, r, span, err)
+		return
+	}
+	j, err := s.startJob("", gen.format, gen.sched, optFuncs, gen.sensitive)
+	if errors.Is(err, errEndlessRecurring) {
+		p.fail(burstField, "%s", err)
+		s.ParamErrorResponse(w, r, span, p.err())
+		return
+	}
+	if err != nil {
+		s.logger.Error("failed to start log generation job", "err", err)
+		s.ErrorResponse(w, r, span, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.JSONResponseCode(w, r, j.status(), http.StatusCreated)
+}
+
+// JobList godoc
+// @Summary List log generation jobs
+// @Tags HTTP API
+// @Produce json
+// @Success 200 {array} api.JobResponse
+// @Router /api/jobs [get]
+func (s *Server) jobListHandler(w http.ResponseWriter, r *http.Request) {
+	jobs := s.jobs.list()
+	res := make([]JobResponse, 0, len(jobs))
+	for _, j := range jobs {
+		res = append(res, j.status())
+	}
+	s.JSONResponse(w, r, res)
+}
+
+// JobGet godoc
+// @Summary Log generation job status
+// @Description reports the settings, state and stats of a job
+// @Tags HTTP API
+// @Produce json
+// @Param id path string true "job id"
+// @Success 200 {object} api.JobResponse
+// @Router /api/jobs/{id} [get]
+func (s *Server) jobGetHandler(w http.ResponseWriter, r *http.Request) {
+	_, span := s.tracer.Start(r.Context(), "jobGetHandler")
+	defer span.End()
+	j := s.jobs.get(jobID(r))
+	if j == nil {
+		s.ErrorResponse(w, r, span, "job not found", http.StatusNotFound)
+		return
+	}
+	s.JSONResponse(w, r, j.status())
+}
+
+// JobUpdate godoc
+// @Summary Adjust a running log generation job
+// @Description changes the rate, message size, level mix or format of a job while it runs
+// @Tags HTTP API
+// @Produce json
+// @Param id path string true "job id"
+// @Param per_second query int false "events per second"
+// @Param message_size query int false "words per message"
+// @Param level_mix query string false "weighted level distribution"
+// @Param format query string false "output format"
+// @Success 200 {object} api.JobResponse
+// @Failure 409 {object} api.ErrorResponseBody
+// @Router /api/jobs/{id} [patch]
+func (s *Server) jobUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	_, span := s.tracer.Start(r.Context(), "jobUpdateHandler")
+	defer span.End()
+	j := s.jobs.get(jobID(r))
+	if j == nil {
+		s.ErrorResponse(w, r, span, "job not found", http.StatusNotFound)
+		return
+	}
+	optFuncs, format, err := s.updateOptsFromQueryParams(newParams(r))
+	if err != nil {
+		s.ParamErrorResponse(w, r, span, err)
+		return
+	}
+	err = j.update(s.genMetrics, optFuncs, format)
+	if errors.Is(err, errJobFinished) {
+		s.ErrorResponse(w, r, span, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		s.ErrorResponse(w, r, span, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.logger.Info("updated log generation job", "job", j.id, "options", len(optFuncs), "format", format)
+	s.JSONResponse(w, r, j.status())
+}
+
+// JobDelete godoc
+// @Summary Stop and remove a log generation job
+// @Description stops the job if it is running and reports its final stats
+// @Tags HTTP API
+// @Produce json
+// @Param id path string true "job id"
+// @Success 200 {object} api.JobResponse
+// @Router /api/jobs/{id} [delete]
+func (s *Server) jobDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	_, span := s.tracer.Start(r.Context(), "jobDeleteHandler")
+	defer span.End()
+	j := s.jobs.get(jobID(r))
+	if j == nil {
+		s.ErrorResponse(w, r, span, "job not found", http.StatusNotFound)
+		return
+	}
+	j.stop()
+	s.jobs.remove(j.id)
+	s.JSONResponse(w, r, j.status())
+}
+
+// jobID is the job a request is about. /api/continuous is the same as
+// /api/jobs/continuous.
+func jobID(r *http.Request) string {
+	if id, ok := mux.Vars(r)["id"]; ok {
+		return id
+	}
+	return continuousJobID
+}
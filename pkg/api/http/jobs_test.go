@@ -0,0 +1,306 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"runtime/metrics"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"mcgaunn.com/logwild/pkg/logmaker"
+	"mcgaunn.com/logwild/pkg/report"
+)
+
+func serveJobRequest(t *testing.T, handler http.HandlerFunc, method, target string, vars map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	req, err := http.NewRequest(method, target, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vars != nil {
+		req = mux.SetURLVars(req, vars)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestContinuousJobCanBeAdjusted(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = tmpfile.Name()
+	srv.config.LogwildPerSecondRate = 100
+	if err := srv.startContinuous(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	rr := serveJobRequest(t, srv.jobUpdateHandler, "PATCH", "/api/continuous?per_second=500&level_mix=error=1&format=plain", nil)
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned bad status code: got %v want %v", status, http.StatusOK)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	rr = serveJobRequest(t, srv.jobGetHandler, "GET", "/api/continuous", nil)
+	var status JobResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.State != JobRunning || status.PerSecond != 500 || status.Format != "plain" || status.Stats.Levels["error"] == 0 {
+		t.Errorf("expected adjusted settings to apply, got %+v", status)
+	}
+
+	srv.StopJobs()
+	content, err := os.ReadFile(tmpfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(content), "{") || !strings.Contains(string(content), " ERROR ") {
+		t.Errorf("expected json output followed by plain output, got:\n%s", content)
+	}
+}
+
+func TestJobFieldsKeepCountingWhenTheRateChanges(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = tmpfile.Name()
+	rr := serveJobRequest(t, srv.jobCreateHandler, "POST", "/api/jobs?per_second=100&burst_dur=0&fields=req:1000000:sequential", nil)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("handler returned bad status code: got %v want %v", rr.Code, http.StatusCreated)
+	}
+	var created JobResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	rr = serveJobRequest(t, srv.jobUpdateHandler, "PATCH", "/api/jobs/"+created.ID+"?per_second=200", map[string]string{"id": created.ID})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned bad status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	time.Sleep(200 * time.Millisecond)
+	srv.StopJobs()
+
+	content, err := os.ReadFile(tmpfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		var event struct {
+			Req string `json:"req"`
+		}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatal(err)
+		}
+		if seen[event.Req] {
+			t.Fatalf("expected the sequence to carry on after the rate changed, %s came round again", event.Req)
+		}
+		seen[event.Req] = true
+	}
+	if len(seen) < 20 {
+		t.Errorf("expected events from before and after the change, got %d", len(seen))
+	}
+}
+
+func TestFinishedJobsCannotBeChanged(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = tmpfile.Name()
+	j, err := srv.startJob("", "json", startSchedule{}, []logmaker.OptFunc{logmaker.WithPerSecondRate(10), logmaker.WithBurstDuration(10 * time.Millisecond)}, logmaker.SensitiveOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-j.done
+	rr := serveJobRequest(t, srv.jobUpdateHandler, "PATCH", "/api/jobs/"+j.id+"?per_second=50&format=plain", map[string]string{"id": j.id})
+	if rr.Code != http.StatusConflict {
+		t.Errorf("expected a finished job to refuse changes, got %v", rr.Code)
+	}
+	if status := j.status(); status.PerSecond != 10 || status.Format != "json" {
+		t.Errorf("expected the finished job to be left as it was, got %+v", status)
+	}
+}
+
+func TestJobsCanBeCreatedListedAndDeleted(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = tmpfile.Name()
+	rr := serveJobRequest(t, srv.jobCreateHandler, "POST", "/api/jobs?per_second=200&burst_dur=0", nil)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("handler returned bad status code: got %v want %v", rr.Code, http.StatusCreated)
+	}
+	var created JobResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.ID == "" || created.State != JobRunning || created.PerSecond != 200 {
+		t.Fatalf("unexpected job %+v", created)
+	}
+
+	rr = serveJobRequest(t, srv.jobListHandler, "GET", "/api/jobs", nil)
+	var jobs []JobResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &jobs); err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].ID != created.ID {
+		t.Errorf("expected the created job to be listed, got %+v", jobs)
+	}
+
+	rr = serveJobRequest(t, srv.jobUpdateHandler, "PATCH", "/api/jobs/"+created.ID+"?format=unknown", map[string]string{"id": created.ID})
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), `"field": "format"`) {
+		t.Errorf("expected an unknown format to be rejected, got %s", rr.Body.String())
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	rr = serveJobRequest(t, srv.jobDeleteHandler, "DELETE", "/api/jobs/"+created.ID, map[string]string{"id": created.ID})
+	var deleted JobResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &deleted); err != nil {
+		t.Fatal(err)
+	}
+	if deleted.State != JobStopped || deleted.Stats.LogCount == 0 || deleted.FinishedAt == nil {
+		t.Errorf("expected a stopped job with stats, got %+v", deleted)
+	}
+	rr = serveJobRequest(t, srv.jobGetHandler, "GET", "/api/jobs/"+created.ID, map[string]string{"id": created.ID})
+	if !strings.Contains(rr.Body.String(), "job not found") {
+		t.Errorf("expected the deleted job to be gone, got %s", rr.Body.String())
+	}
+}
+
+func TestOnlyTheLatestFinishedJobsAreKept(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = tmpfile.Name()
+	srv.jobs.keepFinished = 2
+	defer srv.StopJobs()
+	start := func(id string, burst time.Duration) *job {
+		j, err := srv.startJob(id, "json", startSchedule{}, []logmaker.OptFunc{logmaker.WithPerSecondRate(10), logmaker.WithBurstDuration(burst)}, logmaker.SensitiveOpts{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return j
+	}
+	start("running", 0)
+	for _, id := range []string{"first", "second", "third", "fourth"} {
+		<-start(id, 10*time.Millisecond).done
+	}
+	// the fourth job's finish is only noticed by the next job added
+	start("last", 0)
+
+	var ids []string
+	for _, j := range srv.jobs.list() {
+		ids = append(ids, j.id)
+	}
+	if strings.Join(ids, ",") != "running,third,fourth,last" {
+		t.Errorf("expected the running jobs and the two latest finished ones, got %v", ids)
+	}
+}
+
+func TestJobReportHandler(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = tmpfile.Name()
+	rr := serveJobRequest(t, srv.jobCreateHandler, "POST", "/api/jobs?per_second=100&burst_dur=1", nil)
+	var created JobResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	<-srv.jobs.get(created.ID).done
+
+	vars := map[string]string{"id": created.ID}
+	rr = serveJobRequest(t, srv.jobReportHandler, "GET", "/api/jobs/"+created.ID+"/report?slo_min_rate_ratio=0.5", vars)
+	var rep report.Report
+	if err := json.Unmarshal(rr.Body.Bytes(), &rep); err != nil {
+		t.Fatal(err)
+	}
+	if !rep.Pass || rep.LogCount == 0 || len(rep.Series) == 0 || rep.Latency.Count != rep.LogCount || len(rep.Checks) != 2 {
+		t.Errorf("expected a passing report of the finished job, got %+v", rep)
+	}
+	if _, ok := rep.Errors[tmpfile.Name()]; !ok {
+		t.Errorf("expected errors to be reported for the job's sink, got %v", rep.Errors)
+	}
+
+	rr = serveJobRequest(t, srv.jobReportHandler, "GET", "/api/jobs/"+created.ID+"/report?format=junit&slo_max_p99=1ns", vars)
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/xml") {
+		t.Errorf("expected an xml report, got %s", ct)
+	}
+	if !strings.Contains(rr.Body.String(), `<failure message="p99 write latency`) {
+		t.Errorf("expected the latency check to fail, got:\n%s", rr.Body.String())
+	}
+}
+
+// cpuSeconds is the CPU time the test binary has used, as accounted at the
+// end of a GC.
+func cpuSeconds() float64 {
+	cpu := []metrics.Sample{{Name: "/cpu/classes/user:cpu-seconds"}}
+	runtime.GC()
+	metrics.Read(cpu)
+	return cpu[0].Value.Float64()
+}
+
+func TestSlowEndlessJobsLeaveTheCPUIdle(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = tmpfile.Name()
+	srv.config.LogwildPerSecondRate = 10
+	defer srv.StopJobs()
+	before := cpuSeconds()
+	if err := srv.startContinuous(); err != nil {
+		t.Fatal(err)
+	}
+	rr := serveJobRequest(t, srv.jobCreateHandler, "POST", "/api/jobs?per_second=10&burst_dur=0", nil)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("handler returned bad status code: got %v want %v", rr.Code, http.StatusCreated)
+	}
+	time.Sleep(time.Second)
+	// each job would keep a core busy if it spun between events
+	if used := cpuSeconds() - before; used > 0.5 {
+		t.Errorf("expected two slow jobs to be mostly idle, used %.2fs of CPU in a second", used)
+	}
+}
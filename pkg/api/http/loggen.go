@@ -1,11 +1,12 @@
 package http
 
 import (
-	"errors"
+	"cmp"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
-	"strconv"
 	"time"
 
 	"go.opentelemetry.io/otel/attribute"
@@ -13,74 +14,176 @@ import (
 	"go.opentelemetry.io/otel/trace"
 
 	"mcgaunn.com/logwild/pkg/logmaker"
+	"mcgaunn.com/logwild/pkg/metrics"
 )
 
 // Loggen godoc
 // @Summary Log generation endpoint
-// @Description starts logging messages and reports stats
+// @Description starts logging messages and reports stats. with start_at or schedule the burst waits for its start, holding the request open until then. POST takes the settings as a GenerationSpec JSON body instead of query params
 // @Tags HTTP API
 // @Accept json
 // @Produce json
+// @Param start_at query string false "RFC 3339 time to start the burst at"
+// @Param schedule query string false "cron expression, e.g. */15 * * * * or @every 15m, whose next time starts the burst"
+// @Param spec body api.GenerationSpec false "generation settings, for POST"
 // @Success 200 {object} api.LogStatsResponse
+// @Failure 400 {object} api.ErrorResponseBody
+// @Failure 500 {object} api.ErrorResponseBody
 // @Router /api/loggen [get]
+// @Router /api/loggen [post]
 func (s *Server) logGenHandler(w http.ResponseWriter, r *http.Request) {
-	_, span := s.tracer.Start(r.Context(), "logGenHandler")
+	ctx, span := s.tracer.Start(r.Context(), "logGenHandler")
 	defer span.End()
+	p := newParams(r)
 	span.AddEvent("startInitializeLogger")
-	h := s.createLogHandlerOrPanic()
-	// create initial options from config
-	optFuncs := s.buildLoggerOptionsFromConfig()
-	// override functions based on query params
-	optFuncs = append(optFuncs, s.buildLoggerOptionsFromQueryParams(h, r)...)
+	gen, burstField := generation{}, "burst_dur"
+	if hasSpec(r) {
+		gen, burstField = s.specGeneration(r, p), "burst_duration"
+	} else {
+		gen = s.queryGeneration(r, p)
+	}
+	// a burst without an end would hold the request open forever
+	if gen.burst != nil && *gen.burst <= 0 {
+		p.fail(burstField, "must be above 0, as the request lasts as long as the burst")
+	}
+	format, sched, sensitive := gen.format, gen.sched, gen.sensitive
+	// create initial options from config, overridden by the request
+	optFuncs := append(s.buildLoggerOptionsFromConfig(), gen.optFuncs...)
+	if err := p.err(); err != nil {
+		s.ParamErrorResponse(w, r, span, err)
+		return
+	}
+	stream := s.genMetrics.Stream(metrics.KindLoggen, s.config.LogwildOutFile, format)
+	defer stream.Close()
+	h, sink, err := s.createLogHandler(format, stream)
+	if err != nil {
+		s.ErrorResponse(w, r, span, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// the burst is over once the handler returns, so nothing writes after this
+	defer sink.Close()
+	optFuncs = append(optFuncs, logmaker.WithLogger(slog.New(h)), logmaker.WithObserver(stream.Observer()))
+	// synthetic traces can be made children of this request when asked to
+	optFuncs = append(optFuncs, logmaker.WithParentContext(ctx))
+	if sensitive.Fraction > 0 {
+		// values injected without a record of them couldn't be verified
+		manifest, err := s.openSensitiveManifest()
+		if err != nil {
+			s.ErrorResponse(w, r, span, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if manifest != nil {
+			defer manifest.Close()
+			sensitive.Manifest = manifest
+		}
+	}
+	optFuncs = append(optFuncs, logmaker.WithSensitive(sensitive))
 	lm := logmaker.NewLogMaker(optFuncs...)
+	stream.SetTarget(lm.PerSecondRate)
+	s.genMetrics.JobStarted(metrics.KindLoggen)
+	defer s.genMetrics.JobFinished(metrics.KindLoggen)
 	span.AddEvent("doneInitializeLogger")
 	s.logger.Info("lm config", "perSecondRate", lm.PerSecondRate)
-	donech := make(chan int)
-	defer close(donech)
-	go func() {
-		err := lm.StartWriting(donech)
-		if err != nil {
-			span.SetStatus(codes.Error, err.Error())
-			s.logger.Error("encountered problem trying to start logmaker", "err", err)
-			// should return bad status to http as well
+	// the response waits for the start and the whole burst, which can both
+	// be longer than the server's write timeout; not every writer supports
+	// this, in which case the timeout stands
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	// everything is set up before waiting, so the burst starts on time
+	var scheduled time.Time
+	if !sched.immediate() {
+		scheduled = sched.next(time.Now())
+		s.logger.Info("waiting for scheduled start", "startAt", scheduled)
+		if err := sleepUntil(r.Context(), scheduled); err != nil {
+			s.logger.Info("request ended before its scheduled start", "startAt", scheduled)
+			return
 		}
-	}()
+	}
+	actualStart := time.Now()
 	span.AddEvent("startedWriting", trace.WithAttributes(attribute.Int("logCount", 0)))
-	logCount := <-donech
-	data := LogStatsResponse{logCount: logCount}
+	// the burst ends early when the client goes away
+	if err := lm.Run(ctx); err != nil {
+		s.logger.Error("log generation failed", "err", err, "logCount", lm.Stats().LogCount)
+		s.ErrorResponse(w, r, span, fmt.Sprintf("failed to write logs: %v", err), http.StatusInternalServerError)
+		return
+	}
+	stats := lm.Stats()
+	logCount := int(stats.LogCount)
+	data := LogStatsResponse{LogCount: logCount, Levels: stats.Levels, Fuzzed: stats.Fuzzed, startTimes: newStartTimes(scheduled, actualStart)}
 	span.AddEvent("doneWriting", trace.WithAttributes(attribute.Int("logCount", logCount),
 		attribute.Float64("effectiveLogsPerSecond", float64(logCount)/lm.BurstDuration.Seconds())))
 	span.SetStatus(codes.Ok, "successfully wrote logs")
 	s.JSONResponse(w, r, data)
 }
 
-func (s *Server) createLogHandlerOrPanic() *slog.JSONHandler {
-	lvl := &slog.LevelVar{}
+// createLogHandler opens the configured output file and returns a handler
+// writing format to it, along with the file for the caller to close.
+func (s *Server) createLogHandler(format string, stream *metrics.Stream) (slog.Handler, io.Closer, error) {
+	fp, err := logmaker.OpenSink(s.config.LogwildOutFile)
+	if err != nil {
+		s.logger.Error("failed to create log file", "err", err, "fileName", s.config.LogwildOutFile)
+		return nil, nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	h, err := logmaker.NewHandler(stream.Writer(fp), format)
+	if err != nil {
+		s.logger.Error("failed to create log handler", "err", err, "format", format)
+		fp.Close()
+		return nil, nil, err
+	}
+	return h, fp, nil
+}
 
-	var fp *os.File
-	if s.config.LogwildOutFile == "-" {
-		fp = os.Stdout
-	} else {
-		var err error
-		fp, err = os.OpenFile(s.config.LogwildOutFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-		if err != nil {
-			s.logger.Error("failed to create log file: ", err, "fileName", s.config.LogwildOutFile)
-			panic(err)
-		}
+// outputFormat returns the format asked for by the format param, falling
+// back to the preset's format and then the configured one.
+func (s *Server) outputFormat(p *params) string {
+	if format, ok := p.oneOf("format", logmaker.Formats); ok {
+		return format
 	}
-	h := slog.NewJSONHandler(fp, &slog.HandlerOptions{Level: lvl})
-	return h
+	if st := s.presetStream(p); st != nil && st.Format != "" {
+		return st.Format
+	}
+	return s.config.LogwildFormat
 }
 
 func (s *Server) buildLoggerOptionsFromConfig() []logmaker.OptFunc {
 	var optFuncs []logmaker.OptFunc
-	h := s.createLogHandlerOrPanic()
-	optFuncs = append(optFuncs, logmaker.WithLogger(slog.New(h)))
 	optFuncs = append(optFuncs, logmaker.WithPerSecondRate(s.config.LogwildPerSecondRate))
+	optFuncs = append(optFuncs, logmaker.WithDiagnosticLogger(s.logger))
+	if s.config.LogwildLevelMix != "" {
+		mix, err := logmaker.ParseLevelMix(s.config.LogwildLevelMix)
+		if err != nil {
+			s.logger.Error("could not parse configured level mix", "levelMix", s.config.LogwildLevelMix, "err", err)
+		} else {
+			optFuncs = append(optFuncs, logmaker.WithLevelMix(mix))
+		}
+	}
+	if s.config.LogwildFields != "" {
+		fields, err := logmaker.ParseFieldSpecs(s.config.LogwildFields)
+		if err != nil {
+			s.logger.Error("could not parse configured fields", "fields", s.config.LogwildFields, "err", err)
+		} else {
+			optFuncs = append(optFuncs, logmaker.WithFields(fields))
+		}
+	}
+	if s.spanProcessor != nil {
+		optFuncs = append(optFuncs, logmaker.WithSpanProcessor(s.spanProcessor))
+	}
+	optFuncs = append(optFuncs, logmaker.WithTraces(s.traceOptsFromConfig()))
+	kinds, err := logmaker.ParseStackTraceKinds(s.config.LogwildMultilineKinds)
+	if err != nil {
+		s.logger.Error("could not parse configured stack trace kinds", "multilineKinds", s.config.LogwildMultilineKinds, "err", err)
+	}
+	optFuncs = append(optFuncs, logmaker.WithMultiline(logmaker.MultilineOpts{
+		Fraction: s.config.LogwildMultilineFraction,
+		Frames:   s.config.LogwildMultilineFrames,
+		Kinds:    kinds,
+	}))
+	optFuncs = append(optFuncs, logmaker.WithFuzz(s.fuzzOptsFromConfig()))
 	return optFuncs
 }
 
-func (s *Server) buildLoggerOptionsFromQueryParams(h *slog.JSONHandler, r *http.Request) []logmaker.OptFunc {
+// buildLoggerOptionsFromQueryParams reads the generation params, recording
+// any that are bad in p.
+func (s *Server) buildLoggerOptionsFromQueryParams(r *http.Request, p *params) []logmaker.OptFunc {
 	var optFuncs []logmaker.OptFunc
 	_, span := s.tracer.Start(r.Context(), "handleQueryParams")
 	defer span.End()
@@ -89,38 +192,181 @@ func (s *Server) buildLoggerOptionsFromQueryParams(h *slog.JSONHandler, r *http.
 		"perSecondRate", s.config.LogwildPerSecondRate,
 		"outFile", s.config.LogwildOutFile)
 
-	perSecondRateInt, err := s.tryParseAndLogIntParam(r, "per_second")
-	if err == nil {
-		optFuncs = append(optFuncs, logmaker.WithPerSecondRate(perSecondRateInt))
+	// a preset sets defaults that the other params can still override
+	if st := s.presetStream(p); st != nil {
+		optFuncs = append(optFuncs, st.LogMakerOpts()...)
 	}
-	perMessageSizeInt, err := s.tryParseAndLogIntParam(r, "message_size")
-	if err == nil {
-		optFuncs = append(optFuncs, logmaker.WithPerMessageSize(perMessageSizeInt))
+
+	if perSecondRate, ok := p.int("per_second", 1, maxPerSecond); ok {
+		optFuncs = append(optFuncs, logmaker.WithPerSecondRate(perSecondRate))
 	}
-	burstDurationInt, err := s.tryParseAndLogIntParam(r, "burst_dur")
-	if err == nil {
-		optFuncs = append(optFuncs, logmaker.WithBurstDuration(time.Duration(burstDurationInt)*time.Second))
+	if perMessageSize, ok := p.int("message_size", 1, maxMessageSize); ok {
+		optFuncs = append(optFuncs, logmaker.WithPerMessageSize(perMessageSize))
 	}
-	optFuncs = append(optFuncs, logmaker.WithLogger(slog.New(h)))
+	p.parse("level_mix", func(v string) error {
+		mix, err := logmaker.ParseLevelMix(v)
+		if err == nil {
+			optFuncs = append(optFuncs, logmaker.WithLevelMix(mix))
+		}
+		return err
+	})
+	p.parse("fields", func(v string) error {
+		fields, err := logmaker.ParseFieldSpecs(v)
+		if err == nil {
+			optFuncs = append(optFuncs, logmaker.WithFields(fields))
+		}
+		return err
+	})
+	optFuncs = append(optFuncs, s.buildMultilineOptionFromQueryParams(p)...)
+	optFuncs = append(optFuncs, s.buildTraceOptionFromQueryParams(p)...)
+	optFuncs = append(optFuncs, s.buildFuzzOptionFromQueryParams(p)...)
 	s.logger.Info("configured optFuncs", "optFuncs", optFuncs)
 	return optFuncs
 }
 
-func (s *Server) tryParseAndLogIntParam(r *http.Request, paramName string) (int64, error) {
-	queryVals := r.URL.Query()
-	paramVal := queryVals.Get(paramName)
-	s.logger.Debug("handling parameter", "paramName", paramName, "paramVal", paramVal)
-	if paramVal == "" {
-		return 0, errors.New("requested parameter not present in request")
+func (s *Server) buildMultilineOptionFromQueryParams(p *params) []logmaker.OptFunc {
+	if !p.has("multiline_fraction", "multiline_frames", "multiline_kinds") {
+		return nil
+	}
+	// start from the configured settings so a single param can be overridden
+	kinds, _ := logmaker.ParseStackTraceKinds(s.config.LogwildMultilineKinds)
+	ml := logmaker.MultilineOpts{
+		Fraction: s.config.LogwildMultilineFraction,
+		Frames:   s.config.LogwildMultilineFrames,
+		Kinds:    kinds,
+	}
+	if fraction, ok := p.float("multiline_fraction", 0, 1); ok {
+		ml.Fraction = fraction
+	}
+	if frames, ok := p.int("multiline_frames", 1, maxMultilineFrames); ok {
+		ml.Frames = int(frames)
+	}
+	p.parse("multiline_kinds", func(v string) (err error) {
+		ml.Kinds, err = logmaker.ParseStackTraceKinds(v)
+		return err
+	})
+	return []logmaker.OptFunc{logmaker.WithMultiline(ml)}
+}
+
+func (s *Server) fuzzOptsFromConfig() logmaker.FuzzOpts {
+	mix, err := logmaker.ParseFuzzMix(s.config.LogwildFuzzMix)
+	if err != nil {
+		s.logger.Error("could not parse configured fuzz mix", "fuzzMix", s.config.LogwildFuzzMix, "err", err)
+	}
+	return logmaker.FuzzOpts{
+		Fraction: s.config.LogwildFuzzFraction,
+		Mix:      mix,
+		// a config that leaves it out gets logmaker's default, so a spec
+		// that only turns fuzzing on still passes validation
+		LongLineSize: cmp.Or(s.config.LogwildFuzzLongLineSize, logmaker.DefaultLongLineSize),
+	}
+}
+
+func (s *Server) buildFuzzOptionFromQueryParams(p *params) []logmaker.OptFunc {
+	if !p.has("fuzz_fraction", "fuzz_mix", "fuzz_long_line_size") {
+		return nil
+	}
+	// start from the configured settings so a single param can be overridden
+	fo := s.fuzzOptsFromConfig()
+	if fraction, ok := p.float("fuzz_fraction", 0, 1); ok {
+		fo.Fraction = fraction
+	}
+	p.parse("fuzz_mix", func(v string) error {
+		mix, err := logmaker.ParseFuzzMix(v)
+		if err == nil {
+			fo.Mix = mix
+		}
+		return err
+	})
+	if size, ok := p.int("fuzz_long_line_size", 1, maxLongLineSize); ok {
+		fo.LongLineSize = int(size)
+	}
+	return []logmaker.OptFunc{logmaker.WithFuzz(fo)}
+}
+
+func (s *Server) traceOptsFromConfig() logmaker.TraceOpts {
+	return logmaker.TraceOpts{
+		Enabled:  s.config.LogwildTrace,
+		Depth:    s.config.LogwildTraceDepth,
+		Fanout:   s.config.LogwildTraceFanout,
+		Services: logmaker.ParseTraceServices(s.config.LogwildTraceServices),
+	}
+}
+
+func (s *Server) buildTraceOptionFromQueryParams(p *params) []logmaker.OptFunc {
+	if !p.has("trace", "trace_depth", "trace_fanout", "trace_services", "trace_inherit") {
+		return nil
+	}
+	// start from the configured settings so a single param can be overridden
+	to := s.traceOptsFromConfig()
+	to.Enabled = true
+	if enabled, ok := p.bool("trace"); ok {
+		to.Enabled = enabled
+	}
+	if depth, ok := p.int("trace_depth", 1, maxTraceDepth); ok {
+		to.Depth = int(depth)
+	}
+	if fanout, ok := p.int("trace_fanout", 1, maxTraceFanout); ok {
+		to.Fanout = int(fanout)
+	}
+	if services := p.get("trace_services"); services != "" {
+		to.Services = logmaker.ParseTraceServices(services)
+	}
+	if inherit, ok := p.bool("trace_inherit"); ok {
+		to.Inherit = inherit
+	}
+	return []logmaker.OptFunc{logmaker.WithTraces(to)}
+}
+
+// sensitiveOpts returns the configured sensitive data options, overridden by
+// the sensitive_fraction and sensitive_kinds query parameters.
+func (s *Server) sensitiveOpts(p *params) logmaker.SensitiveOpts {
+	so := s.sensitiveOptsFromConfig()
+	if fraction, ok := p.float("sensitive_fraction", 0, 1); ok {
+		so.Fraction = fraction
+	}
+	p.parse("sensitive_kinds", func(v string) error {
+		kinds, err := logmaker.ParseSensitiveKinds(v)
+		if err == nil {
+			so.Kinds = kinds
+		}
+		return err
+	})
+	return so
+}
+
+func (s *Server) sensitiveOptsFromConfig() logmaker.SensitiveOpts {
+	kinds, err := logmaker.ParseSensitiveKinds(s.config.LogwildSensitiveKinds)
+	if err != nil {
+		s.logger.Error("could not parse configured sensitive data kinds", "sensitiveKinds", s.config.LogwildSensitiveKinds, "err", err)
+	}
+	return logmaker.SensitiveOpts{Fraction: s.config.LogwildSensitiveFraction, Kinds: kinds}
+}
+
+// openSensitiveManifest opens the file recording injected sensitive values.
+// Unless configured otherwise it sits next to the output file. When the
+// output goes to stdout and no manifest was configured it returns nil, and
+// values are injected without one.
+func (s *Server) openSensitiveManifest() (*os.File, error) {
+	name := s.config.LogwildSensitiveManifest
+	if name == "" {
+		if s.config.LogwildOutFile == "-" {
+			s.logger.Warn("injecting sensitive data without a manifest, set log-sensitive-manifest to record one")
+			return nil, nil
+		}
+		name = s.config.LogwildOutFile + ".manifest.jsonl"
 	}
-	intValue, err := strconv.ParseInt(paramVal, 10, 64)
+	fp, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
-		s.logger.Error("could not parse param as integer", "paramName", paramName, "paramVal", paramVal, "err", err)
-		return 0, errors.New("could not parse param as integer")
+		s.logger.Error("failed to open sensitive data manifest", "err", err, "fileName", name)
+		return nil, fmt.Errorf("failed to open sensitive data manifest: %w", err)
 	}
-	return intValue, nil
+	return fp, nil
 }
 
 type LogStatsResponse struct {
-	logCount int
+	LogCount int              `json:"log_count"`
+	Levels   map[string]int64 `json:"levels"`
+	Fuzzed   int64            `json:"fuzzed,omitempty"`
+	startTimes
 }
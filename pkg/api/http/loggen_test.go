@@ -1,11 +1,15 @@
 package http
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestLogGenHandler(t *testing.T) {
@@ -47,6 +51,90 @@ func TestLogGenHandlerUsesParams(t *testing.T) {
 	t.Log(rr.Body.String())
 }
 
+func TestLogGenHandlerReportsLevelMix(t *testing.T) {
+	req, err := http.NewRequest("GET", "/loggen?per_second=200&burst_dur=1&message_size=4&level_mix=0/0/1/1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	srv := NewMockServer()
+	handler := http.HandlerFunc(srv.logGenHandler)
+
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned bad status code: got %v want %v",
+			status, http.StatusOK)
+	}
+	var stats LogStatsResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.LogCount == 0 {
+		t.Errorf("expected some logs to be written")
+	}
+	if stats.Levels["info"] != 0 || stats.Levels["warn"]+stats.Levels["error"] != int64(stats.LogCount) {
+		t.Errorf("expected only warn and error levels, got %v", stats.Levels)
+	}
+}
+
+func TestLogGenHandlerWritesPlainMultilineEvents(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = tmpfile.Name()
+	req, err := http.NewRequest("GET", "/loggen?per_second=10&burst_dur=1&format=plain&multiline_fraction=1&multiline_kinds=python", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(srv.logGenHandler)
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned bad status code: got %v want %v", status, http.StatusOK)
+	}
+	content, err := os.ReadFile(tmpfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "Traceback (most recent call last):\n  File") {
+		t.Errorf("expected raw python tracebacks in output, got:\n%s", content)
+	}
+}
+
+func TestLogGenHandlerAttachesFields(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = tmpfile.Name()
+	req, err := http.NewRequest("GET", "/loggen?per_second=10&burst_dur=1&fields=tenant:3:sequential,user_id:1000", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(srv.logGenHandler)
+	handler.ServeHTTP(rr, req)
+
+	content, err := os.ReadFile(tmpfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), `"tenant":"tenant-0"`) || !strings.Contains(string(content), `"user_id":"user_id-`) {
+		t.Errorf("expected fields in output, got:\n%s", content)
+	}
+}
+
 func TestLogGenHandlerAppendsToExistingFile(t *testing.T) {
 	// Create a temporary file for logging output
 	tmpfile, err := os.CreateTemp("", "test.log")
@@ -108,3 +196,236 @@ func TestLogGenHandlerAppendsToExistingFile(t *testing.T) {
 	}
 }
 
+func TestLogGenHandlerStampsTraceContext(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = tmpfile.Name()
+	req, err := http.NewRequest("GET", "/loggen?per_second=10&burst_dur=1&format=ecs&trace=true&trace_services=web,db", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(srv.logGenHandler)
+	handler.ServeHTTP(rr, req)
+
+	content, err := os.ReadFile(tmpfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), `"trace.id":"`) {
+		t.Errorf("expected trace ids in output, got:\n%s", content)
+	}
+}
+
+func TestLogGenHandlerWritesSensitiveManifest(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	defer os.Remove(tmpfile.Name() + ".manifest.jsonl")
+	tmpfile.Close()
+
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = tmpfile.Name()
+	req, err := http.NewRequest("GET", "/loggen?per_second=10&burst_dur=1&sensitive_fraction=1&sensitive_kinds=credit_card", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(srv.logGenHandler)
+	handler.ServeHTTP(rr, req)
+
+	manifest, err := os.ReadFile(tmpfile.Name() + ".manifest.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(manifest), `"kind":"credit_card"`) {
+		t.Errorf("expected manifest entries, got:\n%s", manifest)
+	}
+}
+
+func TestSensitiveDataIsNotInjectedWithoutItsManifest(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = tmpfile.Name()
+	srv.config.LogwildSensitiveManifest = "/nonexistent/manifest.jsonl"
+	for target, handler := range map[string]http.HandlerFunc{
+		"/loggen?per_second=10&burst_dur=1&sensitive_fraction=1":   srv.logGenHandler,
+		"/api/jobs?per_second=10&burst_dur=1&sensitive_fraction=1": srv.jobCreateHandler,
+	} {
+		rr := serveJobRequest(t, handler, "POST", target, nil)
+		if rr.Code != http.StatusInternalServerError || !strings.Contains(rr.Body.String(), "sensitive data manifest") {
+			t.Errorf("expected %s to fail without its manifest, got %v %s", target, rr.Code, rr.Body.String())
+		}
+	}
+	if content, _ := os.ReadFile(tmpfile.Name()); len(content) > 0 {
+		t.Errorf("expected nothing to be written, got:\n%s", content)
+	}
+}
+
+func TestLogGenHandlerFuzzesRawOutput(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = tmpfile.Name()
+	req, err := http.NewRequest("GET", "/loggen?per_second=10&burst_dur=1&format=raw&fuzz_fraction=1&fuzz_mix=nul", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(srv.logGenHandler)
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned bad status code: got %v want %v", status, http.StatusOK)
+	}
+	content, err := os.ReadFile(tmpfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "\x00") {
+		t.Errorf("expected NUL bytes in raw output, got %q", content)
+	}
+}
+
+func TestReplayHandler(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = tmpfile.Name()
+	captured := "{\"time\":\"2024-03-01T10:00:00Z\",\"level\":\"INFO\",\"msg\":\"a\"}\n{\"time\":\"2024-03-01T10:00:01Z\",\"level\":\"ERROR\",\"msg\":\"b\"}\n"
+	req, err := http.NewRequest("POST", "/api/replay?timing=rate&per_second=100&inject_seq=true", strings.NewReader(captured))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(srv.replayHandler)
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned bad status code: got %v want %v", status, http.StatusOK)
+	}
+	if !strings.Contains(rr.Body.String(), `"log_count": 2`) {
+		t.Errorf("expected 2 replayed lines, got %s", rr.Body.String())
+	}
+	content, err := os.ReadFile(tmpfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(content), `{"seq":1,"time":"2024-03-01T10:00:00Z"`) {
+		t.Errorf("expected replayed lines with seq, got:\n%s", content)
+	}
+}
+
+func TestReplayOutlastsTheServerTimeouts(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = tmpfile.Name()
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(srv.replayHandler))
+	// stand in for --http-server-timeout, with the capture lasting longer
+	ts.Config.ReadTimeout = 300 * time.Millisecond
+	ts.Config.WriteTimeout = 300 * time.Millisecond
+	ts.Start()
+	defer ts.Close()
+
+	// the capture is sent as it is replayed, as a tail piped to curl would be
+	body, capture := io.Pipe()
+	go func() {
+		for i, msg := range []string{"a", "b", "c"} {
+			fmt.Fprintf(capture, "{\"time\":\"2024-03-01T10:00:0%d.%dZ\",\"level\":\"INFO\",\"msg\":%q}\n", i/2, i%2*5, msg)
+			time.Sleep(250 * time.Millisecond)
+		}
+		capture.Close()
+	}()
+	resp, err := http.Post(ts.URL+"/api/replay?timing=original", "text/plain", body)
+	if err != nil {
+		t.Fatalf("expected the replay to finish, got %v", err)
+	}
+	defer resp.Body.Close()
+	var stats LogStatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatalf("expected the replay's stats, got %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || stats.LogCount != 3 {
+		t.Errorf("expected all 3 lines to be replayed, got %v %+v", resp.StatusCode, stats)
+	}
+}
+
+func TestLogGenReportsAnUnwritableFile(t *testing.T) {
+	req, err := http.NewRequest("GET", "/loggen?per_second=10&burst_dur=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = "/nonexistent/logwild.log"
+	http.HandlerFunc(srv.logGenHandler).ServeHTTP(rr, req)
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("handler returned bad status code: got %v want %v", rr.Code, http.StatusInternalServerError)
+	}
+	var body ErrorResponseBody
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(body.Message, "failed to open log file") {
+		t.Errorf("expected the open error to be reported, got %+v", body)
+	}
+}
+
+func TestLogGenReportsFailedWrites(t *testing.T) {
+	// every write to /dev/full fails with ENOSPC
+	if _, err := os.Stat("/dev/full"); err != nil {
+		t.Skip("no /dev/full to write to")
+	}
+	req, err := http.NewRequest("GET", "/loggen?per_second=100&burst_dur=5", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = "/dev/full"
+	start := time.Now()
+	http.HandlerFunc(srv.logGenHandler).ServeHTTP(rr, req)
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("handler returned bad status code: got %v want %v", rr.Code, http.StatusInternalServerError)
+	}
+	var body ErrorResponseBody
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(body.Message, "failed to write logs") {
+		t.Errorf("expected the write error to be reported, got %+v", body)
+	}
+	if took := time.Since(start); took > 4*time.Second {
+		t.Errorf("expected the burst to stop at the first failed write, it took %s", took)
+	}
+}
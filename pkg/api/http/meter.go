@@ -0,0 +1,52 @@
+package http
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/viper"
+	otelprom "go.opentelemetry.io/contrib/bridges/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// initMeter pushes the HTTP and generator metrics over OTLP, next to traces.
+// The metrics are still kept in Prometheus collectors and bridged on every
+// export, so /metrics and OTLP always agree. The export interval follows
+// OTEL_METRIC_EXPORT_INTERVAL.
+func (s *Server) initMeter(ctx context.Context, gatherer prometheus.Gatherer) {
+	if viper.GetString("otel-service-name") == "" {
+		return
+	}
+
+	exporter, err := otlpmetricgrpc.New(ctx)
+	if err != nil {
+		s.logger.Error("creating OTLP metric exporter", "err", err)
+		return
+	}
+
+	reader := sdkmetric.NewPeriodicReader(exporter,
+		sdkmetric.WithProducer(otelprom.NewMetricProducer(otelprom.WithGatherer(gatherer))),
+	)
+	s.meterProvider = sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(reader),
+		sdkmetric.WithResource(otelResource()),
+	)
+	otel.SetMeterProvider(s.meterProvider)
+}
+
+// StopTelemetry flushes and stops the OpenTelemetry providers, so the last
+// metrics and spans of stopped jobs are exported before exit.
+func (s *Server) StopTelemetry(ctx context.Context) {
+	if s.meterProvider != nil {
+		if err := s.meterProvider.Shutdown(ctx); err != nil {
+			s.logger.Warn("stopping meter provider", "err", err)
+		}
+	}
+	if s.tracerProvider != nil {
+		if err := s.tracerProvider.Shutdown(ctx); err != nil {
+			s.logger.Warn("stopping tracer provider", "err", err)
+		}
+	}
+}
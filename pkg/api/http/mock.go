@@ -11,25 +11,32 @@ import (
 
 func NewMockServer() *Server {
 	config := &Config{
-		Port:                  "9998",
-		ServerShutdownTimeout: 5 * time.Second,
-		HttpServerTimeout:     30 * time.Second,
-		BackendURL:            []string{},
-		DataPath:              "/data",
-		ConfigPath:            "/config",
-		HttpClientTimeout:     30 * time.Second,
-		Hostname:              "localhost",
-		LogwildOutFile:        "-",
-		LogwildPerSecondRate:  5000,
-		LogwildPerMessageSize: 50,
+		Port:                     "9998",
+		ServerShutdownTimeout:    5 * time.Second,
+		TelemetryShutdownTimeout: 5 * time.Second,
+		HttpServerTimeout:        30 * time.Second,
+		BackendURL:               []string{},
+		DataPath:                 "/data",
+		ConfigPath:               "/config",
+		HttpClientTimeout:        30 * time.Second,
+		Hostname:                 "localhost",
+		LogwildOutFile:           "-",
+		LogwildPerSecondRate:     5000,
+		LogwildPerMessageSize:    50,
+		LogwildFormat:            "json",
+		LogwildMultilineFrames:   8,
+		LogwildTraceDepth:        3,
+		LogwildTraceFanout:       2,
+		LogwildFuzzLongLineSize:  4096,
 	}
 	h := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})
 	slog.SetDefault(slog.New(h))
 	logger := slog.Default().With("mockserver", "yes")
 	return &Server{
-		router: mux.NewRouter(),
-		logger: logger,
-		config: config,
-		tracer: noop.NewTracerProvider().Tracer("mock"),
+		router:   mux.NewRouter(),
+		logger:   logger,
+		config:   config,
+		tracer:   noop.NewTracerProvider().Tracer("mock"),
+		registry: newRegistry(config),
 	}
 }
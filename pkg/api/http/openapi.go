@@ -0,0 +1,102 @@
+package http
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// openAPIDoc describes the HTTP API. It is kept by hand alongside the
+// handlers' godoc comments; TestOpenAPICoversTheAPI checks it against the
+// routes and GenerationSpec, and TestOpenAPIDocumentsTheParamsHandlersRead
+// against the query params each handler reads.
+//
+//go:embed openapi.json
+var openAPIDoc []byte
+
+const componentSchemas = "#/components/schemas/"
+
+// generationSpecSchema is the GenerationSpec schema of the OpenAPI document
+// as a standalone JSON Schema, with the schemas it refers to under $defs.
+var generationSpecSchema = sync.OnceValues(func() ([]byte, error) {
+	var doc struct {
+		Components struct {
+			Schemas map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(openAPIDoc, &doc); err != nil {
+		return nil, err
+	}
+	schemas := doc.Components.Schemas
+	defs := map[string]any{}
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			for k, child := range v {
+				ref, ok := child.(string)
+				if k == "$ref" && ok && strings.HasPrefix(ref, componentSchemas) {
+					name := strings.TrimPrefix(ref, componentSchemas)
+					v[k] = "#/$defs/" + name
+					if _, seen := defs[name]; !seen {
+						defs[name] = schemas[name]
+						walk(schemas[name])
+					}
+					continue
+				}
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	spec := schemas["GenerationSpec"].(map[string]any)
+	walk(spec)
+	schema := map[string]any{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$id":     "/api/schemas/generation-spec.json",
+		"title":   "GenerationSpec",
+		"$defs":   defs,
+	}
+	for k, v := range spec {
+		schema[k] = v
+	}
+	return json.MarshalIndent(schema, "", "  ")
+})
+
+// OpenAPI godoc
+// @Summary OpenAPI document
+// @Description describes every endpoint, with the JSON Schema of the GenerationSpec body of POST /loggen and POST /api/jobs
+// @Tags HTTP API
+// @Produce json
+// @Success 200 {object} object
+// @Router /api/openapi.json [get]
+func (s *Server) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openAPIDoc)
+}
+
+// GenerationSpecSchema godoc
+// @Summary JSON Schema of a generation spec
+// @Description the GenerationSpec schema of the OpenAPI document on its own, for validating specs before sending them
+// @Tags HTTP API
+// @Produce json
+// @Success 200 {object} object
+// @Router /api/schemas/generation-spec.json [get]
+func (s *Server) generationSpecSchemaHandler(w http.ResponseWriter, r *http.Request) {
+	_, span := s.tracer.Start(r.Context(), "generationSpecSchemaHandler")
+	defer span.End()
+	schema, err := generationSpecSchema()
+	if err != nil {
+		s.ErrorResponse(w, r, span, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(schema)
+}
@@ -0,0 +1,285 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"mcgaunn.com/logwild/pkg/logmaker"
+	"mcgaunn.com/logwild/pkg/scenario"
+)
+
+type openAPISchema struct {
+	Ref        string                    `json:"$ref"`
+	Enum       []string                  `json:"enum"`
+	Properties map[string]*openAPISchema `json:"properties"`
+	Items      *openAPISchema            `json:"items"`
+}
+
+type openAPIParameter struct {
+	Name string `json:"name"`
+	In   string `json:"in"`
+}
+
+type openAPIOperation struct {
+	Parameters  []openAPIParameter `json:"parameters"`
+	RequestBody struct {
+		Content map[string]json.RawMessage `json:"content"`
+	} `json:"requestBody"`
+}
+
+type openAPI struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]*openAPISchema `json:"schemas"`
+	} `json:"components"`
+}
+
+// jsonNames are the JSON names of the fields of struct type t.
+func jsonNames(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestOpenAPICoversTheAPI(t *testing.T) {
+	var doc openAPI
+	if err := json.Unmarshal(openAPIDoc, &doc); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+
+	srv := NewMockServer()
+	srv.registerHandlers()
+	err := srv.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil || path == "/" || path == "/metrics" {
+			return nil
+		}
+		methods, _ := route.GetMethods()
+		for _, m := range methods {
+			if _, ok := doc.Paths[path][strings.ToLower(m)]; !ok {
+				t.Errorf("%s %s is not described", m, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schemas := doc.Components.Schemas
+	for name, typ := range map[string]reflect.Type{
+		"GenerationSpec":    reflect.TypeFor[GenerationSpec](),
+		"LevelMix":          reflect.TypeFor[logmaker.LevelMix](),
+		"FieldSpec":         reflect.TypeFor[logmaker.FieldSpec](),
+		"MultilineOpts":     reflect.TypeFor[logmaker.MultilineOpts](),
+		"FuzzOpts":          reflect.TypeFor[logmaker.FuzzOpts](),
+		"TraceOpts":         reflect.TypeFor[logmaker.TraceOpts](),
+		"SensitiveOpts":     reflect.TypeFor[logmaker.SensitiveOpts](),
+		"ErrorResponseBody": reflect.TypeFor[ErrorResponseBody](),
+	} {
+		var props []string
+		for prop := range schemas[name].Properties {
+			props = append(props, prop)
+		}
+		slices.Sort(props)
+		if want := jsonNames(typ); !slices.Equal(props, want) {
+			t.Errorf("%s schema has properties %v, want %v", name, props, want)
+		}
+	}
+
+	spec := schemas["GenerationSpec"].Properties
+	for name, want := range map[string][]string{
+		"format": logmaker.Formats,
+		"preset": scenario.PresetNames(),
+	} {
+		if !slices.Equal(spec[name].Enum, want) {
+			t.Errorf("%s enum is %v, want %v", name, spec[name].Enum, want)
+		}
+	}
+	for name, want := range map[string][]string{
+		"MultilineOpts": logmaker.StackTraceKinds,
+		"SensitiveOpts": logmaker.SensitiveKinds,
+	} {
+		if got := schemas[name].Properties["kinds"].Items.Enum; !slices.Equal(got, want) {
+			t.Errorf("%s kinds are %v, want %v", name, got, want)
+		}
+	}
+	if got := schemas["FieldSpec"].Properties["kind"].Enum; !slices.Equal(got, logmaker.FieldKinds) {
+		t.Errorf("FieldSpec kinds are %v, want %v", got, logmaker.FieldKinds)
+	}
+}
+
+// paramReads finds every query param name the package's handlers read.
+var paramReads = regexp.MustCompile(`\bp\.(?:int|float|bool|oneOf|parse|get|all|has)\(((?:"[a-z0-9_]+",? ?)+)`)
+
+func knownParams(t *testing.T) []string {
+	t.Helper()
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range paramReads.FindAllStringSubmatch(string(src), -1) {
+			for _, quoted := range strings.Split(m[1], ",") {
+				if param := strings.Trim(strings.TrimSpace(quoted), `"`); param != "" && !slices.Contains(names, param) {
+					names = append(names, param)
+				}
+			}
+		}
+	}
+	if len(names) == 0 {
+		t.Fatal("found no params read by the handlers")
+	}
+	return names
+}
+
+// TestOpenAPIDocumentsTheParamsHandlersRead asks every operation about every
+// query param any handler reads. As unknown params are rejected by name, the
+// params an operation didn't reject are the ones it reads, and those must be
+// the ones its document lists.
+func TestOpenAPIDocumentsTheParamsHandlersRead(t *testing.T) {
+	var doc openAPI
+	if err := json.Unmarshal(openAPIDoc, &doc); err != nil {
+		t.Fatal(err)
+	}
+	tmpfile, err := os.CreateTemp("", "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = tmpfile.Name()
+	srv.registerHandlers()
+	if err := srv.startContinuous(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.StopJobs()
+	run := srv.startScenario(scenario.Preset("chatty-debug-service"), time.Now().Add(time.Hour))
+	defer srv.StopScenarios()
+	ids := strings.NewReplacer("/api/jobs/{id}", "/api/jobs/"+continuousJobID, "/api/scenarios/{id}", "/api/scenarios/"+run.id, "{name}", "chatty-debug-service")
+
+	known := knownParams(t)
+	q := url.Values{}
+	for _, name := range known {
+		q.Set(name, "?")
+	}
+	for path, ops := range doc.Paths {
+		var shared []openAPIParameter
+		if raw, ok := ops["parameters"]; ok {
+			if err := json.Unmarshal(raw, &shared); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for method, raw := range ops {
+			if method == "parameters" {
+				continue
+			}
+			var op openAPIOperation
+			if err := json.Unmarshal(raw, &op); err != nil {
+				t.Fatal(err)
+			}
+			var documented []string
+			for _, prm := range append(op.Parameters, shared...) {
+				if prm.In == "query" {
+					documented = append(documented, prm.Name)
+				}
+			}
+			slices.Sort(documented)
+			method = strings.ToUpper(method)
+			body := "{}"
+			if path == "/api/scenarios" {
+				body = `{"streams": [{"name": "a"}], "phases": [{"duration": "1s"}]}`
+			}
+			req := httptest.NewRequest(method, ids.Replace(path)+"?"+q.Encode(), strings.NewReader(body))
+			if _, isJSON := op.RequestBody.Content["application/json"]; isJSON && len(documented) == 0 {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			var match mux.RouteMatch
+			if !srv.router.Match(req, &match) || match.MatchErr != nil {
+				t.Errorf("%s %s is described but has no route", method, path)
+				continue
+			}
+			// asking could start or stop something unless the params are
+			// rejected, so only ask what can't change anything
+			if method != "GET" && len(documented) == 0 {
+				continue
+			}
+			// event streams last as long as the request
+			ctx, cancel := context.WithTimeout(req.Context(), 100*time.Millisecond)
+			rr := httptest.NewRecorder()
+			srv.router.ServeHTTP(rr, req.WithContext(ctx))
+			cancel()
+			// a handler that reads no params doesn't reject any either
+			var read []string
+			var res ErrorResponseBody
+			if rr.Code == http.StatusBadRequest && json.Unmarshal(rr.Body.Bytes(), &res) == nil && len(res.Fields) > 0 {
+				read = slices.Clone(known)
+				for _, fe := range res.Fields {
+					if fe.Message == "is not a known param" {
+						read = slices.DeleteFunc(read, func(name string) bool { return name == fe.Field })
+					}
+				}
+			}
+			slices.Sort(read)
+			if !slices.Equal(read, documented) {
+				t.Errorf("%s %s reads %v but documents %v", method, path, read, documented)
+			}
+		}
+	}
+}
+
+func TestGenerationSpecSchemaStandsAlone(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/schemas/generation-spec.json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	srv := NewMockServer()
+	http.HandlerFunc(srv.generationSpecSchemaHandler).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned bad status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var schema struct {
+		Schema     string                     `json:"$schema"`
+		Properties map[string]*openAPISchema  `json:"properties"`
+		Defs       map[string]json.RawMessage `json:"$defs"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &schema); err != nil {
+		t.Fatal(err)
+	}
+	if schema.Schema == "" || schema.Properties["level_mix"].Ref != "#/$defs/LevelMix" || schema.Defs["LevelMix"] == nil {
+		t.Errorf("expected references to point into $defs, got %s", rr.Body.String())
+	}
+	if strings.Contains(rr.Body.String(), "#/components/") {
+		t.Errorf("expected no references into the OpenAPI document, got %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,108 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"mcgaunn.com/logwild/pkg/scenario"
+)
+
+// PresetResponse describes a built-in scenario.
+type PresetResponse struct {
+	*scenario.Scenario
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+func newPresetResponse(sc *scenario.Scenario) PresetResponse {
+	return PresetResponse{Scenario: sc, DurationSeconds: sc.Duration().Seconds()}
+}
+
+// presetStream is the stream of the preset named by the preset query param,
+// whose format, fields, level mix and rate a single LogMaker can use. It is
+// nil when no preset, or an unknown or multi-stream one, was asked for.
+func (s *Server) presetStream(p *params) *scenario.Stream {
+	name, ok := p.oneOf("preset", scenario.PresetNames())
+	if !ok {
+		return nil
+	}
+	st, err := singleStream(scenario.Preset(name))
+	if err != nil {
+		p.fail("preset", "%s", err)
+		return nil
+	}
+	return st
+}
+
+// singleStream is the only stream of sc. A preset with more than one stream
+// needs the scenario runner to write them side by side, so using just one
+// of them would quietly generate something else.
+func singleStream(sc *scenario.Scenario) (*scenario.Stream, error) {
+	if len(sc.Streams) > 1 {
+		return nil, fmt.Errorf("%s has %d streams, which only a scenario can run together, start it with POST /api/presets/%s or POST /api/scenarios", sc.Name, len(sc.Streams), sc.Name)
+	}
+	return &sc.Streams[0], nil
+}
+
+// PresetList godoc
+// @Summary List built-in workload presets
+// @Description lists the preset scenarios, which can be run with POST /api/presets/{name} or, when they have a single stream, used with the preset param of /loggen and /api/jobs
+// @Tags HTTP API
+// @Produce json
+// @Success 200 {array} api.PresetResponse
+// @Router /api/presets [get]
+func (s *Server) presetListHandler(w http.ResponseWriter, r *http.Request) {
+	presets := scenario.Presets()
+	res := make([]PresetResponse, 0, len(presets))
+	for _, sc := range presets {
+		res = append(res, newPresetResponse(sc))
+	}
+	s.JSONResponse(w, r, res)
+}
+
+// PresetGet godoc
+// @Summary Built-in workload preset
+// @Description describes the streams and phases of a preset scenario
+// @Tags HTTP API
+// @Produce json
+// @Param name path string true "preset name"
+// @Success 200 {object} api.PresetResponse
+// @Router /api/presets/{name} [get]
+func (s *Server) presetGetHandler(w http.ResponseWriter, r *http.Request) {
+	_, span := s.tracer.Start(r.Context(), "presetGetHandler")
+	defer span.End()
+	sc := scenario.Preset(mux.Vars(r)["name"])
+	if sc == nil {
+		s.ErrorResponse(w, r, span, "preset not found", http.StatusNotFound)
+		return
+	}
+	s.JSONResponse(w, r, newPresetResponse(sc))
+}
+
+// PresetRun godoc
+// @Summary Run a built-in workload preset
+// @Description runs every phase of a preset scenario in the background, writing to the configured output file
+// @Tags HTTP API
+// @Produce json
+// @Param name path string true "preset name"
+// @Success 201 {object} api.ScenarioResponse
+// @Failure 400 {object} api.ErrorResponseBody
+// @Router /api/presets/{name} [post]
+func (s *Server) presetRunHandler(w http.ResponseWriter, r *http.Request) {
+	_, span := s.tracer.Start(r.Context(), "presetRunHandler")
+	defer span.End()
+	sc := scenario.Preset(mux.Vars(r)["name"])
+	if sc == nil {
+		s.ErrorResponse(w, r, span, "preset not found", http.StatusNotFound)
+		return
+	}
+	// a preset runs as it is, so any param is one it doesn't know
+	if err := newParams(r).err(); err != nil {
+		s.ParamErrorResponse(w, r, span, err)
+		return
+	}
+	run := s.startScenario(sc, time.Time{})
+	s.JSONResponseCode(w, r, run.status(), http.StatusCreated)
+}
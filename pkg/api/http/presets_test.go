@@ -0,0 +1,101 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPresetListHandler(t *testing.T) {
+	srv := NewMockServer()
+	rr := serveJobRequest(t, srv.presetListHandler, "GET", "/api/presets", nil)
+	var presets []PresetResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &presets); err != nil {
+		t.Fatal(err)
+	}
+	if len(presets) != 6 {
+		t.Fatalf("expected 6 presets, got %d", len(presets))
+	}
+	for _, p := range presets {
+		if p.Name == "" || p.DurationSeconds <= 0 || len(p.Streams) == 0 {
+			t.Errorf("expected preset to describe its streams and duration, got %+v", p)
+		}
+	}
+}
+
+func TestLogGenHandlerAppliesPreset(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = tmpfile.Name()
+	// the preset's rate is overridden, its format, fields and level mix are not
+	req, err := http.NewRequest("GET", "/loggen?preset=chatty-debug-service&per_second=50&burst_dur=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(srv.logGenHandler).ServeHTTP(rr, req)
+
+	var stats LogStatsResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.LogCount > 100 || stats.Levels["debug"] == 0 {
+		t.Errorf("expected ~50 mostly debug logs, got %+v", stats)
+	}
+	content, err := os.ReadFile(tmpfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(content), "time=") || !strings.Contains(string(content), "component=") {
+		t.Errorf("expected text lines with the preset's fields, got:\n%s", content)
+	}
+}
+
+func TestMultiStreamPresetsNeedAScenario(t *testing.T) {
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = "/nonexistent/logwild.log"
+	for _, rr := range []*httptest.ResponseRecorder{
+		serveJobRequest(t, srv.logGenHandler, "GET", "/loggen?preset=auth-failures-bruteforce&burst_dur=1", nil),
+		serveJobRequest(t, srv.jobCreateHandler, "POST", "/api/jobs?preset=auth-failures-bruteforce", nil),
+		serveSpecRequest(t, srv.jobCreateHandler, "/api/jobs", `{"preset": "auth-failures-bruteforce"}`),
+	} {
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("handler returned bad status code: got %v want %v", rr.Code, http.StatusBadRequest)
+			continue
+		}
+		var body ErrorResponseBody
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if len(body.Fields) != 1 || body.Fields[0].Field != "preset" || !strings.Contains(body.Fields[0].Message, "POST /api/presets/auth-failures-bruteforce") {
+			t.Errorf("expected the preset to be pointed at the scenario runner, got %+v", body.Fields)
+		}
+	}
+}
+
+func TestPresetRunsRejectParams(t *testing.T) {
+	srv := NewMockServer()
+	rr := serveJobRequest(t, srv.presetRunHandler, "POST", "/api/presets/nginx-web-tier?per_second=10", map[string]string{"name": "nginx-web-tier"})
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("handler returned bad status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	var body ErrorResponseBody
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Fields) != 1 || body.Fields[0].Field != "per_second" {
+		t.Errorf("expected per_second to be reported, got %+v", body.Fields)
+	}
+	if runs := srv.scenarios.list(); len(runs) != 0 {
+		t.Errorf("expected nothing to be started, got %d runs", len(runs))
+	}
+}
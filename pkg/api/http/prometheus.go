@@ -20,7 +20,9 @@ type PrometheusMiddleware struct {
 	Counter   *prometheus.CounterVec
 }
 
-func NewPrometheusMiddleware() *PrometheusMiddleware {
+// NewPrometheusMiddleware creates the HTTP metrics and registers them with
+// reg.
+func NewPrometheusMiddleware(reg prometheus.Registerer) *PrometheusMiddleware {
 	// used for monitoring and alerting (RED method)
 	histogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Subsystem: "http",
@@ -38,8 +40,7 @@ func NewPrometheusMiddleware() *PrometheusMiddleware {
 		[]string{"status"},
 	)
 
-	prometheus.MustRegister(histogram)
-	prometheus.MustRegister(counter)
+	reg.MustRegister(histogram, counter)
 
 	return &PrometheusMiddleware{
 		Histogram: histogram,
@@ -49,7 +50,7 @@ func NewPrometheusMiddleware() *PrometheusMiddleware {
 
 // Metrics godoc
 // @Summary Prometheus metrics
-// @Description returns HTTP requests duration and Go runtime metrics
+// @Description returns HTTP request and generator metrics, and Go runtime metrics when enabled
 // @Tags Kubernetes
 // @Produce plain
 // @Router /metrics [get]
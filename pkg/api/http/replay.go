@@ -0,0 +1,80 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"mcgaunn.com/logwild/pkg/logmaker"
+)
+
+// Replay godoc
+// @Summary Log replay endpoint
+// @Description re-emits the lines of the log file in the request body to the configured output file and reports stats
+// @Tags HTTP API
+// @Accept plain
+// @Produce json
+// @Param timing query string false "original or rate"
+// @Param speed query number false "speed factor applied to original timing"
+// @Param per_second query int false "lines per second with rate timing"
+// @Param rewrite_timestamps query bool false "replace timestamps with the time of replay"
+// @Param inject_seq query bool false "add a unique seq field to every line"
+// @Success 200 {object} api.LogStatsResponse
+// @Router /api/replay [post]
+func (s *Server) replayHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, span := s.tracer.Start(r.Context(), "replayHandler")
+	defer span.End()
+
+	p := newParams(r)
+	var ro logmaker.ReplayOpts
+	ro.Timing, _ = p.oneOf("timing", logmaker.ReplayTimings)
+	if speed, ok := p.float("speed", minReplaySpeed, maxReplaySpeed); ok {
+		ro.Speed = speed
+	}
+	if rewrite, ok := p.bool("rewrite_timestamps"); ok {
+		ro.RewriteTimestamps = rewrite
+	}
+	if inject, ok := p.bool("inject_seq"); ok {
+		ro.InjectSeq = inject
+	}
+	optFuncs := []logmaker.OptFunc{
+		logmaker.WithPerSecondRate(s.config.LogwildPerSecondRate),
+		logmaker.WithDiagnosticLogger(s.logger),
+	}
+	if perSecond, ok := p.int("per_second", 1, maxPerSecond); ok {
+		optFuncs = append(optFuncs, logmaker.WithPerSecondRate(perSecond))
+	}
+	if err := p.err(); err != nil {
+		s.ParamErrorResponse(w, r, span, err)
+		return
+	}
+	lm := logmaker.NewLogMaker(optFuncs...)
+
+	sink, err := logmaker.OpenSink(s.config.LogwildOutFile)
+	if err != nil {
+		s.logger.Error("failed to open log file", "err", err, "fileName", s.config.LogwildOutFile)
+		s.ErrorResponse(w, r, span, "failed to open log file", http.StatusInternalServerError)
+		return
+	}
+	defer sink.Close()
+
+	// with original timing the body is read for as long as the capture
+	// lasts, which can be well past the server's timeouts; not every writer
+	// supports this, in which case the timeouts stand
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(time.Time{})
+	_ = rc.SetWriteDeadline(time.Time{})
+	span.AddEvent("startedReplay", trace.WithAttributes(attribute.String("timing", ro.Timing)))
+	if err := lm.Replay(ctx, r.Body, sink, ro); err != nil {
+		s.logger.Error("replay failed", "err", err)
+		s.ErrorResponse(w, r, span, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	stats := lm.Stats()
+	span.AddEvent("doneReplay", trace.WithAttributes(attribute.Int64("logCount", stats.LogCount)))
+	span.SetStatus(codes.Ok, "successfully replayed logs")
+	s.JSONResponse(w, r, LogStatsResponse{LogCount: int(stats.LogCount), Levels: stats.Levels})
+}
@@ -0,0 +1,110 @@
+package http
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/trace"
+
+	"mcgaunn.com/logwild/pkg/report"
+)
+
+// sloFromParams is the configured SLO, with thresholds overridden by the
+// slo_min_rate_ratio, slo_max_p99 and slo_max_errors params.
+func (s *Server) sloFromParams(p *params) report.SLO {
+	slo := report.SLO{
+		MinRateRatio:  s.config.ReportSLOMinRateRatio,
+		MaxP99Latency: s.config.ReportSLOMaxP99,
+		MaxErrors:     s.config.ReportSLOMaxErrors,
+	}
+	if ratio, ok := p.float("slo_min_rate_ratio", 0, 1); ok {
+		slo.MinRateRatio = ratio
+	}
+	p.parse("slo_max_p99", func(v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return errors.New("must be a duration such as 5ms")
+		}
+		slo.MaxP99Latency = d
+		return nil
+	})
+	if maxErrors, ok := p.int("slo_max_errors", 0, math.MaxInt64); ok {
+		slo.MaxErrors = maxErrors
+	}
+	return slo
+}
+
+// writeReport writes rep in the format asked for by the format param, JSON
+// by default, unless any of p is bad.
+func (s *Server) writeReport(w http.ResponseWriter, r *http.Request, span trace.Span, p *params, rep report.Report) {
+	format, ok := p.oneOf("format", report.Formats)
+	if !ok {
+		format = report.FormatJSON
+	}
+	if err := p.err(); err != nil {
+		s.ParamErrorResponse(w, r, span, err)
+		return
+	}
+	w.Header().Set("Content-Type", report.ContentType(format))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	if err := rep.Write(w, format); err != nil {
+		s.logger.Error("failed to write report", "err", err)
+	}
+}
+
+func (j *job) report(slo report.SLO) report.Report {
+	return report.New(j.id, slo, report.Source{Sink: j.sinkPath, Record: j.lm.Record()})
+}
+
+// JobReport godoc
+// @Summary Log generation job report
+// @Description summarises a job's target and achieved rate per second, write latency percentiles, errors by sink and SLO checks, so far or after it finished
+// @Tags HTTP API
+// @Produce json
+// @Produce plain
+// @Produce xml
+// @Param id path string true "job id"
+// @Param format query string false "json, markdown or junit"
+// @Param slo_min_rate_ratio query number false "lowest ratio of achieved to target events that passes"
+// @Param slo_max_p99 query string false "slowest p99 write latency that passes, e.g. 5ms"
+// @Param slo_max_errors query int false "most write errors that pass"
+// @Success 200 {object} report.Report
+// @Router /api/jobs/{id}/report [get]
+func (s *Server) jobReportHandler(w http.ResponseWriter, r *http.Request) {
+	_, span := s.tracer.Start(r.Context(), "jobReportHandler")
+	defer span.End()
+	j := s.jobs.get(jobID(r))
+	if j == nil {
+		s.ErrorResponse(w, r, span, "job not found", http.StatusNotFound)
+		return
+	}
+	p := newParams(r)
+	s.writeReport(w, r, span, p, j.report(s.sloFromParams(p)))
+}
+
+// ScenarioReport godoc
+// @Summary Scenario run report
+// @Description summarises a scenario run across its streams, with the same params as the job report
+// @Tags HTTP API
+// @Produce json
+// @Produce plain
+// @Produce xml
+// @Param id path string true "scenario id"
+// @Param format query string false "json, markdown or junit"
+// @Success 200 {object} report.Report
+// @Router /api/scenarios/{id}/report [get]
+func (s *Server) scenarioReportHandler(w http.ResponseWriter, r *http.Request) {
+	_, span := s.tracer.Start(r.Context(), "scenarioReportHandler")
+	defer span.End()
+	run := s.scenarios.get(mux.Vars(r)["id"])
+	if run == nil {
+		s.ErrorResponse(w, r, span, "scenario not found", http.StatusNotFound)
+		return
+	}
+	p := newParams(r)
+	s.writeReport(w, r, span, p, run.runner.Report(s.sloFromParams(p)))
+}
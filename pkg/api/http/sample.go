@@ -0,0 +1,64 @@
+package http
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+
+	"mcgaunn.com/logwild/pkg/logmaker"
+)
+
+const (
+	defaultSampleCount = 10
+	// maxSampleCount keeps a sample small enough to hold in memory
+	maxSampleCount = 10000
+)
+
+// Sample godoc
+// @Summary Generate sample lines
+// @Description returns generated lines in the response body instead of writing them anywhere, with the same format, preset, level mix, field, multiline, fuzz and sensitive data params as /loggen, for trying out parser configs
+// @Tags HTTP API
+// @Produce plain
+// @Param count query int false "number of lines, 10 by default"
+// @Param format query string false "output format"
+// @Param preset query string false "preset to take defaults from"
+// @Success 200 {string} string
+// @Router /api/sample [get]
+func (s *Server) sampleHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, span := s.tracer.Start(r.Context(), "sampleHandler")
+	defer span.End()
+	p := newParams(r)
+	count := int64(defaultSampleCount)
+	if n, ok := p.int("count", 1, maxSampleCount); ok {
+		count = n
+	}
+	format := s.outputFormat(p)
+	optFuncs := s.buildLoggerOptionsFromConfig()
+	optFuncs = append(optFuncs, logmaker.WithPerMessageSize(s.config.LogwildPerMessageSize))
+	optFuncs = append(optFuncs, s.buildLoggerOptionsFromQueryParams(r, p)...)
+	// no manifest, since the sensitive values never reach a sink
+	optFuncs = append(optFuncs, logmaker.WithSensitive(s.sensitiveOpts(p)))
+	if err := p.err(); err != nil {
+		s.ParamErrorResponse(w, r, span, err)
+		return
+	}
+	var buf bytes.Buffer
+	h, err := logmaker.NewHandler(&buf, format)
+	if err != nil {
+		s.ErrorResponse(w, r, span, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	optFuncs = append(optFuncs, logmaker.WithLogger(slog.New(h)), logmaker.WithParentContext(ctx))
+	lm := logmaker.NewLogMaker(optFuncs...)
+	for i := int64(0); i < count; i++ {
+		msg := logmaker.GetFakeSentence(int(lm.Options().PerMessageSize))
+		if err := logmaker.WriteLog(lm, msg); err != nil {
+			s.ErrorResponse(w, r, span, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,58 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSampleHandlerReturnsLinesWithoutWriting(t *testing.T) {
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = "/nonexistent/logwild.log"
+	req, err := http.NewRequest("GET", "/api/sample?count=25&format=text&fields=tenant:3&level_mix=0/0/0/1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(srv.sampleHandler).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned bad status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	lines := strings.Split(strings.TrimSuffix(rr.Body.String(), "\n"), "\n")
+	if len(lines) != 25 {
+		t.Fatalf("expected 25 lines, got %d:\n%s", len(lines), rr.Body.String())
+	}
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "time=") || !strings.Contains(line, "level=ERROR") || !strings.Contains(line, "tenant=tenant-") {
+			t.Errorf("expected a logfmt error line with a tenant, got %s", line)
+		}
+	}
+	if _, err := os.Stat(srv.config.LogwildOutFile); err == nil {
+		t.Error("expected nothing to be written to the sink")
+	}
+}
+
+func TestSampleHandlerUsesPresets(t *testing.T) {
+	srv := NewMockServer()
+	req, err := http.NewRequest("GET", "/api/sample?preset=chatty-debug-service&count=3", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(srv.sampleHandler).ServeHTTP(rr, req)
+	if n := strings.Count(rr.Body.String(), "\n"); n != 3 {
+		t.Errorf("expected 3 lines, got %d:\n%s", n, rr.Body.String())
+	}
+
+	req, err = http.NewRequest("GET", "/api/sample?count=0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(srv.sampleHandler).ServeHTTP(rr, req)
+	if !strings.Contains(rr.Body.String(), `"code": 400`) {
+		t.Errorf("expected a bad count to be rejected, got %s", rr.Body.String())
+	}
+}
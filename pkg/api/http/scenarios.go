@@ -0,0 +1,348 @@
+package http
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"mcgaunn.com/logwild/pkg/metrics"
+	"mcgaunn.com/logwild/pkg/scenario"
+)
+
+// maxScenarioSize limits the body of a scenario request.
+const maxScenarioSize = 1 << 20
+
+// scenarioRun is one scenario run in the background.
+type scenarioRun struct {
+	id         string
+	runner     *scenario.Runner
+	mu         sync.Mutex
+	startedAt  time.Time
+	startAt    time.Time
+	finishedAt time.Time
+	stopped    bool
+	err        error
+	cancel     context.CancelFunc
+	done       chan struct{}
+}
+
+// maxFinishedScenarios is how many finished scenarios are kept for their
+// results and reports before the oldest are forgotten.
+const maxFinishedScenarios = 100
+
+// scenarioRegistry keeps every scenario the server started until it is
+// deleted. Like jobs, only the latest keepFinished finished scenarios are
+// kept, so a worker driven by a coordinator doesn't grow without bound.
+type scenarioRegistry struct {
+	mu     sync.Mutex
+	runs   map[string]*scenarioRun
+	lastID int
+	// keepFinished defaults to maxFinishedScenarios
+	keepFinished int
+}
+
+// ScenarioResponse describes a scenario run.
+type ScenarioResponse struct {
+	ID         string     `json:"id"`
+	State      string     `json:"state"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// StartAt is when the first phase was scheduled to start, if it
+	// didn't start right away.
+	StartAt *time.Time `json:"start_at,omitempty"`
+	scenario.Result
+	Error string `json:"error,omitempty"`
+}
+
+func (sr *scenarioRegistry) add(run *scenarioRun) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	if sr.runs == nil {
+		sr.runs = map[string]*scenarioRun{}
+	}
+	sr.runs[run.id] = run
+	sr.evict()
+}
+
+// evict forgets the oldest finished runs beyond keepFinished. sr.mu must be
+// held.
+func (sr *scenarioRegistry) evict() {
+	var finished []*scenarioRun
+	for _, run := range sr.runs {
+		select {
+		case <-run.done:
+			finished = append(finished, run)
+		default:
+		}
+	}
+	keep := cmp.Or(sr.keepFinished, maxFinishedScenarios)
+	if len(finished) <= keep {
+		return
+	}
+	// finishedAt is no longer written once done is closed
+	sort.Slice(finished, func(a, b int) bool { return finished[a].finishedAt.Before(finished[b].finishedAt) })
+	for _, run := range finished[:len(finished)-keep] {
+		delete(sr.runs, run.id)
+	}
+}
+
+func (sr *scenarioRegistry) newID() string {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	sr.lastID++
+	return fmt.Sprintf("scenario-%d", sr.lastID)
+}
+
+func (sr *scenarioRegistry) get(id string) *scenarioRun {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	return sr.runs[id]
+}
+
+func (sr *scenarioRegistry) remove(id string) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	delete(sr.runs, id)
+}
+
+// list returns the runs in the order they were started.
+func (sr *scenarioRegistry) list() []*scenarioRun {
+	sr.mu.Lock()
+	runs := make([]*scenarioRun, 0, len(sr.runs))
+	for _, run := range sr.runs {
+		runs = append(runs, run)
+	}
+	sr.mu.Unlock()
+	sort.Slice(runs, func(a, b int) bool { return runs[a].startedAt.Before(runs[b].startedAt) })
+	return runs
+}
+
+// startScenario runs sc in the background, from startAt if it is set.
+// Streams write to the configured output file.
+func (s *Server) startScenario(sc *scenario.Scenario, startAt time.Time) *scenarioRun {
+	run := &scenarioRun{id: s.scenarios.newID(), startAt: startAt, done: make(chan struct{})}
+	run.runner = scenario.NewRunner(sc,
+		scenario.WithStartAt(startAt),
+		scenario.WithDefaultSink(s.config.LogwildOutFile),
+		scenario.WithDefaultFormat(s.config.LogwildFormat),
+		scenario.WithLogMakerOpts(s.buildLoggerOptionsFromConfig()...),
+		scenario.WithLogger(s.logger),
+		scenario.WithMetrics(s.genMetrics, run.id))
+	ctx, cancel := context.WithCancel(context.Background())
+	run.cancel = cancel
+	run.startedAt = time.Now()
+	s.scenarios.add(run)
+	s.genMetrics.JobStarted(metrics.KindScenario)
+	go func() {
+		_, err := run.runner.Run(ctx)
+		s.genMetrics.JobFinished(metrics.KindScenario)
+		if errors.Is(err, context.Canceled) {
+			err = nil
+		}
+		run.mu.Lock()
+		run.err = err
+		run.finishedAt = time.Now()
+		run.mu.Unlock()
+		if err != nil {
+			s.logger.Error("scenario failed", "scenario", run.id, "err", err)
+		}
+		close(run.done)
+	}()
+	s.logger.Info("started scenario", "scenario", run.id, "name", sc.Name, "duration", sc.Duration())
+	return run
+}
+
+// stop cancels the run and waits for events in flight to be written.
+func (run *scenarioRun) stop() {
+	run.mu.Lock()
+	select {
+	case <-run.done:
+	default:
+		run.stopped = true
+	}
+	run.mu.Unlock()
+	run.cancel()
+	<-run.done
+}
+
+func (run *scenarioRun) status() ScenarioResponse {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	res := ScenarioResponse{
+		ID:        run.id,
+		State:     JobRunning,
+		StartedAt: run.startedAt,
+		Result:    run.runner.Result(),
+	}
+	if !run.startAt.IsZero() {
+		startAt := run.startAt
+		res.StartAt = &startAt
+	}
+	if !run.finishedAt.IsZero() {
+		finishedAt := run.finishedAt
+		res.FinishedAt = &finishedAt
+		switch {
+		case run.err != nil:
+			res.State = JobFailed
+			res.Error = run.err.Error()
+		case run.stopped:
+			res.State = JobStopped
+		default:
+			res.State = JobFinished
+		}
+	}
+	return res
+}
+
+// StopScenarios stops every running scenario, waiting for events in flight
+// to be written.
+func (s *Server) StopScenarios() {
+	for _, run := range s.scenarios.list() {
+		run.stop()
+	}
+}
+
+// ScenarioCreate godoc
+// @Summary Run a scenario
+// @Description runs the YAML or JSON scenario in the request body in the background. streams write to the configured output file, so they can't name their own sink
+// @Tags HTTP API
+// @Accept json
+// @Produce json
+// @Param start_at query string false "RFC 3339 time to start the first phase at, so several instances start together"
+// @Success 201 {object} api.ScenarioResponse
+// @Router /api/scenarios [post]
+func (s *Server) scenarioCreateHandler(w http.ResponseWriter, r *http.Request) {
+	_, span := s.tracer.Start(r.Context(), "scenarioCreateHandler")
+	defer span.End()
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxScenarioSize))
+	if err != nil {
+		s.ErrorResponse(w, r, span, "failed to read scenario", http.StatusBadRequest)
+		return
+	}
+	sc, err := scenario.Parse(data)
+	if err != nil {
+		s.ErrorResponse(w, r, span, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// writing to arbitrary paths on the server is left to the command line
+	for _, st := range sc.Streams {
+		if st.Sink != "" && st.Sink != s.config.LogwildOutFile {
+			s.ErrorResponse(w, r, span, fmt.Sprintf("stream %q can't set a sink through the API", st.Name), http.StatusBadRequest)
+			return
+		}
+	}
+	p := newParams(r)
+	var startAt time.Time
+	p.parse("start_at", func(v string) (err error) {
+		startAt, err = parseStartAt(v)
+		return err
+	})
+	if err := p.err(); err != nil {
+		s.ParamErrorResponse(w, r, span, err)
+		return
+	}
+	run := s.startScenario(sc, startAt)
+	s.JSONResponseCode(w, r, run.status(), http.StatusCreated)
+}
+
+// ScenarioList godoc
+// @Summary List scenario runs
+// @Tags HTTP API
+// @Produce json
+// @Success 200 {array} api.ScenarioResponse
+// @Router /api/scenarios [get]
+func (s *Server) scenarioListHandler(w http.ResponseWriter, r *http.Request) {
+	runs := s.scenarios.list()
+	res := make([]ScenarioResponse, 0, len(runs))
+	for _, run := range runs {
+		res = append(res, run.status())
+	}
+	s.JSONResponse(w, r, res)
+}
+
+// ScenarioGet godoc
+// @Summary Scenario run status
+// @Description reports the current phase of a scenario and what each stream has written
+// @Tags HTTP API
+// @Produce json
+// @Param id path string true "scenario id"
+// @Success 200 {object} api.ScenarioResponse
+// @Router /api/scenarios/{id} [get]
+func (s *Server) scenarioGetHandler(w http.ResponseWriter, r *http.Request) {
+	_, span := s.tracer.Start(r.Context(), "scenarioGetHandler")
+	defer span.End()
+	run := s.scenarios.get(mux.Vars(r)["id"])
+	if run == nil {
+		s.ErrorResponse(w, r, span, "scenario not found", http.StatusNotFound)
+		return
+	}
+	s.JSONResponse(w, r, run.status())
+}
+
+// ScenarioDelete godoc
+// @Summary Stop and remove a scenario run
+// @Description stops the scenario if it is running and reports what each stream wrote
+// @Tags HTTP API
+// @Produce json
+// @Param id path string true "scenario id"
+// @Success 200 {object} api.ScenarioResponse
+// @Router /api/scenarios/{id} [delete]
+func (s *Server) scenarioDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	_, span := s.tracer.Start(r.Context(), "scenarioDeleteHandler")
+	defer span.End()
+	run := s.scenarios.get(mux.Vars(r)["id"])
+	if run == nil {
+		s.ErrorResponse(w, r, span, "scenario not found", http.StatusNotFound)
+		return
+	}
+	run.stop()
+	s.scenarios.remove(run.id)
+	s.JSONResponse(w, r, run.status())
+}
+
+// ScenarioStop godoc
+// @Summary Stop a scenario run
+// @Description stops the scenario if it is running, keeping it for its status, report and sources until it is deleted
+// @Tags HTTP API
+// @Produce json
+// @Param id path string true "scenario id"
+// @Success 200 {object} api.ScenarioResponse
+// @Router /api/scenarios/{id}/stop [post]
+func (s *Server) scenarioStopHandler(w http.ResponseWriter, r *http.Request) {
+	_, span := s.tracer.Start(r.Context(), "scenarioStopHandler")
+	defer span.End()
+	run := s.scenarios.get(mux.Vars(r)["id"])
+	if run == nil {
+		s.ErrorResponse(w, r, span, "scenario not found", http.StatusNotFound)
+		return
+	}
+	run.stop()
+	s.JSONResponse(w, r, run.status())
+}
+
+// ScenarioSources godoc
+// @Summary Scenario run records
+// @Description returns what each stream recorded so far, with its sink, so a coordinator can combine runs on several instances into one report
+// @Tags HTTP API
+// @Produce json
+// @Param id path string true "scenario id"
+// @Success 200 {array} report.Source
+// @Router /api/scenarios/{id}/sources [get]
+func (s *Server) scenarioSourcesHandler(w http.ResponseWriter, r *http.Request) {
+	_, span := s.tracer.Start(r.Context(), "scenarioSourcesHandler")
+	defer span.End()
+	run := s.scenarios.get(mux.Vars(r)["id"])
+	if run == nil {
+		s.ErrorResponse(w, r, span, "scenario not found", http.StatusNotFound)
+		return
+	}
+	s.JSONResponse(w, r, run.runner.Sources())
+}
@@ -0,0 +1,129 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"mcgaunn.com/logwild/pkg/scenario"
+)
+
+const testScenario = `
+name: smoke
+streams:
+  - name: web
+    rate: 200
+  - name: worker
+    rate: 50
+    format: plain
+phases:
+  - name: warmup
+    duration: 100ms
+    transition: linear
+  - name: spike
+    duration: 100ms
+    multiplier: 4
+`
+
+func TestScenarioCanBeRunAndInspected(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = tmpfile.Name()
+	req, err := http.NewRequest("POST", "/api/scenarios", bytes.NewBufferString(testScenario))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(srv.scenarioCreateHandler).ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusCreated {
+		t.Fatalf("handler returned bad status code: got %v want %v: %s", status, http.StatusCreated, rr.Body)
+	}
+	var created ScenarioResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+
+	run := srv.scenarios.get(created.ID)
+	if run == nil {
+		t.Fatalf("expected scenario %s to be registered", created.ID)
+	}
+	select {
+	case <-run.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("scenario did not finish")
+	}
+	rr = serveJobRequest(t, srv.scenarioGetHandler, "GET", "/api/scenarios/"+created.ID, map[string]string{"id": created.ID})
+	var status ScenarioResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.State != JobFinished || status.Phase != "spike" {
+		t.Errorf("expected the scenario to finish in its last phase, got %+v", status)
+	}
+	if status.Streams["web"].LogCount == 0 || status.Streams["worker"].LogCount == 0 {
+		t.Errorf("expected both streams to write logs, got %+v", status.Streams)
+	}
+}
+
+func TestOnlyTheLatestFinishedScenariosAreKept(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = tmpfile.Name()
+	srv.scenarios.keepFinished = 2
+	sc, err := scenario.Parse([]byte("streams: [{name: web, rate: 10}]\nphases: [{duration: 10ms}]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for i := 0; i < 4; i++ {
+		run := srv.startScenario(sc, time.Time{})
+		<-run.done
+		ids = append(ids, run.id)
+	}
+	// the fourth run's finish is only noticed by the next run added
+	last := srv.startScenario(sc, time.Time{})
+	<-last.done
+
+	var kept []string
+	for _, run := range srv.scenarios.list() {
+		kept = append(kept, run.id)
+	}
+	if want := []string{ids[2], ids[3], last.id}; !slices.Equal(kept, want) {
+		t.Errorf("expected the latest finished scenarios %v, got %v", want, kept)
+	}
+}
+
+func TestScenarioCreateRejectsBadScenarios(t *testing.T) {
+	srv := NewMockServer()
+	for name, body := range map[string]string{
+		"invalid": "name: broken\nstreams: []\n",
+		"sink":    "streams: [{name: web, rate: 1, sink: /etc/passwd}]\nphases: [{duration: 1s}]\n",
+	} {
+		req, err := http.NewRequest("POST", "/api/scenarios", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(srv.scenarioCreateHandler).ServeHTTP(rr, req)
+		if len(srv.scenarios.list()) != 0 {
+			t.Errorf("%s: expected the scenario not to start", name)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// startSchedule is when generation starts: right away, once at a set time, or
+// every time a cron schedule comes round.
+type startSchedule struct {
+	at   time.Time
+	cron cron.Schedule
+	spec string
+}
+
+// scheduleFromParams reads the start_at param, an RFC 3339 time, or the
+// schedule param, a five field cron expression or a descriptor such as
+// @every 15m. Instances with synchronised clocks given the same start start
+// within milliseconds of each other.
+func scheduleFromParams(p *params) startSchedule {
+	var ss startSchedule
+	if p.has("start_at") && p.has("schedule") {
+		p.fail("schedule", "give either start_at or schedule, not both")
+		return ss
+	}
+	p.parse("start_at", func(v string) (err error) {
+		ss.at, err = parseStartAt(v)
+		return err
+	})
+	p.parse("schedule", func(v string) (err error) {
+		ss.cron, err = parseCron(v)
+		ss.spec = v
+		return err
+	})
+	return ss
+}
+
+// parseCron reads a schedule param.
+func parseCron(v string) (cron.Schedule, error) {
+	sched, err := cron.ParseStandard(v)
+	if err != nil {
+		return nil, fmt.Errorf("must be a cron expression: %w", err)
+	}
+	return sched, nil
+}
+
+// parseStartAt reads a start_at param.
+func parseStartAt(v string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return time.Time{}, errors.New("must be an RFC 3339 time")
+	}
+	return t, nil
+}
+
+// immediate reports whether there is nothing to wait for.
+func (ss startSchedule) immediate() bool {
+	return ss.at.IsZero() && ss.cron == nil
+}
+
+// recurring reports whether generation starts again after each run.
+func (ss startSchedule) recurring() bool {
+	return ss.cron != nil
+}
+
+// next is the first start after now, which is now itself for an immediate
+// start or a start_at in the past.
+func (ss startSchedule) next(now time.Time) time.Time {
+	switch {
+	case ss.cron != nil:
+		return ss.cron.Next(now)
+	case ss.at.After(now):
+		return ss.at
+	}
+	return now
+}
+
+// String is the schedule as it was given.
+func (ss startSchedule) String() string {
+	switch {
+	case ss.cron != nil:
+		return ss.spec
+	case !ss.at.IsZero():
+		return ss.at.Format(time.RFC3339Nano)
+	}
+	return ""
+}
+
+// sleepUntil waits for t, returning early with ctx's error when it is done
+// first.
+func sleepUntil(ctx context.Context, t time.Time) error {
+	wait := time.Until(t)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// startTimes are the scheduled and actual start of a run, for stats.
+type startTimes struct {
+	// ScheduledStart is when the run was due to start, unset when it
+	// started right away.
+	ScheduledStart *time.Time `json:"scheduled_start,omitempty"`
+	// ActualStart is when it did start.
+	ActualStart *time.Time `json:"actual_start,omitempty"`
+	// StartLagMillis is how late the actual start was.
+	StartLagMillis float64 `json:"start_lag_ms,omitempty"`
+}
+
+func newStartTimes(scheduled, actual time.Time) startTimes {
+	var st startTimes
+	if !actual.IsZero() {
+		st.ActualStart = &actual
+	}
+	if !scheduled.IsZero() {
+		st.ScheduledStart = &scheduled
+		if !actual.IsZero() {
+			st.StartLagMillis = float64(actual.Sub(scheduled)) / float64(time.Millisecond)
+		}
+	}
+	return st
+}
@@ -0,0 +1,152 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScheduledJobWaitsForItsStart(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = tmpfile.Name()
+	startAt := time.Now().Add(300 * time.Millisecond)
+	q := url.Values{"per_second": {"100"}, "burst_dur": {"1"}, "start_at": {startAt.Format(time.RFC3339Nano)}}
+	rr := serveJobRequest(t, srv.jobCreateHandler, "POST", "/api/jobs?"+q.Encode(), nil)
+	var created JobResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.State != JobScheduled || created.NextStart == nil || !created.NextStart.Equal(startAt) || created.Runs != 0 {
+		t.Fatalf("expected a job waiting for its start, got %+v", created)
+	}
+
+	<-srv.jobs.get(created.ID).done
+	done := srv.jobs.get(created.ID).status()
+	if done.State != JobFinished || done.Runs != 1 || done.Stats.LogCount == 0 {
+		t.Fatalf("expected the job to run once, got %+v", done)
+	}
+	if done.ScheduledStart == nil || !done.ScheduledStart.Equal(startAt) || done.ActualStart.Before(startAt) {
+		t.Errorf("expected the job to start at %s, got %+v", startAt, done.startTimes)
+	}
+	if done.StartLagMillis > 50 {
+		t.Errorf("expected the job to start on time, was %vms late", done.StartLagMillis)
+	}
+}
+
+func TestRecurringJobRunsAtEveryStart(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = tmpfile.Name()
+	rr := serveJobRequest(t, srv.jobCreateHandler, "POST", "/api/jobs?per_second=50&burst_dur=1&schedule="+url.QueryEscape("@every 1s"), nil)
+	var created JobResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Schedule != "@every 1s" {
+		t.Fatalf("expected the job to keep its schedule, got %+v", created)
+	}
+	j := srv.jobs.get(created.ID)
+	deadline := time.Now().Add(5 * time.Second)
+	for j.status().Runs < 2 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	j.stop()
+	st := j.status()
+	if st.Runs < 2 || st.State != JobStopped || st.Stats.LogCount < 40 {
+		t.Errorf("expected the job to run again on schedule, got %+v", st)
+	}
+}
+
+func TestBadSchedulesAreRejected(t *testing.T) {
+	srv := NewMockServer()
+	for _, q := range []string{
+		"start_at=tomorrow",
+		"schedule=" + url.QueryEscape("every day"),
+		"start_at=2030-01-01T00:00:00Z&schedule=" + url.QueryEscape("@hourly"),
+		"burst_dur=0&schedule=" + url.QueryEscape("@hourly"),
+	} {
+		rr := serveJobRequest(t, srv.jobCreateHandler, "POST", "/api/jobs?"+q, nil)
+		if !strings.Contains(rr.Body.String(), `"code": 400`) {
+			t.Errorf("expected %s to be rejected, got %s", q, rr.Body.String())
+		}
+	}
+	if len(srv.jobs.list()) != 0 {
+		t.Error("expected no job to be started")
+	}
+}
+
+func TestLogGenStartsAtStartAt(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = tmpfile.Name()
+	startAt := time.Now().Add(200 * time.Millisecond)
+	req, err := http.NewRequest("GET", "/loggen?per_second=10&burst_dur=1&start_at="+url.QueryEscape(startAt.Format(time.RFC3339Nano)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(srv.logGenHandler).ServeHTTP(rr, req)
+	var stats LogStatsResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.ScheduledStart == nil || !stats.ScheduledStart.Equal(startAt) || stats.ActualStart == nil || stats.ActualStart.Before(startAt) {
+		t.Errorf("expected the burst to start at %s, got %+v", startAt, stats)
+	}
+}
+
+func TestScheduledLogGenOutlastsTheWriteTimeout(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = tmpfile.Name()
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(srv.logGenHandler))
+	// stands in for --http-server-timeout, with the start and burst past it
+	ts.Config.WriteTimeout = 200 * time.Millisecond
+	ts.Start()
+	defer ts.Close()
+
+	startAt := time.Now().Add(300 * time.Millisecond)
+	q := url.Values{"per_second": {"100"}, "burst_dur": {"1"}, "start_at": {startAt.Format(time.RFC3339Nano)}}
+	resp, err := http.Get(ts.URL + "/loggen?" + q.Encode())
+	if err != nil {
+		t.Fatalf("expected the response to wait for the burst, got %v", err)
+	}
+	defer resp.Body.Close()
+	var stats LogStatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatalf("expected the burst's stats, got %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || stats.LogCount == 0 || stats.ScheduledStart == nil {
+		t.Errorf("expected the stats of the scheduled burst, got %v %+v", resp.StatusCode, stats)
+	}
+}
@@ -5,16 +5,21 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
-	_ "net/http/pprof"
+	"net/http/pprof"
 	"path"
 	"strings"
 	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/trace"
+
+	"mcgaunn.com/logwild/pkg/metrics"
 )
 
 // @license.name MIT License
@@ -24,29 +29,47 @@ import (
 // @BasePath /
 // @schemes http https
 
-var (
-	healthy int32
-	ready   int32
-)
-
 type Config struct {
-	HttpClientTimeout     time.Duration `mapstructure:"http-client-timeout"`
-	HttpServerTimeout     time.Duration `mapstructure:"http-server-timeout"`
-	ServerShutdownTimeout time.Duration `mapstructure:"server-shutdown-timeout"`
-	BackendURL            []string      `mapstructure:"backend-url"`
-	DataPath              string        `mapstructure:"data-path"`
-	ConfigPath            string        `mapstructure:"config-path"`
-	CertPath              string        `mapstructure:"cert-path"`
-	Host                  string        `mapstructure:"host"`
-	Port                  string        `mapstructure:"port"`
-	SecurePort            string        `mapstructure:"secure-port"`
-	PortMetrics           int           `mapstructure:"port-metrics"`
-	Hostname              string        `mapstructure:"hostname"`
-	Unhealthy             bool          `mapstructure:"unhealthy"`
-	Unready               bool          `mapstructure:"unready"`
-	LogwildPerSecondRate  int64         `mapstructure:"log-rate"`
-	LogwildPerMessageSize int64         `mapstructure:"log-size"`
-	LogwildOutFile        string        `mapstructure:"log-out-file"`
+	HttpClientTimeout        time.Duration `mapstructure:"http-client-timeout"`
+	HttpServerTimeout        time.Duration `mapstructure:"http-server-timeout"`
+	ServerShutdownTimeout    time.Duration `mapstructure:"server-shutdown-timeout"`
+	TelemetryShutdownTimeout time.Duration `mapstructure:"telemetry-shutdown-timeout"`
+	BackendURL               []string      `mapstructure:"backend-url"`
+	DataPath                 string        `mapstructure:"data-path"`
+	ConfigPath               string        `mapstructure:"config-path"`
+	CertPath                 string        `mapstructure:"cert-path"`
+	Host                     string        `mapstructure:"host"`
+	Port                     string        `mapstructure:"port"`
+	SecurePort               string        `mapstructure:"secure-port"`
+	PortMetrics              int           `mapstructure:"port-metrics"`
+	MetricsRuntime           bool          `mapstructure:"metrics-runtime"`
+	EnablePprof              bool          `mapstructure:"enable-pprof"`
+	Hostname                 string        `mapstructure:"hostname"`
+	Unhealthy                bool          `mapstructure:"unhealthy"`
+	Unready                  bool          `mapstructure:"unready"`
+	LogwildPerSecondRate     int64         `mapstructure:"log-rate"`
+	LogwildPerMessageSize    int64         `mapstructure:"log-size"`
+	LogwildOutFile           string        `mapstructure:"log-out-file"`
+	LogwildLevelMix          string        `mapstructure:"log-level-mix"`
+	LogwildFormat            string        `mapstructure:"log-format"`
+	LogwildMultilineFraction float64       `mapstructure:"log-multiline-fraction"`
+	LogwildMultilineFrames   int           `mapstructure:"log-multiline-frames"`
+	LogwildMultilineKinds    string        `mapstructure:"log-multiline-kinds"`
+	LogwildFields            string        `mapstructure:"log-fields"`
+	LogwildTrace             bool          `mapstructure:"log-trace"`
+	LogwildTraceDepth        int           `mapstructure:"log-trace-depth"`
+	LogwildTraceFanout       int           `mapstructure:"log-trace-fanout"`
+	LogwildTraceServices     string        `mapstructure:"log-trace-services"`
+	LogwildSensitiveFraction float64       `mapstructure:"log-sensitive-fraction"`
+	LogwildSensitiveKinds    string        `mapstructure:"log-sensitive-kinds"`
+	LogwildSensitiveManifest string        `mapstructure:"log-sensitive-manifest"`
+	LogwildFuzzFraction      float64       `mapstructure:"log-fuzz-fraction"`
+	LogwildFuzzMix           string        `mapstructure:"log-fuzz-mix"`
+	LogwildFuzzLongLineSize  int           `mapstructure:"log-fuzz-long-line-size"`
+	LogwildContinuous        bool          `mapstructure:"log-continuous"`
+	ReportSLOMinRateRatio    float64       `mapstructure:"report-slo-min-rate-ratio"`
+	ReportSLOMaxP99          time.Duration `mapstructure:"report-slo-max-p99"`
+	ReportSLOMaxErrors       int64         `mapstructure:"report-slo-max-errors"`
 }
 
 type Server struct {
@@ -56,22 +79,50 @@ type Server struct {
 	handler        http.Handler
 	tracer         trace.Tracer
 	tracerProvider *sdktrace.TracerProvider
+	spanProcessor  sdktrace.SpanProcessor
+	meterProvider  *sdkmetric.MeterProvider
+	jobs           jobRegistry
+	scenarios      scenarioRegistry
+	genMetrics     *metrics.Generator
+	// registry holds this server's metrics, so several servers can live in
+	// one process
+	registry *prometheus.Registry
+	// healthy and ready back the probes, set to 1 once the server is up
+	healthy int32
+	ready   int32
 }
 
 func NewServer(config *Config, logger *slog.Logger) (*Server, error) {
 	srv := &Server{
-		router: mux.NewRouter(),
-		logger: logger,
-		config: config,
+		router:   mux.NewRouter(),
+		logger:   logger,
+		config:   config,
+		registry: newRegistry(config),
 	}
 
 	return srv, nil
 }
 
+// newRegistry creates a metrics registry, with the Go runtime and process
+// collectors when they were asked for.
+func newRegistry(config *Config) *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	if config.MetricsRuntime {
+		reg.MustRegister(
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		)
+	}
+	return reg
+}
+
 func (s *Server) registerHandlers() {
-	s.router.Handle("/metrics", promhttp.Handler())
+	s.router.Handle("/metrics", s.metricsHandler())
+	if s.config.EnablePprof {
+		s.router.PathPrefix("/debug/pprof/").Handler(pprofHandler())
+	}
 	s.router.HandleFunc("/", s.infoHandler).Methods("GET")
-	s.router.HandleFunc("/loggen", s.logGenHandler).Methods("GET")
+	s.router.HandleFunc("/loggen", s.logGenHandler).Methods("GET", "POST")
 	s.router.HandleFunc("/version", s.versionHandler).Methods("GET")
 	s.router.HandleFunc("/env", s.envHandler).Methods("GET", "POST")
 	s.router.HandleFunc("/healthz", s.healthzHandler).Methods("GET")
@@ -79,10 +130,37 @@ func (s *Server) registerHandlers() {
 	s.router.HandleFunc("/readyz/enable", s.enableReadyHandler).Methods("POST")
 	s.router.HandleFunc("/readyz/disable", s.disableReadyHandler).Methods("POST")
 	s.router.HandleFunc("/api/info", s.infoHandler).Methods("GET")
+	s.router.HandleFunc("/api/replay", s.replayHandler).Methods("POST")
+	s.router.HandleFunc("/api/sample", s.sampleHandler).Methods("GET")
+	s.router.HandleFunc("/api/jobs", s.jobCreateHandler).Methods("POST")
+	s.router.HandleFunc("/api/jobs", s.jobListHandler).Methods("GET")
+	s.router.HandleFunc("/api/jobs/{id}", s.jobGetHandler).Methods("GET")
+	s.router.HandleFunc("/api/jobs/{id}", s.jobUpdateHandler).Methods("PATCH")
+	s.router.HandleFunc("/api/jobs/{id}", s.jobDeleteHandler).Methods("DELETE")
+	s.router.HandleFunc("/api/jobs/{id}/report", s.jobReportHandler).Methods("GET")
+	s.router.HandleFunc("/api/jobs/{id}/events", s.jobEventsHandler).Methods("GET")
+	s.router.HandleFunc("/api/jobs/{id}/tail", s.jobTailHandler).Methods("GET")
+	s.router.HandleFunc("/api/continuous", s.jobGetHandler).Methods("GET")
+	s.router.HandleFunc("/api/continuous", s.jobUpdateHandler).Methods("PATCH")
+	s.router.HandleFunc("/api/continuous/report", s.jobReportHandler).Methods("GET")
+	s.router.HandleFunc("/api/continuous/events", s.jobEventsHandler).Methods("GET")
+	s.router.HandleFunc("/api/continuous/tail", s.jobTailHandler).Methods("GET")
+	s.router.HandleFunc("/api/scenarios", s.scenarioCreateHandler).Methods("POST")
+	s.router.HandleFunc("/api/scenarios", s.scenarioListHandler).Methods("GET")
+	s.router.HandleFunc("/api/scenarios/{id}", s.scenarioGetHandler).Methods("GET")
+	s.router.HandleFunc("/api/scenarios/{id}", s.scenarioDeleteHandler).Methods("DELETE")
+	s.router.HandleFunc("/api/scenarios/{id}/report", s.scenarioReportHandler).Methods("GET")
+	s.router.HandleFunc("/api/scenarios/{id}/sources", s.scenarioSourcesHandler).Methods("GET")
+	s.router.HandleFunc("/api/scenarios/{id}/stop", s.scenarioStopHandler).Methods("POST")
+	s.router.HandleFunc("/api/presets", s.presetListHandler).Methods("GET")
+	s.router.HandleFunc("/api/presets/{name}", s.presetGetHandler).Methods("GET")
+	s.router.HandleFunc("/api/presets/{name}", s.presetRunHandler).Methods("POST")
+	s.router.HandleFunc("/api/openapi.json", s.openAPIHandler).Methods("GET")
+	s.router.HandleFunc("/api/schemas/generation-spec.json", s.generationSpecSchemaHandler).Methods("GET")
 }
 
 func (s *Server) registerMiddlewares() {
-	prom := NewPrometheusMiddleware()
+	prom := NewPrometheusMiddleware(s.registry)
 	s.router.Use(prom.Handler)
 	otel := NewOpenTelemetryMiddleware()
 	s.router.Use(otel)
@@ -97,6 +175,8 @@ func (s *Server) ListenAndServe() (*http.Server, *int32, *int32) {
 	go s.startMetricsServer()
 
 	s.initTracer(ctx)
+	s.genMetrics = metrics.NewGenerator(s.registry)
+	s.initMeter(ctx, s.registry)
 	s.registerHandlers()
 	s.registerMiddlewares()
 
@@ -108,18 +188,25 @@ func (s *Server) ListenAndServe() (*http.Server, *int32, *int32) {
 		s.config.LogwildOutFile = "-"
 	}
 
+	// start generating right away when asked to, instead of waiting for /loggen
+	if s.config.LogwildContinuous {
+		if err := s.startContinuous(); err != nil {
+			s.logger.Error("failed to start continuous log generation", "err", err)
+		}
+	}
+
 	// create the http server
 	srv := s.startServer()
 
 	// signal Kubernetes the server is ready to receive traffic
 	if !s.config.Unhealthy {
-		atomic.StoreInt32(&healthy, 1)
+		atomic.StoreInt32(&s.healthy, 1)
 	}
 	if !s.config.Unready {
-		atomic.StoreInt32(&ready, 1)
+		atomic.StoreInt32(&s.ready, 1)
 	}
 
-	return srv, &healthy, &ready
+	return srv, &s.healthy, &s.ready
 }
 
 func (s *Server) startServer() *http.Server {
@@ -183,8 +270,11 @@ func (s *Server) startSecureServer() *http.Server {
 
 func (s *Server) startMetricsServer() {
 	if s.config.PortMetrics > 0 {
-		mux := http.DefaultServeMux
-		mux.Handle("/metrics", promhttp.Handler())
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", s.metricsHandler())
+		if s.config.EnablePprof {
+			mux.Handle("/debug/pprof/", pprofHandler())
+		}
 		mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusOK)
 			w.Write([]byte("OK"))
@@ -199,6 +289,23 @@ func (s *Server) startMetricsServer() {
 	}
 }
 
+// metricsHandler serves the metrics in this server's registry.
+func (s *Server) metricsHandler() http.Handler {
+	return promhttp.InstrumentMetricHandler(s.registry, promhttp.HandlerFor(s.registry, promhttp.HandlerOpts{}))
+}
+
+// pprofHandler serves the net/http/pprof profiles under /debug/pprof/,
+// without relying on http.DefaultServeMux.
+func pprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
 func (s *Server) printRoutes() {
 	s.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
 		pathTemplate, err := route.GetPathTemplate()
@@ -0,0 +1,101 @@
+package http
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"mcgaunn.com/logwild/pkg/metrics"
+)
+
+func TestServersKeepTheirOwnMetrics(t *testing.T) {
+	servers := []*Server{NewMockServer(), NewMockServer()}
+	servers[1].config.MetricsRuntime = true
+	servers[1].config.EnablePprof = true
+	servers[1].registry = newRegistry(servers[1].config)
+	for _, srv := range servers {
+		// would panic on duplicate registration with a shared registry
+		srv.genMetrics = metrics.NewGenerator(srv.registry)
+		srv.registerHandlers()
+		srv.registerMiddlewares()
+	}
+	servers[0].genMetrics.JobStarted(metrics.KindJob)
+
+	scrape := func(srv *Server, path string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		srv.router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := scrape(servers[0], "/metrics").Body.String()
+	if !strings.Contains(first, `logwild_active_jobs{kind="job"} 1`) {
+		t.Errorf("expected the first server to report its active job, got:\n%s", first)
+	}
+	if strings.Contains(first, "go_goroutines") {
+		t.Error("expected no runtime metrics unless enabled")
+	}
+	second := scrape(servers[1], "/metrics").Body.String()
+	if strings.Contains(second, `logwild_active_jobs{kind="job"} 1`) {
+		t.Error("expected the second server not to see the first server's jobs")
+	}
+	if !strings.Contains(second, "go_goroutines") {
+		t.Error("expected runtime metrics when enabled")
+	}
+
+	if status := scrape(servers[0], "/debug/pprof/").Code; status == http.StatusOK {
+		t.Error("expected pprof to be off by default")
+	}
+	if status := scrape(servers[1], "/debug/pprof/").Code; status != http.StatusOK {
+		t.Errorf("expected pprof to be served when enabled, got %d", status)
+	}
+}
+
+func TestServersKeepTheirOwnProbes(t *testing.T) {
+	newServer := func(unready bool) *Server {
+		srv, err := NewServer(&Config{Port: "0", SecurePort: "0", Unready: unready}, slog.Default())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return srv
+	}
+	up, down := newServer(false), newServer(true)
+	// Port 0 starts no listener, so only the probe state is set up
+	if _, healthy, ready := up.ListenAndServe(); *healthy != 1 || *ready != 1 {
+		t.Fatalf("expected the server to be healthy and ready, got %d and %d", *healthy, *ready)
+	}
+	down.ListenAndServe()
+
+	probe := func(srv *Server, method, path string) int {
+		req, err := http.NewRequest(method, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		srv.router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	if status := probe(up, "GET", "/readyz"); status != http.StatusOK {
+		t.Errorf("expected the first server to be ready, got %d", status)
+	}
+	if status := probe(down, "GET", "/readyz"); status != http.StatusServiceUnavailable {
+		t.Errorf("expected the unready server not to be ready, got %d", status)
+	}
+	if status := probe(down, "GET", "/healthz"); status != http.StatusOK {
+		t.Errorf("expected the unready server to be healthy, got %d", status)
+	}
+
+	probe(up, "POST", "/readyz/disable")
+	probe(down, "POST", "/readyz/enable")
+	if status := probe(up, "GET", "/readyz"); status != http.StatusServiceUnavailable {
+		t.Errorf("expected the first server to stop being ready, got %d", status)
+	}
+	if status := probe(down, "GET", "/readyz"); status != http.StatusOK {
+		t.Errorf("expected the second server to become ready, got %d", status)
+	}
+}
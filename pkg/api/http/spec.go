@@ -0,0 +1,331 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"mcgaunn.com/logwild/pkg/logmaker"
+	"mcgaunn.com/logwild/pkg/scenario"
+)
+
+// maxSpecSize bounds a GenerationSpec body.
+const maxSpecSize = 1 << 20
+
+// GenerationSpec is the JSON body of POST /loggen and POST /api/jobs. It
+// covers every logmaker.Opts setting that makes sense over the API, with
+// durations as Go duration strings such as 90s or 1m30s. Anything left out
+// keeps its preset or configured value. Its JSON Schema is
+// components.schemas.GenerationSpec of the OpenAPI document.
+type GenerationSpec struct {
+	// Preset names a built-in workload to take defaults from.
+	Preset string `json:"preset,omitempty"`
+	Format string `json:"format,omitempty"`
+	// Sink can only be the configured output file, as writing to arbitrary
+	// paths on the server is left to the command line.
+	Sink          string               `json:"sink,omitempty"`
+	PerSecond     *int64               `json:"per_second,omitempty"`
+	MessageSize   *int64               `json:"message_size,omitempty"`
+	BurstDuration string               `json:"burst_duration,omitempty"`
+	MaxInFlight   *int                 `json:"max_in_flight,omitempty"`
+	LevelMix      *logmaker.LevelMix   `json:"level_mix,omitempty"`
+	Fields        []logmaker.FieldSpec `json:"fields,omitempty"`
+	// Multiline, Fuzz, Traces and Sensitive start from the configured
+	// settings, so an object only needs the keys it changes.
+	Multiline *logmaker.MultilineOpts `json:"multiline,omitempty"`
+	Fuzz      *logmaker.FuzzOpts      `json:"fuzz,omitempty"`
+	Traces    *logmaker.TraceOpts     `json:"traces,omitempty"`
+	Sensitive *logmaker.SensitiveOpts `json:"sensitive,omitempty"`
+	StartAt   *time.Time              `json:"start_at,omitempty"`
+	Schedule  string                  `json:"schedule,omitempty"`
+}
+
+// generation is what a request asks to generate, read from its query params
+// or its GenerationSpec body.
+type generation struct {
+	format    string
+	sched     startSchedule
+	optFuncs  []logmaker.OptFunc
+	sensitive logmaker.SensitiveOpts
+	// burst is the burst duration asked for, nil when it wasn't
+	burst *time.Duration
+}
+
+// hasSpec reports whether r carries a GenerationSpec rather than query params.
+func hasSpec(r *http.Request) bool {
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return r.Method == http.MethodPost && mt == "application/json"
+}
+
+// queryGeneration reads a generation from the query params, recording any
+// that are bad in p.
+func (s *Server) queryGeneration(r *http.Request, p *params) generation {
+	gen := generation{
+		format:    s.outputFormat(p),
+		sched:     scheduleFromParams(p),
+		optFuncs:  s.buildLoggerOptionsFromQueryParams(r, p),
+		sensitive: s.sensitiveOpts(p),
+	}
+	if seconds, ok := p.int("burst_dur", 0, maxBurstDuration); ok {
+		d := time.Duration(seconds) * time.Second
+		gen.burst = &d
+		gen.optFuncs = append(gen.optFuncs, logmaker.WithBurstDuration(d))
+	}
+	return gen
+}
+
+// specGeneration reads a generation from the GenerationSpec in r's body,
+// recording what is wrong with it in p under the JSON names of its settings.
+func (s *Server) specGeneration(r *http.Request, p *params) generation {
+	gen := generation{format: s.config.LogwildFormat, sensitive: s.sensitiveOptsFromConfig()}
+	spec, ok := s.readSpec(r, p)
+	if !ok {
+		return gen
+	}
+
+	// a preset sets defaults that the rest of the spec can still override
+	if spec.Preset != "" {
+		if !slices.Contains(scenario.PresetNames(), spec.Preset) {
+			p.fail("preset", "must be one of %s, got %q", strings.Join(scenario.PresetNames(), ", "), spec.Preset)
+		} else if st, err := singleStream(scenario.Preset(spec.Preset)); err != nil {
+			p.fail("preset", "%s", err)
+		} else {
+			gen.optFuncs = append(gen.optFuncs, st.LogMakerOpts()...)
+			if st.Format != "" {
+				gen.format = st.Format
+			}
+		}
+	}
+	if spec.Format != "" {
+		if !slices.Contains(logmaker.Formats, spec.Format) {
+			p.fail("format", "must be one of %s, got %q", strings.Join(logmaker.Formats, ", "), spec.Format)
+		}
+		gen.format = spec.Format
+	}
+	if spec.Sink != "" && spec.Sink != s.config.LogwildOutFile {
+		p.fail("sink", "can only be the configured output file %s", s.config.LogwildOutFile)
+	}
+	if spec.PerSecond != nil {
+		if *spec.PerSecond < 1 || *spec.PerSecond > maxPerSecond {
+			p.fail("per_second", "must be between 1 and %d, got %d", maxPerSecond, *spec.PerSecond)
+		}
+		gen.optFuncs = append(gen.optFuncs, logmaker.WithPerSecondRate(*spec.PerSecond))
+	}
+	if spec.MessageSize != nil {
+		if *spec.MessageSize < 1 || *spec.MessageSize > maxMessageSize {
+			p.fail("message_size", "must be between 1 and %d, got %d", maxMessageSize, *spec.MessageSize)
+		}
+		gen.optFuncs = append(gen.optFuncs, logmaker.WithPerMessageSize(*spec.MessageSize))
+	}
+	if spec.BurstDuration != "" {
+		d, err := time.ParseDuration(spec.BurstDuration)
+		switch {
+		case err != nil:
+			p.fail("burst_duration", "must be a duration such as 90s or 1m30s, got %q", spec.BurstDuration)
+		case d < 0 || d > maxBurstDuration*time.Second:
+			p.fail("burst_duration", "must be between 0s and %s, got %s", maxBurstDuration*time.Second, d)
+		default:
+			gen.burst = &d
+			gen.optFuncs = append(gen.optFuncs, logmaker.WithBurstDuration(d))
+		}
+	}
+	if spec.MaxInFlight != nil {
+		if *spec.MaxInFlight < 0 {
+			p.fail("max_in_flight", "must be 0, for no limit, or more, got %d", *spec.MaxInFlight)
+		}
+		gen.optFuncs = append(gen.optFuncs, logmaker.WithMaxInFlight(*spec.MaxInFlight))
+	}
+	if mix := spec.LevelMix; mix != nil {
+		weights := []int{mix.Debug, mix.Info, mix.Warn, mix.Error, mix.Fatal}
+		if slices.Min(weights) < 0 {
+			p.fail("level_mix", "weights must not be negative")
+		} else if mix.Debug+mix.Info+mix.Warn+mix.Error+mix.Fatal == 0 {
+			p.fail("level_mix", "must give at least one level a weight")
+		}
+		gen.optFuncs = append(gen.optFuncs, logmaker.WithLevelMix(*mix))
+	}
+	if spec.Fields != nil {
+		for i, f := range spec.Fields {
+			name := fmt.Sprintf("fields[%d]", i)
+			switch {
+			case f.Name == "":
+				p.fail(name+".name", "must not be empty")
+			case len(f.Values) == 0 && f.Cardinality < 1:
+				p.fail(name+".cardinality", "must be at least 1, got %d", f.Cardinality)
+			case len(f.Values) > 0 && f.Cardinality != 0 && f.Cardinality != len(f.Values):
+				p.fail(name+".cardinality", "must be left out or match the %d values, got %d", len(f.Values), f.Cardinality)
+			case f.Kind != "" && len(f.Values) > 0:
+				p.fail(name+".kind", "can't be used along with values")
+			case f.Kind != "" && !slices.Contains(logmaker.FieldKinds, f.Kind):
+				p.fail(name+".kind", "must be one of %s, got %q", strings.Join(logmaker.FieldKinds, ", "), f.Kind)
+			case f.Distribution != "" && !slices.Contains(logmaker.Distributions, f.Distribution):
+				p.fail(name+".distribution", "must be one of %s, got %q", strings.Join(logmaker.Distributions, ", "), f.Distribution)
+			}
+		}
+		gen.optFuncs = append(gen.optFuncs, logmaker.WithFields(spec.Fields))
+	}
+	if ml := spec.Multiline; ml != nil {
+		checkFraction(p, "multiline.fraction", ml.Fraction)
+		if ml.Frames < 1 || ml.Frames > maxMultilineFrames {
+			p.fail("multiline.frames", "must be between 1 and %d, got %d", maxMultilineFrames, ml.Frames)
+		}
+		checkKinds(p, "multiline.kinds", ml.Kinds, logmaker.StackTraceKinds)
+		gen.optFuncs = append(gen.optFuncs, logmaker.WithMultiline(*ml))
+	}
+	if fo := spec.Fuzz; fo != nil {
+		checkFraction(p, "fuzz.fraction", fo.Fraction)
+		for kind, w := range fo.Mix {
+			if !slices.Contains(logmaker.FuzzKinds, kind) {
+				p.fail("fuzz.mix", "keys must be among %s, got %q", strings.Join(logmaker.FuzzKinds, ", "), kind)
+			} else if w < 0 {
+				p.fail("fuzz.mix", "weight for %q must not be negative", kind)
+			}
+		}
+		if fo.LongLineSize < 1 || fo.LongLineSize > maxLongLineSize {
+			p.fail("fuzz.long_line_size", "must be between 1 and %d, got %d", maxLongLineSize, fo.LongLineSize)
+		}
+		gen.optFuncs = append(gen.optFuncs, logmaker.WithFuzz(*fo))
+	}
+	if to := spec.Traces; to != nil {
+		if to.Depth < 1 || to.Depth > maxTraceDepth {
+			p.fail("traces.depth", "must be between 1 and %d, got %d", maxTraceDepth, to.Depth)
+		}
+		if to.Fanout < 1 || to.Fanout > maxTraceFanout {
+			p.fail("traces.fanout", "must be between 1 and %d, got %d", maxTraceFanout, to.Fanout)
+		}
+		gen.optFuncs = append(gen.optFuncs, logmaker.WithTraces(*to))
+	}
+	if so := spec.Sensitive; so != nil {
+		checkFraction(p, "sensitive.fraction", so.Fraction)
+		checkKinds(p, "sensitive.kinds", so.Kinds, logmaker.SensitiveKinds)
+		gen.sensitive = *so
+	}
+
+	if spec.StartAt != nil && spec.Schedule != "" {
+		p.fail("schedule", "give either start_at or schedule, not both")
+	} else if spec.StartAt != nil {
+		gen.sched.at = *spec.StartAt
+	} else if spec.Schedule != "" {
+		sched, err := parseCron(spec.Schedule)
+		if err != nil {
+			p.fail("schedule", "%s", err)
+		}
+		gen.sched = startSchedule{cron: sched, spec: spec.Schedule}
+	}
+	return gen
+}
+
+// readSpec decodes the GenerationSpec in r's body. Its multiline, fuzz,
+// traces and sensitive objects are laid over the configured settings.
+func (s *Server) readSpec(r *http.Request, p *params) (GenerationSpec, bool) {
+	var spec GenerationSpec
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxSpecSize+1))
+	if err != nil {
+		p.fail("body", "could not be read: %s", err)
+		return spec, false
+	}
+	if len(data) > maxSpecSize {
+		p.fail("body", "must be at most %d bytes", maxSpecSize)
+		return spec, false
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&spec); err != nil {
+		failDecode(p, err)
+		return spec, false
+	}
+
+	// decoding again onto the configured settings keeps those the body
+	// leaves out
+	fuzz := s.fuzzOptsFromConfig()
+	mix := fuzz.Mix
+	fuzz.Mix = nil
+	base := GenerationSpec{
+		Multiline: &logmaker.MultilineOpts{Fraction: s.config.LogwildMultilineFraction, Frames: s.config.LogwildMultilineFrames},
+		Fuzz:      &fuzz,
+		Traces:    ptr(s.traceOptsFromConfig()),
+		Sensitive: ptr(s.sensitiveOptsFromConfig()),
+	}
+	base.Multiline.Kinds, _ = logmaker.ParseStackTraceKinds(s.config.LogwildMultilineKinds)
+	if err := json.Unmarshal(data, &base); err != nil {
+		failDecode(p, err)
+		return spec, false
+	}
+	if base.Fuzz.Mix == nil {
+		base.Fuzz.Mix = mix
+	}
+	if spec.Multiline != nil {
+		spec.Multiline = base.Multiline
+	}
+	if spec.Fuzz != nil {
+		spec.Fuzz = base.Fuzz
+	}
+	if spec.Traces != nil {
+		spec.Traces = base.Traces
+	}
+	if spec.Sensitive != nil {
+		spec.Sensitive = base.Sensitive
+	}
+	return spec, true
+}
+
+// failDecode records why a body couldn't be decoded, against the setting at
+// fault when it is known.
+func failDecode(p *params, err error) {
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		p.fail(typeErr.Field, "must be a %s, got a %s", jsonType(typeErr.Type.Kind().String()), typeErr.Value)
+	case errors.As(err, &syntaxErr):
+		p.fail("body", "must be JSON: %s at byte %d", syntaxErr, syntaxErr.Offset)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		p.fail(field, "is not a generation setting")
+	case errors.Is(err, io.EOF):
+		p.fail("body", "must be a generation spec, got nothing")
+	default:
+		p.fail("body", "%s", err)
+	}
+}
+
+// jsonType names a Go kind as its JSON type.
+func jsonType(kind string) string {
+	switch {
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"), strings.HasPrefix(kind, "float"):
+		return "number"
+	case kind == "struct", kind == "map", kind == "ptr":
+		return "object"
+	case kind == "slice":
+		return "array"
+	case kind == "bool":
+		return "boolean"
+	}
+	return kind
+}
+
+func checkFraction(p *params, name string, f float64) {
+	if f < 0 || f > 1 {
+		p.fail(name, "must be between 0 and 1, got %g", f)
+	}
+}
+
+func checkKinds(p *params, name string, kinds, known []string) {
+	for _, kind := range kinds {
+		if !slices.Contains(known, kind) {
+			p.fail(name, "must be among %s, got %q", strings.Join(known, ", "), kind)
+			return
+		}
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,147 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func serveSpecRequest(t *testing.T, handler http.HandlerFunc, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req, err := http.NewRequest("POST", target, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestLogGenTakesASpec(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = tmpfile.Name()
+	rr := serveSpecRequest(t, srv.logGenHandler, "/loggen", `{
+		"format": "json",
+		"sink": "`+tmpfile.Name()+`",
+		"per_second": 20,
+		"burst_duration": "1s",
+		"level_mix": {"error": 1},
+		"fields": [{"name": "tenant", "cardinality": 3, "distribution": "sequential"}]
+	}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned bad status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var stats LogStatsResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.LogCount == 0 || stats.Levels["error"] != int64(stats.LogCount) {
+		t.Errorf("expected only errors to be written, got %+v", stats)
+	}
+	content, err := os.ReadFile(tmpfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), `"tenant":"tenant-0"`) {
+		t.Errorf("expected fields in output, got:\n%s", content)
+	}
+}
+
+func TestJobsCanBeCreatedFromASpec(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = tmpfile.Name()
+	defer srv.StopJobs()
+	// traces only turns them on, keeping the configured depth and fanout
+	rr := serveSpecRequest(t, srv.jobCreateHandler, "/api/jobs", `{"preset": "nginx-web-tier", "per_second": 200, "burst_duration": "0s", "traces": {"enabled": true}}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("handler returned bad status code: got %v want %v: %s", rr.Code, http.StatusCreated, rr.Body.String())
+	}
+	var created JobResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.State != JobRunning || created.PerSecond != 200 || created.Format != "ecs" {
+		t.Errorf("expected a running job with the preset's format and the spec's rate, got %+v", created)
+	}
+}
+
+func TestSpecsOnlyValidateTheFuzzSettingsTheyGive(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = tmpfile.Name()
+	// as when the config file leaves it out
+	srv.config.LogwildFuzzLongLineSize = 0
+	rr := serveSpecRequest(t, srv.logGenHandler, "/loggen", `{"per_second": 10, "burst_duration": "1s", "fuzz": {"fraction": 0.5}}`)
+	if rr.Code != http.StatusOK {
+		t.Errorf("handler returned bad status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+}
+
+func TestBadSpecsAreRejected(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = tmpfile.Name()
+	for _, tc := range []struct {
+		handler http.HandlerFunc
+		body    string
+		field   string
+	}{
+		{srv.jobCreateHandler, `{"per_second": "fast"}`, "per_second"},
+		{srv.jobCreateHandler, `{"burst_duration": "10"}`, "burst_duration"},
+		{srv.jobCreateHandler, `{"rate": 5}`, "rate"},
+		{srv.jobCreateHandler, `{"sink": "/etc/passwd"}`, "sink"},
+		{srv.jobCreateHandler, `{"preset": "nope"}`, "preset"},
+		{srv.jobCreateHandler, `{"fields": [{"name": "a", "cardinality": 0}]}`, "fields[0].cardinality"},
+		{srv.jobCreateHandler, `{"fields": [{"name": "a", "cardinality": 5, "kind": "mac"}]}`, "fields[0].kind"},
+		{srv.jobCreateHandler, `{"fields": [{"name": "a", "cardinality": 3, "values": ["x", "y"]}]}`, "fields[0].cardinality"},
+		{srv.jobCreateHandler, `{"multiline": {"frames": 0}}`, "multiline.frames"},
+		{srv.jobCreateHandler, `{"fuzz": {"mix": {"gremlins": 1}}}`, "fuzz.mix"},
+		{srv.jobCreateHandler, `{"level_mix": {}}`, "level_mix"},
+		{srv.jobCreateHandler, `{"schedule": "@every 1m", "burst_duration": "0s"}`, "burst_duration"},
+		{srv.jobCreateHandler, `{`, "body"},
+		{srv.logGenHandler, `{"burst_duration": "0s"}`, "burst_duration"},
+	} {
+		rr := serveSpecRequest(t, tc.handler, "/api/jobs", tc.body)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected %s to be rejected, got %v", tc.body, rr.Code)
+			continue
+		}
+		var body ErrorResponseBody
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if len(body.Fields) != 1 || body.Fields[0].Field != tc.field {
+			t.Errorf("expected %s to be reported for %s, got %+v", tc.field, tc.body, body.Fields)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"mcgaunn.com/logwild/pkg/logmaker"
+)
+
+// tailBuffer is how many lines a tail holds for a slow client before it
+// starts missing some.
+const tailBuffer = 1024
+
+// minTailSample is the smallest fraction of lines a tail can ask for.
+const minTailSample = 1e-6
+
+// tailFilterFromParams reads the sample, level and field params of a tail.
+// field is key=value and can be given more than once.
+func tailFilterFromParams(p *params) logmaker.TapFilter {
+	f := logmaker.TapFilter{Sample: 1}
+	if sample, ok := p.float("sample", minTailSample, 1); ok {
+		f.Sample = sample
+	}
+	p.parse("level", func(v string) error {
+		for _, name := range strings.Split(v, ",") {
+			l, err := logmaker.ParseLevel(name)
+			if err != nil {
+				return err
+			}
+			f.Levels = append(f.Levels, l)
+		}
+		return nil
+	})
+	for _, field := range p.all("field") {
+		key, val, ok := strings.Cut(field, "=")
+		if !ok || key == "" {
+			p.fail("field", "must be key=value, got %q", field)
+			continue
+		}
+		if f.Fields == nil {
+			f.Fields = map[string]string{}
+		}
+		f.Fields[key] = val
+	}
+	return f
+}
+
+// JobTail godoc
+// @Summary Tail the output of a log generation job
+// @Description streams a sampled copy of the lines a job writes, exactly as encoded for its sink, until the job ends. lines the client can't keep up with are skipped rather than slowing the job down
+// @Tags HTTP API
+// @Produce plain
+// @Param id path string true "job id"
+// @Param sample query number false "fraction of lines to stream, 1 by default"
+// @Param level query string false "comma separated levels to stream, e.g. error,fatal"
+// @Param field query string false "key=value a line's fields must include, can be repeated"
+// @Success 200 {string} string
+// @Router /api/jobs/{id}/tail [get]
+func (s *Server) jobTailHandler(w http.ResponseWriter, r *http.Request) {
+	_, span := s.tracer.Start(r.Context(), "jobTailHandler")
+	defer span.End()
+	j := s.jobs.get(jobID(r))
+	if j == nil {
+		s.ErrorResponse(w, r, span, "job not found", http.StatusNotFound)
+		return
+	}
+	p := newParams(r)
+	filter := tailFilterFromParams(p)
+	if err := p.err(); err != nil {
+		s.ParamErrorResponse(w, r, span, err)
+		return
+	}
+	sub := j.tap.Subscribe(filter, tailBuffer)
+	defer func() {
+		sub.Close()
+		s.logger.Info("tail ended", "job", j.id, "missed", sub.Missed())
+	}()
+
+	rc := http.NewResponseController(w)
+	// like the event stream, a tail lasts as long as the job
+	_ = rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	rc.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-j.done:
+			// what was written before the job ended is still worth seeing
+			for len(sub.Lines()) > 0 {
+				w.Write(<-sub.Lines())
+			}
+			rc.Flush()
+			return
+		case line := <-sub.Lines():
+			if _, err := w.Write(line); err != nil {
+				return
+			}
+			// flush once caught up, rather than for every line
+			if len(sub.Lines()) == 0 {
+				if err := rc.Flush(); err != nil {
+					s.logger.Debug("tail flush failed", "job", j.id, "err", err)
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package http
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestJobTailStreamsFilteredLines(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = tmpfile.Name()
+	srv.registerHandlers()
+	ts := httptest.NewServer(srv.router)
+	defer ts.Close()
+	rr := serveJobRequest(t, srv.jobCreateHandler, "POST", "/api/jobs?per_second=200&burst_dur=1&format=ecs&level_mix=1/1/1/1&fields=tenant:2", nil)
+	var created JobResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Get(ts.URL + "/api/jobs/" + created.ID + "/tail?level=error,warn&field=tenant=tenant-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var lines []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if len(lines) == 0 {
+		t.Fatal("expected tailed lines")
+	}
+	content, err := os.ReadFile(tmpfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range lines {
+		if !strings.Contains(string(content), line+"\n") {
+			t.Errorf("expected a line written to the sink, got %s", line)
+		}
+		level := strings.Contains(line, `"log.level":"error"`) || strings.Contains(line, `"log.level":"warn"`)
+		if !level || !strings.Contains(line, `"tenant":"tenant-1"`) {
+			t.Errorf("expected a warn or error line for tenant-1, got %s", line)
+		}
+	}
+
+	rr = serveJobRequest(t, srv.jobTailHandler, "GET", "/api/jobs/"+created.ID+"/tail?sample=2", map[string]string{"id": created.ID})
+	if !strings.Contains(rr.Body.String(), `"code": 400`) {
+		t.Errorf("expected a bad sample to be rejected, got %s", rr.Body.String())
+	}
+}
@@ -37,13 +37,12 @@ func (s *Server) initTracer(ctx context.Context) {
 		s.logger.Error("creating OTLP trace exporter", "err", err)
 	}
 
+	// synthetic traces share the processor, so shutting the provider down
+	// flushes them too
+	s.spanProcessor = sdktrace.NewBatchSpanProcessor(exporter)
 	s.tracerProvider = sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter),
-		sdktrace.WithResource(resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceNameKey.String(viper.GetString("otel-service-name")),
-			semconv.ServiceVersionKey.String(version.VERSION),
-		)),
+		sdktrace.WithSpanProcessor(s.spanProcessor),
+		sdktrace.WithResource(otelResource()),
 	)
 
 	otel.SetTracerProvider(s.tracerProvider)
@@ -61,6 +60,16 @@ func (s *Server) initTracer(ctx context.Context) {
 	)
 }
 
+// otelResource describes logwild to OpenTelemetry, the same way for traces and
+// metrics.
+func otelResource() *resource.Resource {
+	return resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceNameKey.String(viper.GetString("otel-service-name")),
+		semconv.ServiceVersionKey.String(version.VERSION),
+	)
+}
+
 func NewOpenTelemetryMiddleware() mux.MiddlewareFunc {
 	return otelmux.Middleware(viper.GetString("otel-service-name"))
 }
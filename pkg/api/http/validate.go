@@ -0,0 +1,188 @@
+package http
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// limits on generation params, generous enough for any real load but tight
+// enough to catch a typo such as a few extra zeros
+const (
+	maxPerSecond       = 10_000_000
+	maxMessageSize     = 100_000
+	maxBurstDuration   = 7 * 24 * 60 * 60
+	maxMultilineFrames = 1000
+	maxLongLineSize    = 64 << 20
+	maxTraceDepth      = 32
+	maxTraceFanout     = 32
+	minReplaySpeed     = 0.001
+	maxReplaySpeed     = 1000
+)
+
+// FieldError is what is wrong with one request param.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every bad param of a request.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (ve *ValidationError) Error() string {
+	msgs := make([]string, 0, len(ve.Fields))
+	for _, fe := range ve.Fields {
+		msgs = append(msgs, fe.Field+": "+fe.Message)
+	}
+	return "invalid params: " + strings.Join(msgs, "; ")
+}
+
+// params reads a request's query params, checking each against its limits
+// and collecting what is wrong, so a request learns about every bad param at
+// once rather than having it ignored. It remembers which params a handler
+// looked at, so that err can report the ones it didn't know, such as a typo.
+type params struct {
+	q    url.Values
+	read map[string]bool
+	errs []FieldError
+}
+
+func newParams(r *http.Request) *params {
+	return &params{q: r.URL.Query(), read: map[string]bool{}}
+}
+
+func (p *params) get(name string) string {
+	p.read[name] = true
+	return p.q.Get(name)
+}
+
+// all returns every value given for name.
+func (p *params) all(name string) []string {
+	p.read[name] = true
+	return p.q[name]
+}
+
+// has reports whether any of names was given.
+func (p *params) has(names ...string) bool {
+	given := false
+	for _, name := range names {
+		if p.get(name) != "" {
+			given = true
+		}
+	}
+	return given
+}
+
+// fail records what is wrong with name. Only the first problem with each
+// param is kept, as params can be read more than once.
+func (p *params) fail(name, format string, args ...any) {
+	for _, fe := range p.errs {
+		if fe.Field == name {
+			return
+		}
+	}
+	p.errs = append(p.errs, FieldError{Field: name, Message: fmt.Sprintf(format, args...)})
+}
+
+// int reads a whole number between min and max. ok is false when the param is
+// absent or bad.
+func (p *params) int(name string, min, max int64) (int64, bool) {
+	v := p.get(name)
+	if v == "" {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		p.fail(name, "must be a whole number, got %q", v)
+		return 0, false
+	}
+	if n < min || n > max {
+		p.fail(name, "must be between %d and %d, got %d", min, max, n)
+		return 0, false
+	}
+	return n, true
+}
+
+// float reads a number between min and max.
+func (p *params) float(name string, min, max float64) (float64, bool) {
+	v := p.get(name)
+	if v == "" {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(f) {
+		p.fail(name, "must be a number, got %q", v)
+		return 0, false
+	}
+	if f < min || f > max {
+		p.fail(name, "must be between %g and %g, got %g", min, max, f)
+		return 0, false
+	}
+	return f, true
+}
+
+// bool reads true or false, or anything else strconv.ParseBool takes.
+func (p *params) bool(name string) (bool, bool) {
+	v := p.get(name)
+	if v == "" {
+		return false, false
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		p.fail(name, "must be true or false, got %q", v)
+		return false, false
+	}
+	return b, true
+}
+
+// oneOf reads a param that must be one of options.
+func (p *params) oneOf(name string, options []string) (string, bool) {
+	v := p.get(name)
+	if v == "" {
+		return "", false
+	}
+	if !slices.Contains(options, v) {
+		p.fail(name, "must be one of %s, got %q", strings.Join(options, ", "), v)
+		return "", false
+	}
+	return v, true
+}
+
+// parse hands a param to fn, recording the error fn returns against it.
+func (p *params) parse(name string, fn func(string) error) bool {
+	v := p.get(name)
+	if v == "" {
+		return false
+	}
+	if err := fn(v); err != nil {
+		p.fail(name, "%s", err.Error())
+		return false
+	}
+	return true
+}
+
+// err is a *ValidationError listing the bad params, or nil when there were
+// none. It is called once the handler has read every param it knows, so any
+// other param given is reported as unknown rather than silently ignored.
+func (p *params) err() error {
+	unknown := make([]string, 0, len(p.q))
+	for name := range p.q {
+		if !p.read[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	slices.Sort(unknown)
+	for _, name := range unknown {
+		p.fail(name, "is not a known param")
+	}
+	if len(p.errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: p.errs}
+}
@@ -0,0 +1,130 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestBadGenerationParamsAreRejected(t *testing.T) {
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = "/nonexistent/logwild.log"
+	req, err := http.NewRequest("GET", "/loggen?per_second=abc&message_size=-1&level_mix=loud&multiline_fraction=2&format=xml&preset=nope&burst_dur=10", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(srv.logGenHandler).ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("handler returned bad status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	var body ErrorResponseBody
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	bad := map[string]bool{}
+	for _, fe := range body.Fields {
+		bad[fe.Field] = true
+		if fe.Message == "" {
+			t.Errorf("expected a message for %s", fe.Field)
+		}
+	}
+	for _, field := range []string{"per_second", "message_size", "level_mix", "multiline_fraction", "format", "preset"} {
+		if !bad[field] {
+			t.Errorf("expected %s to be reported, got %+v", field, body.Fields)
+		}
+	}
+	if len(body.Fields) != 6 || body.Code != http.StatusBadRequest {
+		t.Errorf("expected only the bad params to be reported, got %+v", body)
+	}
+}
+
+func TestUnknownParamsAreRejected(t *testing.T) {
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = "/nonexistent/logwild.log"
+	for target, handler := range map[string]http.HandlerFunc{
+		"/loggen?per_sec=10&burst_dur=1&formt=ecs": srv.logGenHandler,
+		"/api/sample?per_sec=10&formt=ecs":         srv.sampleHandler,
+		"/api/jobs?per_sec=10&formt=ecs":           srv.jobCreateHandler,
+	} {
+		req, err := http.NewRequest("GET", target, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected %s to be rejected, got %v", target, rr.Code)
+			continue
+		}
+		var body ErrorResponseBody
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if len(body.Fields) != 2 || body.Fields[0].Field != "formt" || body.Fields[1].Field != "per_sec" {
+			t.Errorf("expected formt and per_sec to be reported for %s, got %+v", target, body.Fields)
+		}
+	}
+}
+
+func TestLogGenNeedsABurstThatEnds(t *testing.T) {
+	srv := NewMockServer()
+	for _, q := range []string{"per_second=0", "burst_dur=0"} {
+		req, err := http.NewRequest("GET", "/loggen?"+q, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(srv.logGenHandler).ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected %s to be rejected, got %v", q, rr.Code)
+		}
+	}
+	// each is one problem, not one per place burst_dur is checked
+	for target, field := range map[string]string{"/loggen?burst_dur=0": "burst_dur", "/loggen?burst_dur=-1": "burst_dur"} {
+		req, err := http.NewRequest("GET", target, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(srv.logGenHandler).ServeHTTP(rr, req)
+		var body ErrorResponseBody
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if len(body.Fields) != 1 || body.Fields[0].Field != field {
+			t.Errorf("expected one problem with %s for %s, got %+v", field, target, body.Fields)
+		}
+	}
+}
+
+func TestJobsCanBePausedButNotGivenBadRates(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	srv := NewMockServer()
+	srv.config.LogwildOutFile = tmpfile.Name()
+	srv.config.LogwildPerSecondRate = 100
+	if err := srv.startContinuous(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.StopJobs()
+	rr := serveJobRequest(t, srv.jobUpdateHandler, "PATCH", "/api/continuous?per_second=0", nil)
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected a rate of 0 to pause the job, got %v", rr.Code)
+	}
+	rr = serveJobRequest(t, srv.jobUpdateHandler, "PATCH", "/api/continuous?per_second=-5", nil)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected a negative rate to be rejected, got %v", rr.Code)
+	}
+	rr = serveJobRequest(t, srv.jobGetHandler, "GET", "/api/jobs/missing", map[string]string{"id": "missing"})
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected errors to carry their status, got %v", rr.Code)
+	}
+}
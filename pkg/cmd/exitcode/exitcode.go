@@ -0,0 +1,49 @@
+// Package exitcode maps command errors to process exit codes, so scripts and
+// CI steps can tell a bad invocation from a failed run.
+package exitcode
+
+import "errors"
+
+// exit codes used by logwild commands
+const (
+	OK = 0
+	// Failure is any error without a more specific code.
+	Failure = 1
+	// Usage means the flags or configuration were invalid.
+	Usage = 2
+	// WriteFailed means generated events could not be written to the sink.
+	WriteFailed = 3
+	// SLOFailed means the run report did not meet its SLO thresholds.
+	SLOFailed = 4
+)
+
+// Error carries the exit code a command should end with.
+type Error struct {
+	Code int
+	Err  error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// New wraps err with code, returning nil when err is nil.
+func New(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Code: code, Err: err}
+}
+
+// Code returns the exit code for err: OK for nil, the code of an Error in its
+// chain, or Failure.
+func Code(err error) int {
+	if err == nil {
+		return OK
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return Failure
+}
@@ -0,0 +1,195 @@
+package gen
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"mcgaunn.com/logwild/pkg/cmd/exitcode"
+	"mcgaunn.com/logwild/pkg/cmd/logopts"
+	"mcgaunn.com/logwild/pkg/logmaker"
+	"mcgaunn.com/logwild/pkg/report"
+	"mcgaunn.com/logwild/pkg/signals"
+)
+
+var (
+	genCmdUse   string = "gen"
+	genCmdShort string = "generate logs without the http server"
+	genCmdLong  string = "write generated logs to log-out-file for log-burst-duration seconds, or until interrupted when it is 0, then print stats. exits 2 for invalid flags, 3 when logs could not be written and 4 when a requested report fails its SLO"
+
+	statsFormat  string
+	reportFile   string
+	reportFormat string
+)
+
+// Result is the summary gen prints once it is done.
+type Result struct {
+	logmaker.Stats
+	ElapsedSeconds  float64 `json:"elapsed_seconds"`
+	LogsPerSecond   float64 `json:"logs_per_second"`
+	TargetPerSecond int64   `json:"target_per_second"`
+}
+
+func NewGenCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   genCmdUse,
+		Short: genCmdShort,
+		Long:  genCmdLong,
+		RunE:  doGenCmd,
+	}
+	f := cmd.Flags()
+	f.StringVar(&statsFormat, "stats-format", "text", "format of the final stats, text or json")
+	logopts.AddReportFlags(f, &reportFile, &reportFormat)
+	return cmd
+}
+
+func doGenCmd(cmd *cobra.Command, args []string) error {
+	slog.Debug("got request to generate logs", "args", args)
+	if statsFormat != "text" && statsFormat != "json" {
+		return exitcode.New(exitcode.Usage, fmt.Errorf("unknown stats format %q, expected text or json", statsFormat))
+	}
+	if !slices.Contains(report.Formats, reportFormat) {
+		return exitcode.New(exitcode.Usage, fmt.Errorf("unknown report format %q, expected one of %s", reportFormat, strings.Join(report.Formats, ", ")))
+	}
+	// a run with nothing to write, or with a negative duration, would never
+	// end or end having done nothing
+	if rate := viper.GetInt64("log-rate"); rate <= 0 {
+		return exitcode.New(exitcode.Usage, fmt.Errorf("log-rate must be at least 1, got %d", rate))
+	}
+	if d := viper.GetInt("log-burst-duration"); d < 0 {
+		return exitcode.New(exitcode.Usage, fmt.Errorf("log-burst-duration must be 0, to run until interrupted, or more, got %d", d))
+	}
+	optFuncs, err := logopts.FromViper()
+	if err != nil {
+		return exitcode.New(exitcode.Usage, err)
+	}
+	// from here on errors are about the run, not how it was invoked
+	cmd.SilenceUsage = true
+
+	outFile := viper.GetString("log-out-file")
+	sink, err := logmaker.OpenSink(outFile)
+	if err != nil {
+		return exitcode.New(exitcode.WriteFailed, err)
+	}
+	defer sink.Close()
+	h, err := logmaker.NewHandler(sink, viper.GetString("log-format"))
+	if err != nil {
+		return exitcode.New(exitcode.Usage, err)
+	}
+	if viper.GetFloat64("log-sensitive-fraction") > 0 {
+		manifest, err := openManifest(outFile)
+		if err != nil {
+			return exitcode.New(exitcode.WriteFailed, err)
+		}
+		sensitive := logmaker.SensitiveOpts{
+			Fraction: viper.GetFloat64("log-sensitive-fraction"),
+			Kinds:    sensitiveKinds(),
+		}
+		if manifest != nil {
+			defer manifest.Close()
+			sensitive.Manifest = manifest
+		}
+		optFuncs = append(optFuncs, logmaker.WithSensitive(sensitive))
+	}
+	// keep diagnostics out of the generated logs when they go to stdout
+	diag := slog.Default()
+	if outFile == "-" {
+		diag = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	}
+	rate := viper.GetInt64("log-rate")
+	optFuncs = append(optFuncs,
+		logmaker.WithPerSecondRate(rate),
+		logmaker.WithPerMessageSize(viper.GetInt64("log-size")),
+		logmaker.WithBurstDuration(time.Duration(viper.GetInt("log-burst-duration"))*time.Second),
+		logmaker.WithLogger(slog.New(h)),
+		logmaker.WithDiagnosticLogger(diag),
+	)
+	lm := logmaker.NewLogMaker(optFuncs...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stopCh := signals.SetupSignalHandler()
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+	start := time.Now()
+	runErr := lm.Run(ctx)
+	elapsed := time.Since(start).Seconds()
+	res := Result{
+		Stats:           lm.Stats(),
+		ElapsedSeconds:  elapsed,
+		LogsPerSecond:   float64(lm.Stats().LogCount) / elapsed,
+		TargetPerSecond: rate,
+	}
+	var out io.Writer = os.Stdout
+	if outFile == "-" {
+		out = os.Stderr
+	}
+	if err := printResult(out, res); err != nil {
+		return err
+	}
+	if reportFile != "" {
+		rep := report.New("gen", logopts.SLOFromViper(), report.Source{Sink: outFile, Record: lm.Record()})
+		// a failed write is the more useful exit code, so it wins
+		if err := logopts.WriteReport(rep, reportFile, reportFormat); err != nil && runErr == nil {
+			return err
+		}
+	}
+	return exitcode.New(exitcode.WriteFailed, runErr)
+}
+
+func printResult(w io.Writer, res Result) error {
+	if statsFormat == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(res)
+	}
+	fmt.Fprintf(w, "logs written: %d in %.2fs (%.2f/s, target %d/s)\n", res.LogCount, res.ElapsedSeconds, res.LogsPerSecond, res.TargetPerSecond)
+	levels := make([]string, 0, len(res.Levels))
+	for l := range res.Levels {
+		levels = append(levels, l)
+	}
+	sort.Strings(levels)
+	for _, l := range levels {
+		fmt.Fprintf(w, "  %-5s %d\n", l, res.Levels[l])
+	}
+	if res.Sensitive > 0 {
+		fmt.Fprintf(w, "sensitive values injected: %d\n", res.Sensitive)
+	}
+	if res.Fuzzed > 0 {
+		fmt.Fprintf(w, "fuzzed events: %d\n", res.Fuzzed)
+	}
+	return nil
+}
+
+// openManifest opens the sensitive data manifest, next to the output file
+// unless log-sensitive-manifest says otherwise. It returns nil when logs go
+// to stdout and no manifest path was given, and values are injected without
+// one, as the server does.
+func openManifest(outFile string) (*os.File, error) {
+	name := viper.GetString("log-sensitive-manifest")
+	if name == "" {
+		if outFile == "-" {
+			slog.Warn("injecting sensitive data without a manifest, set log-sensitive-manifest to record one")
+			return nil, nil
+		}
+		name = outFile + ".manifest.jsonl"
+	}
+	return os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+}
+
+func sensitiveKinds() []string {
+	// already validated by logopts.FromViper
+	kinds, _ := logmaker.ParseSensitiveKinds(viper.GetString("log-sensitive-kinds"))
+	return kinds
+}
@@ -0,0 +1,103 @@
+package logopts
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"mcgaunn.com/logwild/pkg/cmd/exitcode"
+	"mcgaunn.com/logwild/pkg/logmaker"
+	"mcgaunn.com/logwild/pkg/report"
+)
+
+// FromViper builds LogMaker options from the persistent --log-* flags that
+// shape generated events: level mix, fields, multiline exceptions, sensitive
+// data, fuzzing and synthetic traces. The sensitive data manifest is left to callers,
+// since they own the files it is written to.
+func FromViper() ([]logmaker.OptFunc, error) {
+	var optFuncs []logmaker.OptFunc
+	if s := viper.GetString("log-level-mix"); s != "" {
+		mix, err := logmaker.ParseLevelMix(s)
+		if err != nil {
+			return nil, err
+		}
+		optFuncs = append(optFuncs, logmaker.WithLevelMix(mix))
+	}
+	if s := viper.GetString("log-fields"); s != "" {
+		fields, err := logmaker.ParseFieldSpecs(s)
+		if err != nil {
+			return nil, err
+		}
+		optFuncs = append(optFuncs, logmaker.WithFields(fields))
+	}
+	kinds, err := logmaker.ParseStackTraceKinds(viper.GetString("log-multiline-kinds"))
+	if err != nil {
+		return nil, err
+	}
+	optFuncs = append(optFuncs, logmaker.WithMultiline(logmaker.MultilineOpts{
+		Fraction: viper.GetFloat64("log-multiline-fraction"),
+		Frames:   viper.GetInt("log-multiline-frames"),
+		Kinds:    kinds,
+	}))
+	sensitiveKinds, err := logmaker.ParseSensitiveKinds(viper.GetString("log-sensitive-kinds"))
+	if err != nil {
+		return nil, err
+	}
+	optFuncs = append(optFuncs, logmaker.WithSensitive(logmaker.SensitiveOpts{
+		Fraction: viper.GetFloat64("log-sensitive-fraction"),
+		Kinds:    sensitiveKinds,
+	}))
+	fuzz, err := FuzzFromViper()
+	if err != nil {
+		return nil, err
+	}
+	optFuncs = append(optFuncs, logmaker.WithFuzz(fuzz))
+	optFuncs = append(optFuncs, logmaker.WithTraces(logmaker.TraceOpts{
+		Enabled:  viper.GetBool("log-trace"),
+		Depth:    viper.GetInt("log-trace-depth"),
+		Fanout:   viper.GetInt("log-trace-fanout"),
+		Services: logmaker.ParseTraceServices(viper.GetString("log-trace-services")),
+	}))
+	return optFuncs, nil
+}
+
+// FuzzFromViper builds fuzz mode options from the --log-fuzz-* flags.
+func FuzzFromViper() (logmaker.FuzzOpts, error) {
+	mix, err := logmaker.ParseFuzzMix(viper.GetString("log-fuzz-mix"))
+	if err != nil {
+		return logmaker.FuzzOpts{}, err
+	}
+	return logmaker.FuzzOpts{
+		Fraction:     viper.GetFloat64("log-fuzz-fraction"),
+		Mix:          mix,
+		LongLineSize: viper.GetInt("log-fuzz-long-line-size"),
+	}, nil
+}
+
+// SLOFromViper builds run report thresholds from the --report-slo-* flags.
+func SLOFromViper() report.SLO {
+	return report.SLO{
+		MinRateRatio:  viper.GetFloat64("report-slo-min-rate-ratio"),
+		MaxP99Latency: viper.GetDuration("report-slo-max-p99"),
+		MaxErrors:     viper.GetInt64("report-slo-max-errors"),
+	}
+}
+
+// AddReportFlags adds the flags that ask a command for a run report.
+func AddReportFlags(f *pflag.FlagSet, file, format *string) {
+	f.StringVar(file, "report-file", "", "path to write a run report to once done, or - for stdout")
+	f.StringVar(format, "report-format", report.FormatJSON, fmt.Sprintf("format of the run report, one of %s", strings.Join(report.Formats, ", ")))
+}
+
+// WriteReport writes rep to path, returning an SLOFailed error when it did
+// not pass.
+func WriteReport(rep report.Report, path, format string) error {
+	if err := rep.WriteFile(path, format); err != nil {
+		return exitcode.New(exitcode.Failure, err)
+	}
+	if !rep.Pass {
+		return exitcode.New(exitcode.SLOFailed, fmt.Errorf("run did not meet its SLO, see the report in %s", path))
+	}
+	return nil
+}
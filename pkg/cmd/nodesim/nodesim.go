@@ -0,0 +1,83 @@
+package nodesim
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"mcgaunn.com/logwild/pkg/cmd/logopts"
+	"mcgaunn.com/logwild/pkg/nodesim"
+	"mcgaunn.com/logwild/pkg/signals"
+)
+
+var (
+	nodesimCmdUse   string = "nodesim"
+	nodesimCmdShort string = "simulate a kubernetes node log directory"
+	nodesimCmdLong  string = "write CRI formatted logs into a /var/log/pods and /var/log/containers style tree, with pod churn, container restarts and kubelet-style rotation"
+
+	root             string
+	pods             int
+	containersPerPod int
+	namespaces       []string
+	rate             int64
+	churnInterval    time.Duration
+	restartInterval  time.Duration
+	maxFileSize      int64
+	maxFiles         int
+	duration         time.Duration
+)
+
+func NewNodesimCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   nodesimCmdUse,
+		Short: nodesimCmdShort,
+		Long:  nodesimCmdLong,
+		RunE:  doNodesimCmd,
+	}
+	f := cmd.Flags()
+	f.StringVar(&root, "root", "/tmp/logwild-node", "directory standing in for /var/log on the node")
+	f.IntVar(&pods, "pods", 10, "number of pods running at once")
+	f.IntVar(&containersPerPod, "containers", 1, "number of containers in each pod")
+	f.StringSliceVar(&namespaces, "namespaces", nil, "namespaces pods are spread across")
+	f.Int64Var(&rate, "rate", 10, "number of lines each container writes per second")
+	f.DurationVar(&churnInterval, "churn-interval", 30*time.Second, "how often a pod is deleted and replaced - 0 disables churn")
+	f.DurationVar(&restartInterval, "restart-interval", time.Minute, "how often a container restarts - 0 disables restarts")
+	f.Int64Var(&maxFileSize, "max-file-size", 10*1024*1024, "size in bytes at which container logs are rotated")
+	f.IntVar(&maxFiles, "max-files", 5, "number of log files kept per container, including the live one")
+	f.DurationVar(&duration, "duration", 0, "how long to run the simulation - 0 runs until interrupted")
+	return cmd
+}
+
+func doNodesimCmd(cmd *cobra.Command, args []string) error {
+	slog.Debug("got request to simulate node logs", "args", args)
+	lmOpts, err := logopts.FromViper()
+	if err != nil {
+		slog.Error("invalid log generation flags", "err", err)
+		return err
+	}
+	sim := nodesim.NewSimulator(
+		nodesim.WithRoot(root),
+		nodesim.WithPods(pods),
+		nodesim.WithContainersPerPod(containersPerPod),
+		nodesim.WithNamespaces(namespaces),
+		nodesim.WithPerSecondRate(rate),
+		nodesim.WithPerMessageSize(viper.GetInt64("log-size")),
+		nodesim.WithChurnInterval(churnInterval),
+		nodesim.WithRestartInterval(restartInterval),
+		nodesim.WithRotation(maxFileSize, maxFiles),
+		nodesim.WithDuration(duration),
+		nodesim.WithLogMakerOpts(lmOpts...),
+		nodesim.WithLogger(slog.Default()),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stopCh := signals.SetupSignalHandler()
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+	return sim.Run(ctx)
+}
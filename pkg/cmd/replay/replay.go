@@ -0,0 +1,85 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"mcgaunn.com/logwild/pkg/logmaker"
+	"mcgaunn.com/logwild/pkg/signals"
+)
+
+var (
+	replayCmdUse   string = "replay <file>"
+	replayCmdShort string = "re-emit a captured log file"
+	replayCmdLong  string = "read a captured log file and write its lines to the log-out-file sink, at their original timing, scaled by a speed factor, or at a fixed rate"
+
+	timing            string
+	speed             float64
+	rate              int64
+	rewriteTimestamps bool
+	injectSeq         bool
+)
+
+func NewReplayCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   replayCmdUse,
+		Short: replayCmdShort,
+		Long:  replayCmdLong,
+		Args:  cobra.ExactArgs(1),
+		RunE:  doReplayCmd,
+	}
+	f := cmd.Flags()
+	f.StringVar(&timing, "timing", logmaker.ReplayOriginal, "original keeps the gaps between line timestamps, rate emits lines at --rate")
+	f.Float64Var(&speed, "speed", 1, "speed factor applied to original timing, e.g. 2 replays twice as fast")
+	f.Int64Var(&rate, "rate", 0, "lines per second with --timing rate - 0 uses log-rate")
+	f.BoolVar(&rewriteTimestamps, "rewrite-timestamps", false, "replace the timestamp of every line with the time it is replayed at")
+	f.BoolVar(&injectSeq, "inject-seq", false, "add a unique seq field to every line")
+	return cmd
+}
+
+func doReplayCmd(cmd *cobra.Command, args []string) error {
+	slog.Debug("got request to replay log file", "args", args)
+	src, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	sink, err := logmaker.OpenSink(viper.GetString("log-out-file"))
+	if err != nil {
+		return err
+	}
+	defer sink.Close()
+
+	if rate == 0 {
+		rate = viper.GetInt64("log-rate")
+	}
+	lm := logmaker.NewLogMaker(logmaker.WithPerSecondRate(rate))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stopCh := signals.SetupSignalHandler()
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+	err = lm.Replay(ctx, src, sink, logmaker.ReplayOpts{
+		Timing:            timing,
+		Speed:             speed,
+		RewriteTimestamps: rewriteTimestamps,
+		InjectSeq:         injectSeq,
+	})
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(lm.Stats(), "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%s\n", out)
+	return nil
+}
@@ -6,11 +6,19 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"mcgaunn.com/logwild/pkg/cmd/exitcode"
+	"mcgaunn.com/logwild/pkg/cmd/gen"
+	"mcgaunn.com/logwild/pkg/cmd/nodesim"
+	"mcgaunn.com/logwild/pkg/cmd/replay"
 	"mcgaunn.com/logwild/pkg/cmd/run"
+	"mcgaunn.com/logwild/pkg/cmd/scenario"
+	"mcgaunn.com/logwild/pkg/cmd/verify"
 	"mcgaunn.com/logwild/pkg/cmd/version"
+	"mcgaunn.com/logwild/pkg/logmaker"
 	ver "mcgaunn.com/logwild/pkg/version"
 )
 
@@ -24,10 +32,34 @@ var (
 	certPath           string
 	config             string
 	otelServiceName    string
+	otelStopTimeout    time.Duration
+	backendURLs        []string
+	metricsRuntime     bool
+	enablePprof        bool
 	logsPerSecondRate  int64
 	logsPerMessageSize int64
 	logsBurstDuration  int
 	logsOutFile        string
+	logsLevelMix       string
+	logsFormat         string
+	logsMultiFraction  float64
+	logsMultiFrames    int
+	logsMultiKinds     string
+	logsFields         string
+	logsTrace          bool
+	logsTraceDepth     int
+	logsTraceFanout    int
+	logsTraceServices  string
+	logsSensFraction   float64
+	logsSensKinds      string
+	logsSensManifest   string
+	logsFuzzFraction   float64
+	logsFuzzMix        string
+	logsFuzzLongLine   int
+	logsContinuous     bool
+	sloMinRateRatio    float64
+	sloMaxP99          time.Duration
+	sloMaxErrors       int64
 )
 
 func NewRootCmd() *cobra.Command {
@@ -66,6 +98,11 @@ func NewRootCmd() *cobra.Command {
 		},
 	}
 
+	// bad flags exit with the usage code, for every subcommand
+	cmd.SetFlagErrorFunc(func(c *cobra.Command, err error) error {
+		return exitcode.New(exitcode.Usage, err)
+	})
+
 	p := cmd.PersistentFlags()
 	p.BoolVar(&debug, "debug", false, "Enable debug logging")
 	p.StringVar(&host, "host", "", "Host to which http server should bind")
@@ -74,11 +111,35 @@ func NewRootCmd() *cobra.Command {
 	p.StringVar(&portMetrics, "port-metrics", "0", "Port to which prometheus metrics server should bind - 0 disables metrics")
 	p.StringVar(&configPath, "config-path", "", "config dir path")
 	p.StringVar(&config, "config", "config.yaml", "config file name within config dir")
-	p.StringVar(&otelServiceName, "otel-service-name", "", "service name to report to otel address, disables tracing when not set")
+	p.BoolVar(&metricsRuntime, "metrics-runtime", false, "export Go runtime and process metrics alongside the http and generator metrics")
+	p.BoolVar(&enablePprof, "enable-pprof", false, "serve net/http/pprof profiles at /debug/pprof/ on the http and metrics ports")
+	p.StringSliceVar(&backendURLs, "backend-url", nil, "worker logwild instances, e.g. http://10.0.0.2:8888, that scenario coordinate splits a scenario across")
+	p.StringVar(&otelServiceName, "otel-service-name", "", "service name to report to otel address, disables tracing and OTLP metrics when not set")
+	p.DurationVar(&otelStopTimeout, "telemetry-shutdown-timeout", 10*time.Second, "how long to wait for the last spans and metrics to be exported on shutdown")
 	p.Int64Var(&logsPerSecondRate, "log-rate", 1000, "number of logs to emit per second with each /loggen request")
 	p.Int64Var(&logsPerMessageSize, "log-size", 64, "average length of each log message produced in words")
 	p.IntVar(&logsBurstDuration, "log-burst-duration", 5, "number of seconds to spam logs per /loggen request")
 	p.StringVar(&logsOutFile, "log-out-file", "/tmp/logwild.log", "path to file logs should be streamed for /loggen, or - for stdout")
+	p.StringVar(&logsFormat, "log-format", "json", "format of generated logs, one of json, text, plain, raw, or a schema preset: ecs, otel, cloudwatch, gcp, cri")
+	p.Float64Var(&logsMultiFraction, "log-multiline-fraction", 0, "fraction of generated logs, between 0 and 1, emitted as multiline stack traces")
+	p.IntVar(&logsMultiFrames, "log-multiline-frames", 8, "number of frames in each generated stack trace")
+	p.StringVar(&logsMultiKinds, "log-multiline-kinds", "", "comma separated stack trace styles to use (java, python, go, dotnet) - empty uses all of them")
+	p.StringVar(&logsFields, "log-fields", "", "extra fields as name:cardinality[:uniform|zipf|sequential[:ipv4]] or name:value|value...[:distribution], comma separated, e.g. tenant:50:zipf,pod:500,method:GET|POST")
+	p.BoolVar(&logsTrace, "log-trace", false, "create synthetic traces and stamp generated logs with their trace and span ids")
+	p.IntVar(&logsTraceDepth, "log-trace-depth", 3, "number of span levels in each synthetic trace")
+	p.IntVar(&logsTraceFanout, "log-trace-fanout", 2, "number of child spans of each span in a synthetic trace")
+	p.StringVar(&logsTraceServices, "log-trace-services", "", "comma separated service names assigned to synthetic spans")
+	p.Float64Var(&logsSensFraction, "log-sensitive-fraction", 0, "fraction of generated logs, between 0 and 1, seeded with fake PII or secrets")
+	p.StringVar(&logsSensKinds, "log-sensitive-kinds", "", "comma separated kinds of sensitive data to inject (email, credit_card, ssn, phone, ipv4, ipv6, jwt, aws_key, bearer_token) - empty uses all of them")
+	p.StringVar(&logsSensManifest, "log-sensitive-manifest", "", "path of the ground-truth manifest of injected values - defaults to <log-out-file>.manifest.jsonl")
+	p.Float64Var(&logsFuzzFraction, "log-fuzz-fraction", 0, "fraction of generated logs, between 0 and 1, mangled with unusual or malformed input")
+	p.StringVar(&logsFuzzMix, "log-fuzz-mix", "", "weighted fuzz mutations as kind=weight, comma separated (multibyte, emoji, rtl, invalid_utf8, nul, newline, ansi, long_line, broken_json) - empty weighs them all equally")
+	p.IntVar(&logsFuzzLongLine, "log-fuzz-long-line-size", logmaker.DefaultLongLineSize, "size in bytes of messages made by the long_line fuzz mutation")
+	p.BoolVar(&logsContinuous, "log-continuous", false, "with run, generate logs at log-rate from server start until shutdown instead of waiting for /loggen")
+	p.Float64Var(&sloMinRateRatio, "report-slo-min-rate-ratio", 0, "lowest ratio of achieved to target events, e.g. 0.95, for a run report to pass - 0 skips the check")
+	p.DurationVar(&sloMaxP99, "report-slo-max-p99", 0, "slowest 99th percentile write latency for a run report to pass - 0 skips the check")
+	p.Int64Var(&sloMaxErrors, "report-slo-max-errors", 0, "most write errors for a run report to pass")
+	p.StringVar(&logsLevelMix, "log-level-mix", "", "weighted level distribution, e.g. 10/80/8/2 (debug/info/warn/error/fatal) or info=80,warn=15,error=4,fatal=1 - empty logs everything at info")
 
 	// bind flags and environment variables
 	viper.BindPFlags(p)
@@ -104,6 +165,11 @@ func NewRootCmd() *cobra.Command {
 	// register subcommands
 	cmd.AddCommand(version.NewVersionCmd())
 	cmd.AddCommand(run.NewRunCmd())
+	cmd.AddCommand(nodesim.NewNodesimCmd())
+	cmd.AddCommand(verify.NewVerifyCmd())
+	cmd.AddCommand(replay.NewReplayCmd())
+	cmd.AddCommand(gen.NewGenCmd())
+	cmd.AddCommand(scenario.NewScenarioCmd())
 
 	return cmd
 }
@@ -1,6 +1,7 @@
 package run
 
 import (
+	"context"
 	"log/slog"
 
 	"github.com/spf13/cobra"
@@ -48,5 +49,12 @@ func doRunCmd(cmd *cobra.Command, args []string) error {
 	stopCh := signals.SetupSignalHandler()
 	sd, _ := signals.NewShutdown(srvCfg.ServerShutdownTimeout, slog.Default())
 	sd.Graceful(stopCh, httpServer, healthy, ready)
+	srv.StopJobs()
+	srv.StopScenarios()
+	// the http server's shutdown timeout may be 0, which would give the
+	// final export no time at all
+	ctx, cancel := context.WithTimeout(context.Background(), srvCfg.TelemetryShutdownTimeout)
+	defer cancel()
+	srv.StopTelemetry(ctx)
 	return nil
 }
@@ -0,0 +1,224 @@
+package scenario
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"mcgaunn.com/logwild/pkg/cmd/exitcode"
+	"mcgaunn.com/logwild/pkg/cmd/logopts"
+	"mcgaunn.com/logwild/pkg/coordinator"
+	"mcgaunn.com/logwild/pkg/report"
+	"mcgaunn.com/logwild/pkg/scenario"
+	"mcgaunn.com/logwild/pkg/signals"
+)
+
+var (
+	scenarioCmdUse   string = "scenario"
+	scenarioCmdShort string = "run multi-stream, multi-phase load plans"
+	scenarioCmdLong  string = "run or check scenario files, which describe streams of logs and the phases that shape their rates"
+
+	preset       string
+	reportFile   string
+	reportFormat string
+	startDelay   time.Duration
+)
+
+func NewScenarioCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   scenarioCmdUse,
+		Short: scenarioCmdShort,
+		Long:  scenarioCmdLong,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Usage()
+		},
+	}
+	runCmd := &cobra.Command{
+		Use:   "run [file]",
+		Short: "run a scenario file or a built-in preset",
+		Long:  "run every phase of a YAML or JSON scenario file, or of the preset named by --preset, then print what each stream wrote. streams without a sink write to log-out-file",
+		Args:  cobra.MaximumNArgs(1),
+		RunE:  doRunCmd,
+	}
+	logopts.AddReportFlags(runCmd.Flags(), &reportFile, &reportFormat)
+	runCmd.Flags().StringVar(&preset, "preset", "", fmt.Sprintf("built-in scenario to run instead of a file, one of %s", strings.Join(scenario.PresetNames(), ", ")))
+	cmd.AddCommand(runCmd)
+	coordinateCmd := &cobra.Command{
+		Use:   "coordinate [file]",
+		Short: "run a scenario across several logwild instances",
+		Long:  "split the rates of a scenario file, or of the preset named by --preset, across the logwild instances given by --backend-url, start them together, and combine what they wrote. streams write to each instance's own log-out-file",
+		Args:  cobra.MaximumNArgs(1),
+		RunE:  doCoordinateCmd,
+	}
+	logopts.AddReportFlags(coordinateCmd.Flags(), &reportFile, &reportFormat)
+	coordinateCmd.Flags().StringVar(&preset, "preset", "", fmt.Sprintf("built-in scenario to run instead of a file, one of %s", strings.Join(scenario.PresetNames(), ", ")))
+	coordinateCmd.Flags().DurationVar(&startDelay, "start-delay", 2*time.Second, "how far ahead to schedule the shared start, leaving time to reach every instance")
+	cmd.AddCommand(coordinateCmd)
+	cmd.AddCommand(&cobra.Command{
+		Use:   "presets",
+		Short: "list the built-in scenarios",
+		Args:  cobra.NoArgs,
+		RunE:  doPresetsCmd,
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "validate <file>",
+		Short: "check a scenario file without running it",
+		Args:  cobra.ExactArgs(1),
+		RunE:  doValidateCmd,
+	})
+	return cmd
+}
+
+func load(path string) (*scenario.Scenario, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, exitcode.New(exitcode.Usage, err)
+	}
+	defer f.Close()
+	sc, err := scenario.Load(f)
+	if err != nil {
+		return nil, exitcode.New(exitcode.Usage, err)
+	}
+	return sc, nil
+}
+
+func doPresetsCmd(cmd *cobra.Command, args []string) error {
+	for _, sc := range scenario.Presets() {
+		fmt.Fprintf(os.Stdout, "%-26s %-8s %s\n", sc.Name, sc.Duration(), sc.Description)
+	}
+	return nil
+}
+
+func doValidateCmd(cmd *cobra.Command, args []string) error {
+	sc, err := load(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "scenario %q is valid: %d streams, %d phases, %s\n", sc.Name, len(sc.Streams), len(sc.Phases), sc.Duration())
+	return nil
+}
+
+// scenarioFromArgs loads the scenario file in args or the --preset, and
+// checks the report format.
+func scenarioFromArgs(args []string) (*scenario.Scenario, error) {
+	var sc *scenario.Scenario
+	switch {
+	case preset != "" && len(args) > 0:
+		return nil, exitcode.New(exitcode.Usage, errors.New("give either a scenario file or --preset, not both"))
+	case preset != "":
+		if sc = scenario.Preset(preset); sc == nil {
+			return nil, exitcode.New(exitcode.Usage, fmt.Errorf("unknown preset %q, expected one of %s", preset, strings.Join(scenario.PresetNames(), ", ")))
+		}
+	case len(args) > 0:
+		var err error
+		if sc, err = load(args[0]); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, exitcode.New(exitcode.Usage, errors.New("a scenario file or --preset is required"))
+	}
+	if !slices.Contains(report.Formats, reportFormat) {
+		return nil, exitcode.New(exitcode.Usage, fmt.Errorf("unknown report format %q, expected one of %s", reportFormat, strings.Join(report.Formats, ", ")))
+	}
+	return sc, nil
+}
+
+// cancelOnSignal returns a context that is cancelled on SIGINT or SIGTERM.
+func cancelOnSignal() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stopCh := signals.SetupSignalHandler()
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+	return ctx, cancel
+}
+
+func doRunCmd(cmd *cobra.Command, args []string) error {
+	slog.Debug("got request to run scenario", "args", args, "preset", preset)
+	sc, err := scenarioFromArgs(args)
+	if err != nil {
+		return err
+	}
+	optFuncs, err := logopts.FromViper()
+	if err != nil {
+		return exitcode.New(exitcode.Usage, err)
+	}
+	// from here on errors are about the run, not how it was invoked
+	cmd.SilenceUsage = true
+
+	outFile := viper.GetString("log-out-file")
+	rn := scenario.NewRunner(sc,
+		scenario.WithLogMakerOpts(optFuncs...),
+		scenario.WithDefaultSink(outFile),
+		scenario.WithDefaultFormat(viper.GetString("log-format")),
+		scenario.WithLogger(slog.Default()))
+
+	ctx, cancel := cancelOnSignal()
+	defer cancel()
+	res, runErr := rn.Run(ctx)
+	// keep the result out of the generated logs when they go to stdout
+	var out io.Writer = os.Stdout
+	if outFile == "-" {
+		out = os.Stderr
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(res); err != nil {
+		return err
+	}
+	if runErr == context.Canceled {
+		runErr = nil
+	}
+	if reportFile != "" {
+		// a failed write is the more useful exit code, so it wins
+		if err := logopts.WriteReport(rn.Report(logopts.SLOFromViper()), reportFile, reportFormat); err != nil && runErr == nil {
+			return err
+		}
+	}
+	return exitcode.New(exitcode.WriteFailed, runErr)
+}
+
+func doCoordinateCmd(cmd *cobra.Command, args []string) error {
+	slog.Debug("got request to coordinate scenario", "args", args, "preset", preset)
+	sc, err := scenarioFromArgs(args)
+	if err != nil {
+		return err
+	}
+	workers := viper.GetStringSlice("backend-url")
+	co, err := coordinator.New(sc, workers,
+		coordinator.WithStartDelay(startDelay),
+		coordinator.WithLogger(slog.Default()))
+	if err != nil {
+		return exitcode.New(exitcode.Usage, err)
+	}
+	cmd.SilenceUsage = true
+
+	ctx, cancel := cancelOnSignal()
+	defer cancel()
+	res, runErr := co.Run(ctx)
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(res); err != nil {
+		return err
+	}
+	if errors.Is(runErr, context.Canceled) {
+		runErr = nil
+	}
+	if reportFile != "" {
+		if err := logopts.WriteReport(co.Report(logopts.SLOFromViper()), reportFile, reportFormat); err != nil && runErr == nil {
+			return err
+		}
+	}
+	// failures can be on any worker, or in reaching it
+	return exitcode.New(exitcode.Failure, runErr)
+}
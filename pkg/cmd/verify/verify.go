@@ -0,0 +1,68 @@
+package verify
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/spf13/cobra"
+	"mcgaunn.com/logwild/pkg/logmaker"
+)
+
+var (
+	verifyCmdUse   string = "verify"
+	verifyCmdShort string = "verify that injected sensitive data was redacted"
+	verifyCmdLong  string = "compare the sensitive data manifest of a run with the output of a redaction pipeline, and fail if any injected value leaked"
+
+	manifestPath string
+	inputPath    string
+)
+
+func NewVerifyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   verifyCmdUse,
+		Short: verifyCmdShort,
+		Long:  verifyCmdLong,
+		RunE:  doVerifyCmd,
+	}
+	f := cmd.Flags()
+	f.StringVar(&manifestPath, "manifest", "", "path of the manifest written with --log-sensitive-fraction")
+	f.StringVar(&inputPath, "input", "-", "path of the redacted pipeline output, or - for stdin")
+	cmd.MarkFlagRequired("manifest")
+	return cmd
+}
+
+func doVerifyCmd(cmd *cobra.Command, args []string) error {
+	slog.Debug("got request to verify redaction", "manifest", manifestPath, "input", inputPath)
+	manifest, err := os.Open(manifestPath)
+	if err != nil {
+		return err
+	}
+	defer manifest.Close()
+
+	var input io.Reader = os.Stdin
+	if inputPath != "-" {
+		f, err := os.Open(inputPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		input = f
+	}
+
+	report, err := logmaker.VerifyRedaction(manifest, input)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "%s\n", out)
+	if !report.Passed() {
+		return fmt.Errorf("%d of %d injected values were not redacted and %d could not be found in the input", report.Leaked, report.Total, report.Missing)
+	}
+	return nil
+}
@@ -0,0 +1,358 @@
+// Package coordinator runs one scenario across several logwild instances, for
+// more load than one instance can generate. Each worker gets an equal share
+// of every rate, all of them start at a shared time and are stopped
+// together, and what they recorded is combined into one report.
+package coordinator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"mcgaunn.com/logwild/pkg/logmaker"
+	"mcgaunn.com/logwild/pkg/report"
+	"mcgaunn.com/logwild/pkg/scenario"
+)
+
+type OptFunc func(*Opts)
+
+type Opts struct {
+	// Client talks to the workers.
+	Client *http.Client
+	// StartDelay is how far ahead the shared start time is set, leaving
+	// every worker time to get its part of the scenario.
+	StartDelay time.Duration
+	// PollInterval is how often workers are asked whether they are done.
+	PollInterval time.Duration
+	Logger       *slog.Logger
+}
+
+// Result is what the workers wrote, each and in total.
+type Result struct {
+	Scenario string    `json:"scenario"`
+	StartAt  time.Time `json:"start_at"`
+	// Workers is what each worker's part wrote, by worker URL.
+	Workers map[string]scenario.Result `json:"workers"`
+	// Streams sums the stats of each stream across workers.
+	Streams map[string]logmaker.Stats `json:"streams"`
+}
+
+// Coordinator runs a scenario across workers.
+type Coordinator struct {
+	Opts
+	scenario *scenario.Scenario
+	workers  []*worker
+	startAt  time.Time
+}
+
+// worker is a logwild instance running a part of the scenario.
+type worker struct {
+	url     string
+	id      string
+	result  scenario.Result
+	sources []report.Source
+	err     error
+}
+
+// workerStatus is the part of a worker's scenario status the coordinator
+// uses.
+type workerStatus struct {
+	ID         string     `json:"id"`
+	FinishedAt *time.Time `json:"finished_at"`
+	Error      string     `json:"error"`
+	scenario.Result
+}
+
+// apiError is the body of a failed worker request.
+type apiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func defaultOpts() Opts {
+	return Opts{
+		Client:       &http.Client{Timeout: 30 * time.Second},
+		StartDelay:   2 * time.Second,
+		PollInterval: time.Second,
+		Logger:       slog.Default(),
+	}
+}
+
+func WithClient(c *http.Client) OptFunc {
+	return func(opts *Opts) {
+		opts.Client = c
+	}
+}
+
+func WithStartDelay(d time.Duration) OptFunc {
+	return func(opts *Opts) {
+		opts.StartDelay = d
+	}
+}
+
+func WithPollInterval(d time.Duration) OptFunc {
+	return func(opts *Opts) {
+		opts.PollInterval = d
+	}
+}
+
+func WithLogger(l *slog.Logger) OptFunc {
+	return func(opts *Opts) {
+		opts.Logger = l
+	}
+}
+
+// New creates a coordinator that runs sc across the logwild instances at
+// workerURLs, e.g. http://10.0.0.2:8888.
+func New(sc *scenario.Scenario, workerURLs []string, opts ...OptFunc) (*Coordinator, error) {
+	if len(workerURLs) == 0 {
+		return nil, errors.New("no workers to coordinate")
+	}
+	o := defaultOpts()
+	for _, fn := range opts {
+		fn(&o)
+	}
+	c := &Coordinator{Opts: o, scenario: sc}
+	for _, u := range workerURLs {
+		if _, err := url.ParseRequestURI(u); err != nil {
+			return nil, fmt.Errorf("bad worker url %q: %w", u, err)
+		}
+		c.workers = append(c.workers, &worker{url: strings.TrimSuffix(u, "/")})
+	}
+	return c, nil
+}
+
+// Run gives each worker its share of the scenario, starting them together,
+// and waits for them to finish. When ctx is done first, every worker is
+// stopped. Either way, it collects what the workers recorded before
+// returning.
+func (c *Coordinator) Run(ctx context.Context) (Result, error) {
+	c.startAt = time.Now().Add(c.StartDelay)
+	parts := c.scenario.Split(len(c.workers))
+	for _, part := range parts {
+		// workers only write to their own output file
+		for i := range part.Streams {
+			part.Streams[i].Sink = ""
+		}
+	}
+	err := c.each(func(i int, w *worker) error {
+		return c.start(ctx, w, parts[i])
+	})
+	if err != nil {
+		// don't leave the workers that did start running on their own
+		c.cleanup("failed to stop workers after a failed start", c.stop)
+		c.cleanup("failed to remove workers' parts after a failed start", c.remove)
+		return Result{}, err
+	}
+	c.Logger.Info("scenario started on workers", "scenario", c.scenario.Name, "workers", len(c.workers), "start_at", c.startAt)
+
+	err = c.wait(ctx)
+	if err != nil {
+		c.cleanup("failed to stop workers", c.stop)
+	}
+	collectErr := c.each(func(_ int, w *worker) error {
+		if err := c.collect(w); err != nil {
+			return err
+		}
+		return c.remove(w)
+	})
+	return c.Result(), errors.Join(err, collectErr, c.workerErrors())
+}
+
+// each calls fn for every worker at once, returning their errors joined.
+func (c *Coordinator) each(fn func(int, *worker) error) error {
+	errs := make([]error, len(c.workers))
+	var wg sync.WaitGroup
+	for i, w := range c.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fn(i, w); err != nil {
+				errs[i] = fmt.Errorf("worker %s: %w", w.url, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// cleanup calls fn for every worker, logging its errors rather than
+// returning them, as they'd only bury the error that called for the cleanup.
+func (c *Coordinator) cleanup(msg string, fn func(*worker) error) {
+	err := c.each(func(_ int, w *worker) error { return fn(w) })
+	if err != nil {
+		c.Logger.Warn(msg, "scenario", c.scenario.Name, "err", err)
+	}
+}
+
+func (c *Coordinator) start(ctx context.Context, w *worker, part *scenario.Scenario) error {
+	body, err := yaml.Marshal(part)
+	if err != nil {
+		return err
+	}
+	q := url.Values{"start_at": {c.startAt.Format(time.RFC3339Nano)}}
+	var st workerStatus
+	if err := c.call(ctx, http.MethodPost, w.url+"/api/scenarios?"+q.Encode(), body, &st); err != nil {
+		return err
+	}
+	w.id = st.ID
+	return nil
+}
+
+// wait polls the workers until all of them are done, or ctx is.
+func (c *Coordinator) wait(ctx context.Context) error {
+	// nothing can finish before the last phase is over
+	timer := time.NewTimer(time.Until(c.startAt.Add(c.scenario.Duration())))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+	}
+	tickr := time.NewTicker(c.PollInterval)
+	defer tickr.Stop()
+	for {
+		var mu sync.Mutex
+		done := true
+		err := c.each(func(_ int, w *worker) error {
+			var st workerStatus
+			if err := c.call(ctx, http.MethodGet, w.scenarioURL(), nil, &st); err != nil {
+				return err
+			}
+			mu.Lock()
+			done = done && st.FinishedAt != nil
+			mu.Unlock()
+			return nil
+		})
+		if err != nil && ctx.Err() == nil {
+			return err
+		}
+		if done && err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tickr.C:
+		}
+	}
+}
+
+// stop stops a worker's part, keeping it for collect.
+func (c *Coordinator) stop(w *worker) error {
+	if w.id == "" {
+		return nil
+	}
+	return c.call(context.Background(), http.MethodPost, w.scenarioURL()+"/stop", nil, nil)
+}
+
+// collect fetches a worker's status and what its streams recorded.
+func (c *Coordinator) collect(w *worker) error {
+	ctx := context.Background()
+	var st workerStatus
+	if err := c.call(ctx, http.MethodGet, w.scenarioURL(), nil, &st); err != nil {
+		return err
+	}
+	if st.Error != "" {
+		w.err = fmt.Errorf("worker %s: %s", w.url, st.Error)
+	}
+	w.result = st.Result
+	return c.call(ctx, http.MethodGet, w.scenarioURL()+"/sources", nil, &w.sources)
+}
+
+// remove deletes a worker's part once it has been collected.
+func (c *Coordinator) remove(w *worker) error {
+	if w.id == "" {
+		return nil
+	}
+	return c.call(context.Background(), http.MethodDelete, w.scenarioURL(), nil, nil)
+}
+
+func (w *worker) scenarioURL() string {
+	return w.url + "/api/scenarios/" + url.PathEscape(w.id)
+}
+
+// call makes a request to a worker and decodes the JSON response into out,
+// unless out is nil.
+func (c *Coordinator) call(ctx context.Context, method, u string, body []byte, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/yaml")
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var apiErr apiError
+	if json.Unmarshal(data, &apiErr) == nil && apiErr.Code >= 400 {
+		return fmt.Errorf("%s %s: %s", method, u, apiErr.Message)
+	}
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("%s %s: %s", method, u, resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// workerErrors joins the errors the workers reported for their runs.
+func (c *Coordinator) workerErrors() error {
+	var errs []error
+	for _, w := range c.workers {
+		errs = append(errs, w.err)
+	}
+	return errors.Join(errs...)
+}
+
+// Result is what each worker wrote and the totals per stream, as of the
+// last time they were collected.
+func (c *Coordinator) Result() Result {
+	res := Result{
+		Scenario: c.scenario.Name,
+		StartAt:  c.startAt,
+		Workers:  make(map[string]scenario.Result, len(c.workers)),
+		Streams:  map[string]logmaker.Stats{},
+	}
+	for _, w := range c.workers {
+		res.Workers[w.url] = w.result
+		for name, st := range w.result.Streams {
+			total := res.Streams[name]
+			total.Add(st)
+			res.Streams[name] = total
+		}
+	}
+	return res
+}
+
+// Report combines what every worker's streams recorded into one report.
+// Sinks are named after their worker, since each worker has its own files.
+func (c *Coordinator) Report(slo report.SLO) report.Report {
+	var sources []report.Source
+	for _, w := range c.workers {
+		for _, src := range w.sources {
+			src.Sink = w.url + " " + src.Sink
+			sources = append(sources, src)
+		}
+	}
+	return report.New(c.scenario.Name, slo, sources...)
+}
@@ -0,0 +1,186 @@
+package coordinator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"mcgaunn.com/logwild/pkg/scenario"
+)
+
+// fakeWorker answers the worker API the coordinator uses, failing whichever
+// requests the test asks it to.
+type fakeWorker struct {
+	// fail maps a request, e.g. "POST /api/scenarios", to the message of the
+	// error it answers with.
+	fail map[string]string
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func newFakeWorker(t *testing.T, fail map[string]string) (*fakeWorker, *httptest.Server) {
+	t.Helper()
+	fw := &fakeWorker{fail: fail}
+	ts := httptest.NewServer(fw)
+	t.Cleanup(ts.Close)
+	return fw, ts
+}
+
+func (fw *fakeWorker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	call := r.Method + " " + r.URL.Path
+	fw.mu.Lock()
+	fw.calls = append(fw.calls, call)
+	fw.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if msg, ok := fw.fail[call]; ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(apiError{Code: http.StatusInternalServerError, Message: msg})
+		return
+	}
+	switch {
+	case call == "POST /api/scenarios":
+		json.NewEncoder(w).Encode(workerStatus{ID: "part"})
+	case call == "GET /api/scenarios/part":
+		now := time.Now()
+		json.NewEncoder(w).Encode(workerStatus{ID: "part", FinishedAt: &now})
+	case call == "GET /api/scenarios/part/sources":
+		w.Write([]byte("[]"))
+	default:
+		w.Write([]byte("{}"))
+	}
+}
+
+func (fw *fakeWorker) called(call string) bool {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	return slices.Contains(fw.calls, call)
+}
+
+func testScenario(t *testing.T) *scenario.Scenario {
+	t.Helper()
+	sc, err := scenario.Parse([]byte(`
+name: fake
+streams:
+  - name: web
+    rate: 10
+phases:
+  - name: steady
+    duration: 10ms
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sc
+}
+
+func TestRunStopsStartedWorkersWhenAnotherFailsToStart(t *testing.T) {
+	good, goodTS := newFakeWorker(t, map[string]string{
+		"DELETE /api/scenarios/part": "disk on fire",
+	})
+	_, badTS := newFakeWorker(t, map[string]string{
+		"POST /api/scenarios": "too many scenarios running",
+	})
+	var logs bytes.Buffer
+	co, err := New(testScenario(t), []string{goodTS.URL, badTS.URL},
+		WithStartDelay(0),
+		WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = co.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "too many scenarios running") {
+		t.Fatalf("expected the worker's start error, got %v", err)
+	}
+	if !good.called("POST /api/scenarios/part/stop") {
+		t.Error("expected the worker that started to be stopped")
+	}
+	if !good.called("DELETE /api/scenarios/part") {
+		t.Error("expected the worker that started to have its part removed")
+	}
+	if !strings.Contains(logs.String(), "disk on fire") {
+		t.Errorf("expected the failed removal to be logged, got %q", logs.String())
+	}
+}
+
+func TestRunReportsWorkerErrorsWhilePolling(t *testing.T) {
+	good, goodTS := newFakeWorker(t, nil)
+	_, badTS := newFakeWorker(t, map[string]string{
+		"GET /api/scenarios/part": "no scenario with id part",
+	})
+	co, err := New(testScenario(t), []string{goodTS.URL, badTS.URL},
+		WithStartDelay(0),
+		WithPollInterval(10*time.Millisecond),
+		WithLogger(slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = co.Run(ctx)
+	if err == nil || !strings.Contains(err.Error(), "no scenario with id part") {
+		t.Fatalf("expected the worker's polling error, got %v", err)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("expected polling to give up on the failing worker, not wait for the timeout")
+	}
+	if !good.called("POST /api/scenarios/part/stop") {
+		t.Error("expected the other worker to be stopped")
+	}
+	if !good.called("DELETE /api/scenarios/part") {
+		t.Error("expected the other worker to be collected and removed")
+	}
+}
+
+func TestCallDecodesWorkerErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"api error", http.StatusNotFound, `{"code":404,"message":"no scenario with id x"}`, "no scenario with id x"},
+		{"api error with ok status", http.StatusOK, `{"code":409,"message":"already finished"}`, "already finished"},
+		{"not json", http.StatusBadGateway, `<html>bad gateway</html>`, "502 Bad Gateway"},
+		{"success", http.StatusOK, `{"id":"x"}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer ts.Close()
+			co, err := New(testScenario(t), []string{ts.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var st workerStatus
+			err = co.call(context.Background(), http.MethodGet, ts.URL+"/api/scenarios/x", nil, &st)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if st.ID != "x" {
+					t.Errorf("expected the response to be decoded, got %+v", st)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected an error with %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package logmaker
+
+import (
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// names of the value distributions a FieldSpec can use
+const (
+	DistributionUniform    = "uniform"
+	DistributionZipf       = "zipf"
+	DistributionSequential = "sequential"
+)
+
+// Distributions lists every supported value distribution.
+var Distributions = []string{DistributionUniform, DistributionZipf, DistributionSequential}
+
+// FieldKinds lists the kinds of value a FieldSpec can take besides its
+// "<name>-<n>" default.
+var FieldKinds = []string{FieldKindIPv4}
+
+// FieldKindIPv4 renders the nth value of a field as an IPv4 address.
+const FieldKindIPv4 = "ipv4"
+
+// FieldSpec declares an extra structured field attached to every event. The
+// field takes Cardinality distinct values, "<name>-0" to "<name>-<n-1>" or,
+// with Kind, values of that kind, chosen according to Distribution. Values,
+// when set, are the field's values instead, and Cardinality is their number.
+type FieldSpec struct {
+	Name         string   `json:"name"`
+	Cardinality  int      `json:"cardinality,omitempty"`
+	Distribution string   `json:"distribution,omitempty"`
+	Values       []string `json:"values,omitempty"`
+	Kind         string   `json:"kind,omitempty"`
+}
+
+// ParseFieldSpecs parses a comma separated list of
+// name:cardinality[:distribution[:kind]] entries, e.g.
+// "tenant:50:zipf,user_id:1000000,client_ip:500:zipf:ipv4", where the
+// cardinality can instead be a list of values separated by |, e.g.
+// "method:GET|POST|DELETE:zipf". Values may contain colons. The distribution
+// defaults to uniform.
+func ParseFieldSpecs(s string) ([]FieldSpec, error) {
+	var specs []FieldSpec
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		spec, err := parseFieldSpec(entry)
+		if err != nil {
+			return nil, err
+		}
+		if err := spec.validate(); err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+func parseFieldSpec(entry string) (FieldSpec, error) {
+	name, rest, ok := strings.Cut(entry, ":")
+	if !ok {
+		return FieldSpec{}, fmt.Errorf("field %q is not of the form name:cardinality[:distribution[:kind]]", entry)
+	}
+	spec := FieldSpec{Name: name, Distribution: DistributionUniform}
+	if strings.Contains(rest, "|") {
+		// only a known distribution can follow the values, which leaves
+		// colons free for the values themselves
+		if i := strings.LastIndexByte(rest, ':'); i >= 0 && slices.Contains(Distributions, strings.ToLower(rest[i+1:])) {
+			spec.Distribution = strings.ToLower(rest[i+1:])
+			rest = rest[:i]
+		}
+		spec.Values = strings.Split(rest, "|")
+		spec.Cardinality = len(spec.Values)
+		return spec, nil
+	}
+	parts := strings.Split(rest, ":")
+	if len(parts) > 3 {
+		return FieldSpec{}, fmt.Errorf("field %q is not of the form name:cardinality[:distribution[:kind]]", entry)
+	}
+	card, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return FieldSpec{}, fmt.Errorf("cardinality of field %q is not an integer or a list of values: %w", name, err)
+	}
+	spec.Cardinality = card
+	if len(parts) > 1 {
+		spec.Distribution = strings.ToLower(parts[1])
+	}
+	if len(parts) > 2 {
+		spec.Kind = strings.ToLower(parts[2])
+	}
+	return spec, nil
+}
+
+func (f FieldSpec) validate() error {
+	if f.Name == "" {
+		return fmt.Errorf("field name must not be empty")
+	}
+	switch {
+	case len(f.Values) > 0:
+		if f.Cardinality != 0 && f.Cardinality != len(f.Values) {
+			return fmt.Errorf("cardinality of field %q is %d but it has %d values", f.Name, f.Cardinality, len(f.Values))
+		}
+		if f.Kind != "" {
+			return fmt.Errorf("field %q can't have both values and a kind", f.Name)
+		}
+	case f.Cardinality < 1:
+		return fmt.Errorf("cardinality of field %q must be at least 1", f.Name)
+	}
+	if f.Kind != "" && !slices.Contains(FieldKinds, f.Kind) {
+		return fmt.Errorf("unknown kind %q for field %q, expected one of %s", f.Kind, f.Name, strings.Join(FieldKinds, ", "))
+	}
+	switch f.Distribution {
+	case "", DistributionUniform, DistributionZipf, DistributionSequential:
+		return nil
+	}
+	return fmt.Errorf("unknown distribution %q for field %q, expected one of %s", f.Distribution, f.Name, strings.Join(Distributions, ", "))
+}
+
+// cardinality is the number of distinct values the field takes.
+func (f FieldSpec) cardinality() int {
+	if len(f.Values) > 0 {
+		return len(f.Values)
+	}
+	return f.Cardinality
+}
+
+// fieldGen produces values for one FieldSpec. It is shared by all of the
+// goroutines writing for a LogMaker.
+type fieldGen struct {
+	spec FieldSpec
+	next atomic.Uint64
+
+	mu   sync.Mutex
+	zipf *rand.Zipf
+}
+
+func newFieldGen(spec FieldSpec) *fieldGen {
+	g := &fieldGen{spec: spec}
+	if card := spec.cardinality(); spec.Distribution == DistributionZipf && card > 1 {
+		// rand.Zipf is not safe for concurrent use, so it gets its own source and a lock
+		src := rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+		g.zipf = rand.NewZipf(src, 1.1, 1, uint64(card-1))
+	}
+	return g
+}
+
+func (g *fieldGen) value() uint64 {
+	card := uint64(g.spec.cardinality())
+	switch g.spec.Distribution {
+	case DistributionSequential:
+		return (g.next.Add(1) - 1) % card
+	case DistributionZipf:
+		if g.zipf == nil {
+			return 0
+		}
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return g.zipf.Uint64()
+	default:
+		return rand.Uint64N(card)
+	}
+}
+
+func (g *fieldGen) attr() slog.Attr {
+	n := g.value()
+	switch {
+	case len(g.spec.Values) > 0:
+		return slog.String(g.spec.Name, g.spec.Values[n])
+	case g.spec.Kind == FieldKindIPv4:
+		return slog.String(g.spec.Name, ipv4(n))
+	}
+	return slog.String(g.spec.Name, g.spec.Name+"-"+strconv.FormatUint(n, 10))
+}
+
+// publicOctets are first octets of blocks run by ISPs and clouds, so that
+// generated addresses look like those of real clients.
+var publicOctets = []uint32{23, 31, 45, 52, 66, 73, 81, 89, 101, 114, 121, 142, 154, 176, 185, 193, 203, 213}
+
+// ipv4 is the nth of a fixed series of addresses. Consecutive n are spread
+// over the address space, as client addresses would be, but the same n is
+// always the same address.
+func ipv4(n uint64) string {
+	// multiplying by an odd number is a bijection on 24 bit values, so the
+	// first 2^24 values each get their own last three octets
+	tail := uint32(n+1) * 2654435761 & 0xffffff
+	first := publicOctets[tail%uint32(len(publicOctets))]
+	return strconv.Itoa(int(first)) + "." + strconv.Itoa(int(tail>>16)) + "." + strconv.Itoa(int(tail>>8&0xff)) + "." + strconv.Itoa(int(tail&0xff))
+}
+
+func newFieldGens(specs []FieldSpec) []*fieldGen {
+	gens := make([]*fieldGen, 0, len(specs))
+	for _, spec := range specs {
+		gens = append(gens, newFieldGen(spec))
+	}
+	return gens
+}
+
+// CheckMessage reports a {name} in the message template tmpl that isn't one
+// of fields, which would be written out as it is.
+func CheckMessage(tmpl string, fields []FieldSpec) error {
+	for _, name := range messageFields(tmpl) {
+		if !slices.ContainsFunc(fields, func(f FieldSpec) bool { return f.Name == name }) {
+			return fmt.Errorf("message refers to field %q, which isn't one of the fields", name)
+		}
+	}
+	return nil
+}
+
+// messageFields are the names between braces in tmpl.
+func messageFields(tmpl string) []string {
+	var names []string
+	for {
+		start := strings.IndexByte(tmpl, '{')
+		if start < 0 {
+			return names
+		}
+		end := strings.IndexByte(tmpl[start:], '}')
+		if end < 0 {
+			return names
+		}
+		names = append(names, tmpl[start+1:start+end])
+		tmpl = tmpl[start+end+1:]
+	}
+}
+
+// expandMessage replaces each {name} in tmpl with the value of the attr of
+// that name, leaving those without one as they are.
+func expandMessage(tmpl string, attrs []slog.Attr) string {
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(tmpl, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(tmpl[start:], '}')
+		if end < 0 {
+			break
+		}
+		b.WriteString(tmpl[:start])
+		name, found := tmpl[start+1:start+end], false
+		for _, a := range attrs {
+			if a.Key == name {
+				b.WriteString(a.Value.String())
+				found = true
+				break
+			}
+		}
+		if !found {
+			b.WriteString(tmpl[start : start+end+1])
+		}
+		tmpl = tmpl[start+end+1:]
+	}
+	b.WriteString(tmpl)
+	return b.String()
+}
@@ -0,0 +1,140 @@
+package logmaker
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/netip"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseFieldSpecs(t *testing.T) {
+	specs, err := ParseFieldSpecs("tenant:50:zipf, user_id:1000000,pod:500:Sequential")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []FieldSpec{
+		{Name: "tenant", Cardinality: 50, Distribution: DistributionZipf},
+		{Name: "user_id", Cardinality: 1000000, Distribution: DistributionUniform},
+		{Name: "pod", Cardinality: 500, Distribution: DistributionSequential},
+	}
+	if len(specs) != len(want) {
+		t.Fatalf("expected %d specs, got %v", len(want), specs)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(specs[i], want[i]) {
+			t.Errorf("spec %d = %+v, want %+v", i, specs[i], want[i])
+		}
+	}
+	for _, bad := range []string{"tenant", "tenant:x", "tenant:0", "tenant:5:normal", ":5", "a:1:uniform:extra", "a:1:uniform:ipv4:extra"} {
+		if _, err := ParseFieldSpecs(bad); err == nil {
+			t.Errorf("expected ParseFieldSpecs(%q) to fail", bad)
+		}
+	}
+}
+
+func TestParseFieldSpecsWithValuesAndKinds(t *testing.T) {
+	specs, err := ParseFieldSpecs("method:GET|POST|DELETE:zipf,user:system:admin|system:kube-scheduler,client_ip:500:zipf:ipv4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []FieldSpec{
+		{Name: "method", Cardinality: 3, Distribution: DistributionZipf, Values: []string{"GET", "POST", "DELETE"}},
+		{Name: "user", Cardinality: 2, Distribution: DistributionUniform, Values: []string{"system:admin", "system:kube-scheduler"}},
+		{Name: "client_ip", Cardinality: 500, Distribution: DistributionZipf, Kind: FieldKindIPv4},
+	}
+	if !reflect.DeepEqual(specs, want) {
+		t.Errorf("got %+v, want %+v", specs, want)
+	}
+	if _, err := ParseFieldSpecs("client_ip:500:zipf:mac"); err == nil {
+		t.Error("expected an unknown kind to fail")
+	}
+}
+
+func TestFieldsTakeTheirValuesOrKind(t *testing.T) {
+	g := newFieldGen(FieldSpec{Name: "method", Values: []string{"GET", "POST"}})
+	for i := 0; i < 100; i++ {
+		if v := g.attr().Value.String(); v != "GET" && v != "POST" {
+			t.Fatalf("expected one of the values, got %q", v)
+		}
+	}
+	g = newFieldGen(FieldSpec{Name: "client_ip", Cardinality: 1000, Distribution: DistributionSequential, Kind: FieldKindIPv4})
+	seen := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		v := g.attr().Value.String()
+		if _, err := netip.ParseAddr(v); err != nil {
+			t.Fatalf("expected an address, got %q", v)
+		}
+		seen[v] = true
+	}
+	if len(seen) != 1000 {
+		t.Errorf("expected 1000 distinct addresses, got %d", len(seen))
+	}
+}
+
+func TestThatMessagesAreFilledInFromFields(t *testing.T) {
+	var buf bytes.Buffer
+	mkr := NewLogMaker(WithLogger(slog.New(slog.NewJSONHandler(&buf, HandlerOptions()))),
+		WithFields([]FieldSpec{{Name: "method", Values: []string{"PUT"}}, {Name: "status", Values: []string{"201"}}}),
+		WithMessage(`"{method} /cart HTTP/1.1" {status} {missing}`))
+	if err := WriteLog(mkr, "ignored"); err != nil {
+		t.Fatal(err)
+	}
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatal(err)
+	}
+	if msg := line["msg"]; msg != `"PUT /cart HTTP/1.1" 201 {missing}` {
+		t.Errorf("expected the template filled in, got %v", msg)
+	}
+	if err := CheckMessage(`{method} {missing}`, []FieldSpec{{Name: "method"}}); err == nil {
+		t.Error("expected a template naming a missing field to fail")
+	}
+}
+
+func TestFieldValuesStayWithinCardinality(t *testing.T) {
+	for _, dist := range Distributions {
+		g := newFieldGen(FieldSpec{Name: "pod", Cardinality: 7, Distribution: dist})
+		seen := map[uint64]bool{}
+		for i := 0; i < 1000; i++ {
+			v := g.value()
+			if v >= 7 {
+				t.Fatalf("%s value %d is outside the cardinality", dist, v)
+			}
+			seen[v] = true
+		}
+		if len(seen) < 2 {
+			t.Errorf("%s distribution only produced %d distinct values", dist, len(seen))
+		}
+	}
+}
+
+func TestSequentialFieldCyclesThroughValues(t *testing.T) {
+	g := newFieldGen(FieldSpec{Name: "pod", Cardinality: 3, Distribution: DistributionSequential})
+	var got []uint64
+	for i := 0; i < 5; i++ {
+		got = append(got, g.value())
+	}
+	if want := []uint64{0, 1, 2, 0, 1}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestThatLogMakerAttachesFields(t *testing.T) {
+	var buf bytes.Buffer
+	mkr := NewLogMaker(WithLogger(slog.New(slog.NewJSONHandler(&buf, HandlerOptions()))),
+		WithFields([]FieldSpec{{Name: "tenant", Cardinality: 5, Distribution: DistributionZipf}}))
+	if err := WriteLog(mkr, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatal(err)
+	}
+	if tenant, _ := line["tenant"].(string); !strings.HasPrefix(tenant, "tenant-") {
+		t.Errorf("expected a tenant field, got %v", line)
+	}
+}
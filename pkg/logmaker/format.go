@@ -0,0 +1,127 @@
+package logmaker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// names of the output formats NewHandler understands
+const (
+	FormatJSON  = "json"
+	FormatText  = "text"
+	FormatPlain = "plain"
+	FormatRaw   = "raw"
+)
+
+// TimestampKey is the attribute WriteLog adds to every event on top of the
+// handler's own time field.
+const TimestampKey = "Timestamp"
+
+// Formats lists every output format NewHandler understands, including the
+// schema presets.
+var Formats = []string{FormatJSON, FormatText, FormatPlain, FormatRaw, FormatECS, FormatOTel, FormatCloudWatch, FormatGCP, FormatCRI}
+
+// NewHandler returns a slog.Handler that writes generated events to w in the
+// named format. An empty format means json.
+func NewHandler(w io.Writer, format string) (slog.Handler, error) {
+	switch format {
+	case "", FormatJSON:
+		return slog.NewJSONHandler(w, HandlerOptions()), nil
+	case FormatText:
+		return slog.NewTextHandler(w, HandlerOptions()), nil
+	case FormatPlain:
+		return newPlainHandler(w), nil
+	case FormatRaw:
+		return newSchemaHandler(w, encodeRaw), nil
+	case FormatECS:
+		return newSchemaHandler(w, encodeECS), nil
+	case FormatOTel:
+		return newSchemaHandler(w, encodeOTel), nil
+	case FormatCloudWatch:
+		return newSchemaHandler(w, encodeCloudWatch), nil
+	case FormatGCP:
+		return newSchemaHandler(w, encodeGCP), nil
+	case FormatCRI:
+		return newSchemaHandler(w, encodeCRI), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q, expected one of %s", format, strings.Join(Formats, ", "))
+}
+
+// encodeRaw writes nothing but the message, so the message is the whole line.
+// Fuzzed messages such as broken JSON reach the sink exactly as generated.
+// Attributes, seq included, are dropped, so raw output can't be verified
+// against a sensitive data manifest.
+func encodeRaw(b *bytes.Buffer, r slog.Record, _ []slog.Attr) {
+	b.WriteString(r.Message)
+	b.WriteByte('\n')
+}
+
+// plainHandler writes "<time> <LEVEL> [key=value ...] <message>" without any
+// escaping, so multiline messages span several physical lines the way an
+// application writing straight to a file would produce them.
+type plainHandler struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+	attrs  []string
+}
+
+func newPlainHandler(w io.Writer) *plainHandler {
+	return &plainHandler{mu: &sync.Mutex{}, w: w}
+}
+
+func (h *plainHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= slog.LevelDebug
+}
+
+func (h *plainHandler) Handle(_ context.Context, r slog.Record) error {
+	attrs := append([]string{}, h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key != TimestampKey {
+			attrs = append(attrs, h.prefix+a.Key+"="+a.Value.String())
+		}
+		return true
+	})
+
+	var b strings.Builder
+	b.WriteString(r.Time.UTC().Format(time.RFC3339Nano))
+	b.WriteByte(' ')
+	b.WriteString(LevelName(r.Level))
+	b.WriteByte(' ')
+	if len(attrs) > 0 {
+		b.WriteByte('[')
+		b.WriteString(strings.Join(attrs, " "))
+		b.WriteString("] ")
+	}
+	b.WriteString(r.Message)
+	b.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+func (h *plainHandler) WithAttrs(as []slog.Attr) slog.Handler {
+	nh := *h
+	nh.attrs = append([]string{}, h.attrs...)
+	for _, a := range as {
+		nh.attrs = append(nh.attrs, h.prefix+a.Key+"="+a.Value.String())
+	}
+	return &nh
+}
+
+func (h *plainHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	nh := *h
+	nh.prefix = h.prefix + name + "."
+	return &nh
+}
@@ -0,0 +1,42 @@
+package logmaker
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNewHandlerRejectsUnknownFormat(t *testing.T) {
+	if _, err := NewHandler(&bytes.Buffer{}, "xml"); err == nil {
+		t.Errorf("expected unknown format to fail")
+	}
+}
+
+func TestPlainHandlerWritesMultilineMessagesRaw(t *testing.T) {
+	var buf bytes.Buffer
+	h, err := NewHandler(&buf, FormatPlain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mkr := NewLogMaker(WithLogger(slog.New(h)),
+		WithLevelMix(LevelMix{Fatal: 1}),
+		WithMultiline(MultilineOpts{Fraction: 1, Frames: 3, Kinds: []string{StackTraceJava}}))
+	if err := WriteLog(mkr, "boom"); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) < 8 {
+		t.Fatalf("expected a multiline event, got:\n%s", out)
+	}
+	if !strings.Contains(lines[0], " FATAL ") || !strings.HasSuffix(lines[0], ": boom") {
+		t.Errorf("unexpected first line %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "\tat com.") {
+		t.Errorf("expected a java frame on the second line, got %q", lines[1])
+	}
+	if strings.Contains(out, TimestampKey) {
+		t.Errorf("plain output should not repeat the timestamp attribute")
+	}
+}
@@ -0,0 +1,165 @@
+package logmaker
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// names of the mutations fuzz mode can apply to a message
+const (
+	FuzzMultibyte   = "multibyte"
+	FuzzEmoji       = "emoji"
+	FuzzRTL         = "rtl"
+	FuzzInvalidUTF8 = "invalid_utf8"
+	FuzzNUL         = "nul"
+	FuzzNewline     = "newline"
+	FuzzANSI        = "ansi"
+	FuzzLongLine    = "long_line"
+	FuzzBrokenJSON  = "broken_json"
+)
+
+// FuzzKinds lists every mutation fuzz mode knows.
+var FuzzKinds = []string{FuzzMultibyte, FuzzEmoji, FuzzRTL, FuzzInvalidUTF8, FuzzNUL, FuzzNewline, FuzzANSI, FuzzLongLine, FuzzBrokenJSON}
+
+// DefaultLongLineSize is the size of long_line messages when none is set.
+const DefaultLongLineSize = 1024 * 1024
+
+// FuzzOpts controls fuzz mode, which mixes malformed and unusual input into
+// generated messages. Encoders escape some of it, e.g. json turns invalid
+// UTF-8 into U+FFFD, so the plain, cri and raw formats are the ones that pass
+// every byte through to the sink.
+type FuzzOpts struct {
+	// Fraction of events, between 0 and 1, that get mutated.
+	Fraction float64 `json:"fraction"`
+	// Mix weights the mutations against each other. Empty weights them all
+	// equally.
+	Mix map[string]int `json:"mix,omitempty"`
+	// LongLineSize is the size in bytes of messages made by long_line.
+	LongLineSize int `json:"long_line_size"`
+}
+
+var (
+	fuzzMultibyte   = []string{"日本語のログメッセージ", "Größenänderung fehlgeschlagen", "Ελληνικά δεδομένα", "кириллица", "한국어 로그", "naïve café façade"}
+	fuzzEmoji       = []string{"🔥", "💥🚀", "👩‍👩‍👧‍👦", "🇺🇸🇯🇵", "🤷🏽‍♀️", "❤️‍🔥", "🧵"}
+	fuzzRTL         = []string{"مرحبا بالعالم", "שלום עולם", "\u202egnirts desrever\u202c", "abc \u200fעברית\u200e def"}
+	fuzzInvalidUTF8 = []string{"\xff\xfe", "\xc3\x28", "\xed\xa0\x80", "\xe2\x82", "\xf0\x28\x8c\xbc", "\x80"}
+	fuzzNewline     = []string{"\n", "\r\n", "\r", "\u2028", "\u2029", "\n\n\n"}
+	fuzzANSI        = []string{"\x1b[31m", "\x1b[1;33;41m", "\x1b[0m", "\x1b]0;pwned\x07", "\x1b[2J\x1b[H", "\x1b[?25l"}
+	fuzzBrokenJSON  = []string{
+		`{"user":"%s","nested":{"ids":[1,2,`,
+		`{"message":"%s`,
+		`{"message":"%s"}}`,
+		`{'message': '%s'}`,
+		`{"message":"%s",}`,
+		`[{"message":"%s"},`,
+		`{"message":"%s","count":NaN}`,
+	}
+)
+
+// ParseFuzzMix accepts a comma separated list of kind=weight entries, e.g.
+// "emoji=5,invalid_utf8=1,long_line=1". A bare kind gets a weight of 1.
+func ParseFuzzMix(s string) (map[string]int, error) {
+	mix := map[string]int{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kind, weight, hasWeight := strings.Cut(entry, "=")
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		if !slices.Contains(FuzzKinds, kind) {
+			return nil, fmt.Errorf("unknown fuzz kind %q, expected one of %s", kind, strings.Join(FuzzKinds, ", "))
+		}
+		w := 1
+		if hasWeight {
+			var err error
+			w, err = strconv.Atoi(strings.TrimSpace(weight))
+			if err != nil {
+				return nil, fmt.Errorf("weight for fuzz kind %q is not an integer: %w", kind, err)
+			}
+			if w < 0 {
+				return nil, fmt.Errorf("weight for fuzz kind %q is negative", kind)
+			}
+		}
+		mix[kind] = w
+	}
+	return mix, nil
+}
+
+// pickFuzzKind chooses a mutation, weighted by mix.
+func pickFuzzKind(mix map[string]int) string {
+	if len(mix) == 0 {
+		return pick(FuzzKinds)
+	}
+	// iterate in a fixed order so the weights mean the same thing every time
+	kinds := make([]string, 0, len(mix))
+	total := 0
+	for k, w := range mix {
+		kinds = append(kinds, k)
+		total += w
+	}
+	if total <= 0 {
+		return pick(FuzzKinds)
+	}
+	sort.Strings(kinds)
+	n := rand.IntN(total)
+	for _, k := range kinds {
+		if n < mix[k] {
+			return k
+		}
+		n -= mix[k]
+	}
+	return kinds[len(kinds)-1]
+}
+
+// Fuzz applies one mutation of the given kind to msg.
+func Fuzz(kind string, msg string, longLineSize int) string {
+	switch kind {
+	case FuzzMultibyte:
+		return insertAtWordBoundary(msg, pick(fuzzMultibyte))
+	case FuzzEmoji:
+		return insertAtWordBoundary(msg, pick(fuzzEmoji))
+	case FuzzRTL:
+		return insertAtWordBoundary(msg, pick(fuzzRTL))
+	case FuzzInvalidUTF8:
+		return insertAtWordBoundary(msg, pick(fuzzInvalidUTF8))
+	case FuzzNUL:
+		return insertAtWordBoundary(msg, "\x00")
+	case FuzzNewline:
+		return insertAtWordBoundary(msg, pick(fuzzNewline))
+	case FuzzANSI:
+		return pick(fuzzANSI) + msg + "\x1b[0m"
+	case FuzzLongLine:
+		if longLineSize <= 0 {
+			longLineSize = DefaultLongLineSize
+		}
+		if longLineSize <= len(msg) {
+			return msg
+		}
+		var b strings.Builder
+		b.Grow(longLineSize)
+		for b.Len() < longLineSize {
+			b.WriteString(msg)
+			b.WriteByte(' ')
+		}
+		return b.String()[:longLineSize]
+	case FuzzBrokenJSON:
+		return fmt.Sprintf(pick(fuzzBrokenJSON), msg)
+	}
+	return msg
+}
+
+func insertAtWordBoundary(msg string, s string) string {
+	spaces := []int{0, len(msg)}
+	for i := 0; i < len(msg); i++ {
+		if msg[i] == ' ' {
+			spaces = append(spaces, i+1)
+		}
+	}
+	at := spaces[rand.IntN(len(spaces))]
+	return msg[:at] + s + msg[at:]
+}
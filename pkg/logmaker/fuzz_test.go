@@ -0,0 +1,73 @@
+package logmaker
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestParseFuzzMix(t *testing.T) {
+	mix, err := ParseFuzzMix("emoji=5, invalid_utf8=1,nul")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mix[FuzzEmoji] != 5 || mix[FuzzInvalidUTF8] != 1 || mix[FuzzNUL] != 1 || len(mix) != 3 {
+		t.Errorf("unexpected mix %v", mix)
+	}
+	for _, bad := range []string{"unicorn=1", "emoji=x", "emoji=-1"} {
+		if _, err := ParseFuzzMix(bad); err == nil {
+			t.Errorf("expected %q to fail to parse", bad)
+		}
+	}
+}
+
+func TestPickFuzzKindHonoursWeights(t *testing.T) {
+	mix := map[string]int{FuzzEmoji: 1, FuzzNUL: 0}
+	for i := 0; i < 100; i++ {
+		if kind := pickFuzzKind(mix); kind != FuzzEmoji {
+			t.Fatalf("picked %s with a weight of 0", kind)
+		}
+	}
+}
+
+func TestFuzzMutations(t *testing.T) {
+	msg := "the quick brown fox"
+	checks := map[string]func(string) bool{
+		FuzzMultibyte:   func(s string) bool { return utf8.ValidString(s) && utf8.RuneCountInString(s) < len(s) },
+		FuzzEmoji:       func(s string) bool { return utf8.ValidString(s) && len(s) > len(msg) },
+		FuzzRTL:         func(s string) bool { return utf8.ValidString(s) && len(s) > len(msg) },
+		FuzzInvalidUTF8: func(s string) bool { return !utf8.ValidString(s) },
+		FuzzNUL:         func(s string) bool { return strings.Contains(s, "\x00") },
+		FuzzNewline:     func(s string) bool { return strings.ContainsAny(s, "\r\n\u2028\u2029") },
+		FuzzANSI:        func(s string) bool { return strings.Contains(s, "\x1b") },
+		FuzzLongLine:    func(s string) bool { return len(s) == 4096 },
+		FuzzBrokenJSON:  func(s string) bool { return strings.ContainsAny(s, "{[") && strings.Contains(s, msg) },
+	}
+	for _, kind := range FuzzKinds {
+		for i := 0; i < 20; i++ {
+			if got := Fuzz(kind, msg, 4096); !checks[kind](got) {
+				t.Fatalf("%s: unexpected mutation %q", kind, got)
+			}
+		}
+	}
+}
+
+func TestThatRawFormatPassesFuzzedBytesThrough(t *testing.T) {
+	var out bytes.Buffer
+	h, _ := NewHandler(&out, FormatRaw)
+	mkr := NewLogMaker(WithLogger(slog.New(h)),
+		WithFuzz(FuzzOpts{Fraction: 1, Mix: map[string]int{FuzzInvalidUTF8: 1}}))
+	for i := 0; i < 10; i++ {
+		if err := WriteLog(mkr, "hello world"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if utf8.Valid(out.Bytes()) {
+		t.Errorf("expected invalid UTF-8 to reach the sink, got %q", out.String())
+	}
+	if mkr.Stats().Fuzzed != 10 {
+		t.Errorf("expected 10 fuzzed events, got %d", mkr.Stats().Fuzzed)
+	}
+}
@@ -0,0 +1,169 @@
+package logmaker
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+)
+
+// LevelFatal sits above slog.LevelError. slog has no fatal level of its own,
+// so handlers need ReplaceLevelAttr to render it as "FATAL" instead of "ERROR+4".
+const LevelFatal = slog.Level(12)
+
+// mixLevels is the order used for positional level mixes, e.g. "80/15/4/1".
+var mixLevels = []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError, LevelFatal}
+
+// LevelMix is a weighted distribution of log levels. Weights are relative,
+// so 80/15/4/1 and 160/30/8/2 describe the same mix.
+type LevelMix struct {
+	Debug int `json:"debug"`
+	Info  int `json:"info"`
+	Warn  int `json:"warn"`
+	Error int `json:"error"`
+	Fatal int `json:"fatal"`
+}
+
+// DefaultLevelMix logs everything at info, which is what logwild always did.
+var DefaultLevelMix = LevelMix{Info: 1}
+
+func (m LevelMix) weights() []int {
+	return []int{m.Debug, m.Info, m.Warn, m.Error, m.Fatal}
+}
+
+func (m LevelMix) total() int {
+	total := 0
+	for _, w := range m.weights() {
+		total += w
+	}
+	return total
+}
+
+// Pick returns a level at random, weighted by the mix.
+func (m LevelMix) Pick() slog.Level {
+	total := m.total()
+	if total <= 0 {
+		return slog.LevelInfo
+	}
+	n := rand.IntN(total)
+	for i, w := range m.weights() {
+		if n < w {
+			return mixLevels[i]
+		}
+		n -= w
+	}
+	return slog.LevelInfo
+}
+
+func (m LevelMix) String() string {
+	parts := make([]string, 0, len(mixLevels))
+	for i, w := range m.weights() {
+		parts = append(parts, fmt.Sprintf("%s=%d", strings.ToLower(LevelName(mixLevels[i])), w))
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseLevelMix accepts either a positional mix such as "80/15/4/1", read in
+// DEBUG/INFO/WARN/ERROR/FATAL order with missing trailing weights set to zero,
+// or a named mix such as "info=80,warn=15,error=4,fatal=1".
+func ParseLevelMix(s string) (LevelMix, error) {
+	var weights [5]int
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return LevelMix{}, errors.New("level mix is empty")
+	}
+	if strings.Contains(s, "=") {
+		for _, part := range strings.Split(s, ",") {
+			name, val, ok := strings.Cut(strings.TrimSpace(part), "=")
+			if !ok {
+				return LevelMix{}, fmt.Errorf("level mix entry %q is not of the form level=weight", part)
+			}
+			idx := levelIndex(name)
+			if idx < 0 {
+				return LevelMix{}, fmt.Errorf("unknown level %q in level mix", name)
+			}
+			w, err := strconv.Atoi(strings.TrimSpace(val))
+			if err != nil {
+				return LevelMix{}, fmt.Errorf("weight for level %q is not an integer: %w", name, err)
+			}
+			weights[idx] = w
+		}
+	} else {
+		parts := strings.Split(s, "/")
+		if len(parts) > len(weights) {
+			return LevelMix{}, fmt.Errorf("level mix %q has more than %d weights", s, len(weights))
+		}
+		for i, part := range parts {
+			w, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				return LevelMix{}, fmt.Errorf("level mix weight %q is not an integer: %w", part, err)
+			}
+			weights[i] = w
+		}
+	}
+	mix := LevelMix{weights[0], weights[1], weights[2], weights[3], weights[4]}
+	for _, w := range weights {
+		if w < 0 {
+			return LevelMix{}, fmt.Errorf("level mix %q has a negative weight", s)
+		}
+	}
+	if mix.total() == 0 {
+		return LevelMix{}, fmt.Errorf("level mix %q has no positive weights", s)
+	}
+	return mix, nil
+}
+
+func levelIndex(name string) int {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return 0
+	case "info":
+		return 1
+	case "warn", "warning":
+		return 2
+	case "error":
+		return 3
+	case "fatal":
+		return 4
+	}
+	return -1
+}
+
+// ParseLevel reads a level name such as "warn" or "FATAL".
+func ParseLevel(name string) (slog.Level, error) {
+	i := levelIndex(name)
+	if i < 0 {
+		return 0, fmt.Errorf("unknown level %q, expected debug, info, warn, error or fatal", name)
+	}
+	return mixLevels[i], nil
+}
+
+// LevelName is slog.Level.String, except that LevelFatal is called "FATAL".
+func LevelName(l slog.Level) string {
+	if l == LevelFatal {
+		return "FATAL"
+	}
+	return l.String()
+}
+
+// ReplaceLevelAttr is meant for slog.HandlerOptions.ReplaceAttr so that
+// generated fatal events are labelled "FATAL" in the output.
+func ReplaceLevelAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && a.Key == slog.LevelKey {
+		if l, ok := a.Value.Any().(slog.Level); ok {
+			a.Value = slog.StringValue(LevelName(l))
+		}
+	}
+	return a
+}
+
+// HandlerOptions are the slog handler options generated output should use:
+// every level in a mix is let through and fatal events render properly.
+func HandlerOptions() *slog.HandlerOptions {
+	return &slog.HandlerOptions{
+		Level:       slog.LevelDebug,
+		ReplaceAttr: ReplaceLevelAttr,
+	}
+}
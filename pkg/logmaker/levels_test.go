@@ -0,0 +1,45 @@
+package logmaker
+
+import (
+	"log/slog"
+	"testing"
+)
+
+func TestParseLevelMix(t *testing.T) {
+	cases := []struct {
+		in   string
+		want LevelMix
+	}{
+		{"80/15/4/1", LevelMix{Debug: 80, Info: 15, Warn: 4, Error: 1}},
+		{"0/80/15/4/1", LevelMix{Info: 80, Warn: 15, Error: 4, Fatal: 1}},
+		{"info=80, warn=15,error=4,fatal=1", LevelMix{Info: 80, Warn: 15, Error: 4, Fatal: 1}},
+		{"WARNING=3", LevelMix{Warn: 3}},
+	}
+	for _, c := range cases {
+		got, err := ParseLevelMix(c.in)
+		if err != nil {
+			t.Errorf("ParseLevelMix(%q) returned error %s", c.in, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("ParseLevelMix(%q) = %+v, want %+v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestParseLevelMixRejectsBadInput(t *testing.T) {
+	for _, in := range []string{"", "a/b", "1/2/3/4/5/6", "trace=1", "info=-1", "0/0", "info"} {
+		if _, err := ParseLevelMix(in); err == nil {
+			t.Errorf("expected ParseLevelMix(%q) to fail", in)
+		}
+	}
+}
+
+func TestLevelMixPickOnlyUsesWeightedLevels(t *testing.T) {
+	mix := LevelMix{Debug: 1, Error: 1}
+	for i := 0; i < 1000; i++ {
+		if l := mix.Pick(); l != slog.LevelDebug && l != slog.LevelError {
+			t.Fatalf("picked level %s which has no weight", l)
+		}
+	}
+}
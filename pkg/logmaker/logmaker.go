@@ -1,9 +1,16 @@
 package logmaker
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
+	"math/rand/v2"
+	"reflect"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 const (
@@ -11,17 +18,61 @@ const (
 	microsPerMilli  int = 1000
 )
 
+// DefaultMaxInFlight is how many events can be being written at once before
+// new ones are dropped.
+const DefaultMaxInFlight = 10000
+
 type OptFunc func(*Opts)
 
 type Opts struct {
 	PerSecondRate  int64
 	PerMessageSize int64
 	BurstDuration  time.Duration
-	Logger         *slog.Logger
+	LevelMix       LevelMix
+	Multiline      MultilineOpts
+	Fields         []FieldSpec
+	// Message, when set, is the message of every event in place of the
+	// message passed to WriteLog, with each {name} in it replaced by the
+	// event's value of the field of that name, e.g.
+	// `{client_ip} "GET {path} HTTP/1.1" {status}`.
+	Message   string
+	Traces    TraceOpts
+	Sensitive SensitiveOpts
+	Fuzz      FuzzOpts
+	// SpanProcessor exports synthetic traces. Every service gets its own
+	// TracerProvider feeding it, so backends see each service apart. When
+	// nil, spans still get ids but are not exported anywhere.
+	SpanProcessor sdktrace.SpanProcessor
+	// ParentContext is the parent of synthetic traces when Traces.Inherit is set.
+	ParentContext context.Context
+	// Logger receives the generated events.
+	Logger *slog.Logger
+	// Diagnostics receives messages about the LogMaker itself, such as
+	// ticker settings and effective rates, so they stay out of the output.
+	Diagnostics *slog.Logger
+	// Observer, when set, is told about every write, drop and second of a
+	// run, e.g. to export metrics.
+	Observer Observer
+	// MaxInFlight is the most events being written at once. Events due while
+	// that many are still being written are dropped, so a slow sink can't
+	// pile up goroutines.
+	MaxInFlight int
 }
 
 type LogMaker struct {
 	Opts
+	// mu guards Opts and fields, which Update can change while running
+	mu       sync.RWMutex
+	changed  chan struct{}
+	counters *counters
+	latency  *latencyHistogram
+	timeline *timeline
+	fields   []*fieldGen
+	traces   *traceSource
+	manifest *manifestWriter
+	// runID tells this LogMaker's events apart from those of other runs
+	// written to the same file, see RunKey
+	runID string
 }
 
 func defaultOpts() Opts {
@@ -29,7 +80,12 @@ func defaultOpts() Opts {
 		PerSecondRate:  1000,
 		PerMessageSize: 48,
 		BurstDuration:  5 * time.Second,
+		MaxInFlight:    DefaultMaxInFlight,
+		LevelMix:       DefaultLevelMix,
+		Multiline:      MultilineOpts{Frames: 8},
+		Fuzz:           FuzzOpts{LongLineSize: DefaultLongLineSize},
 		Logger:         slog.Default(),
+		Diagnostics:    slog.Default(),
 	}
 }
 
@@ -51,85 +107,390 @@ func WithBurstDuration(d time.Duration) OptFunc {
 	}
 }
 
+func WithMaxInFlight(n int) OptFunc {
+	return func(opts *Opts) {
+		opts.MaxInFlight = n
+	}
+}
+
+func WithObserver(o Observer) OptFunc {
+	return func(opts *Opts) {
+		opts.Observer = o
+	}
+}
+
+func WithLevelMix(m LevelMix) OptFunc {
+	return func(opts *Opts) {
+		opts.LevelMix = m
+	}
+}
+
+func WithMultiline(m MultilineOpts) OptFunc {
+	return func(opts *Opts) {
+		opts.Multiline = m
+	}
+}
+
+func WithMessage(tmpl string) OptFunc {
+	return func(opts *Opts) {
+		opts.Message = tmpl
+	}
+}
+
+func WithFields(fields []FieldSpec) OptFunc {
+	return func(opts *Opts) {
+		opts.Fields = fields
+	}
+}
+
+func WithSensitive(so SensitiveOpts) OptFunc {
+	return func(opts *Opts) {
+		opts.Sensitive = so
+	}
+}
+
+func WithFuzz(f FuzzOpts) OptFunc {
+	return func(opts *Opts) {
+		opts.Fuzz = f
+	}
+}
+
+func WithTraces(t TraceOpts) OptFunc {
+	return func(opts *Opts) {
+		opts.Traces = t
+	}
+}
+
+func WithSpanProcessor(sp sdktrace.SpanProcessor) OptFunc {
+	return func(opts *Opts) {
+		opts.SpanProcessor = sp
+	}
+}
+
+func WithParentContext(ctx context.Context) OptFunc {
+	return func(opts *Opts) {
+		opts.ParentContext = ctx
+	}
+}
+
 func WithLogger(l *slog.Logger) OptFunc {
 	return func(opts *Opts) {
 		opts.Logger = l
 	}
 }
 
+func WithDiagnosticLogger(l *slog.Logger) OptFunc {
+	return func(opts *Opts) {
+		opts.Diagnostics = l
+	}
+}
+
 func NewLogMaker(opts ...OptFunc) *LogMaker {
 	o := defaultOpts()
 	for _, fn := range opts {
 		fn(&o)
 	}
-	return &LogMaker{o}
+	lm := &LogMaker{
+		Opts:     o,
+		changed:  make(chan struct{}, 1),
+		counters: &counters{},
+		latency:  &latencyHistogram{},
+		timeline: &timeline{},
+		fields:   newFieldGens(o.Fields),
+		manifest: newManifestWriter(o.Sensitive.Manifest),
+		runID:    fmt.Sprintf("%016x", rand.Uint64()),
+	}
+	if o.Traces.Enabled {
+		lm.traces = newTraceSource(o.Traces, o.SpanProcessor, o.ParentContext)
+	}
+	return lm
+}
+
+// Update applies opts to a LogMaker that may be running. Rate, message size,
+// message template, level mix, fields, multiline, fuzz and sensitive fraction changes, and a new
+// Logger, take effect from the next event. Traces, the sensitive data
+// manifest and BurstDuration are fixed once the LogMaker is made. Fields keep
+// their sequences and distributions going unless the update changes them.
+func (lm *LogMaker) Update(opts ...OptFunc) {
+	lm.mu.Lock()
+	fields := lm.Fields
+	for _, fn := range opts {
+		fn(&lm.Opts)
+	}
+	if !reflect.DeepEqual(fields, lm.Fields) {
+		lm.fields = newFieldGens(lm.Fields)
+	}
+	lm.mu.Unlock()
+	// wake up the rate engine so a new rate applies right away
+	select {
+	case lm.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Options returns the LogMaker's current options.
+func (lm *LogMaker) Options() Opts {
+	lm.mu.RLock()
+	defer lm.mu.RUnlock()
+	return lm.Opts
+}
+
+func (lm *LogMaker) current() (Opts, []*fieldGen) {
+	lm.mu.RLock()
+	defer lm.mu.RUnlock()
+	return lm.Opts, lm.fields
+}
+
+// Stats returns counts of the events written so far, in total and per level.
+func (lm *LogMaker) Stats() Stats {
+	return lm.counters.snapshot()
 }
 
 func (lm *LogMaker) StartWriting(done chan int) error {
+	startTime := time.Now()
+	err := lm.Run(context.Background())
+	stats := lm.Stats()
+	done <- int(stats.LogCount)
+	// calculate effective logging rates and return them?
+	timeSpentSeconds := time.Since(startTime).Seconds()
+	lm.Diagnostics.Info("completed burst",
+		"timeSpentSeconds", fmt.Sprintf("%.2f", timeSpentSeconds),
+		"logCount", stats.LogCount,
+		"levels", stats.Levels)
+	effectiveRateMessages := float64(stats.LogCount) / time.Since(startTime).Seconds()
+	effectiveRateMbs := (effectiveRateMessages * float64(lm.Options().PerMessageSize) * 9) / (1024 * 1024)
+	lm.Diagnostics.Info(fmt.Sprintf("Effective logging rate: %.2f logs per second", effectiveRateMessages))
+	lm.Diagnostics.Info(fmt.Sprintf("Effective logging rate (Mb/s): %.2f Mb per second", effectiveRateMbs))
+	return err
+}
+
+// Run writes events until BurstDuration has passed or ctx is done. A
+// BurstDuration of 0 writes until ctx is done. Run stops early, returning the
+// error, if an event can't be written.
+func (lm *LogMaker) Run(ctx context.Context) error {
+	var inflight sync.WaitGroup
+	var failOnce sync.Once
+	var writeErr error
+	failed := make(chan struct{})
+	lm.timeline.start(lm.counters.lines.Load())
+	sampled := make(chan struct{})
+	stopSampling := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		tickr := time.NewTicker(time.Second)
+		defer tickr.Stop()
+		for {
+			select {
+			case <-tickr.C:
+				o := lm.Options()
+				smp := lm.timeline.sample(o.PerSecondRate, lm.counters.lines.Load())
+				if o.Observer != nil {
+					o.Observer.Second(smp)
+				}
+			case <-stopSampling:
+				return
+			}
+		}
+	}()
+	var writing atomic.Int64
+	lm.pace(ctx, lm.BurstDuration, func() bool {
+		select {
+		case <-failed:
+			return false
+		default:
+		}
+		o := lm.Options()
+		if o.MaxInFlight > 0 && writing.Load() >= int64(o.MaxInFlight) {
+			lm.counters.dropped.Add(1)
+			if o.Observer != nil {
+				o.Observer.Dropped()
+			}
+			return true
+		}
+		writing.Add(1)
+		inflight.Add(1)
+		go func() {
+			defer inflight.Done()
+			defer writing.Add(-1)
+			// a message template replaces the sentence, so don't make one
+			var sampleMessage string
+			if o.Message == "" {
+				sampleMessage = GetFakeSentence(int(o.PerMessageSize))
+			}
+			if err := WriteLog(lm, sampleMessage); err != nil {
+				failOnce.Do(func() {
+					writeErr = err
+					close(failed)
+				})
+			}
+		}()
+		return true
+	})
+	// wait for writes that are still in flight so the count is complete
+	inflight.Wait()
+	close(stopSampling)
+	<-sampled
+	lm.timeline.finish(lm.Options().PerSecondRate, lm.counters.lines.Load())
+	return writeErr
+}
+
+// pace calls emit PerSecondRate times per second until limit has passed, ctx
+// is done, or emit returns false because it has nothing left to write. A
+// limit of 0 means no limit.
+func (lm *LogMaker) pace(ctx context.Context, limit time.Duration, emit func() bool) {
+	diag := lm.Options().Diagnostics
+	tickDuration, logsPerTick := lm.tickSettings()
+	tickr := time.NewTicker(tickDuration)
+	defer tickr.Stop()
+	lastTick := time.Now()
+	// a nil channel never fires, so without a limit only ctx ends the loop
+	var expired <-chan time.Time
+	if limit > 0 {
+		deadline := time.NewTimer(limit)
+		defer deadline.Stop()
+		expired = deadline.C
+	}
+	// carries the fractional part of logsPerTick over to the next tick, so
+	// rates that don't divide evenly into ticks are still met
+	owed := float64(0)
+
+	// write logsPerTick each tick
+	for {
+		select {
+		case elem := <-tickr.C:
+			// credit the time since the last tick rather than one tick, since
+			// the ticker drops ticks when emitting takes longer than a tick
+			owed += logsPerTick * float64(elem.Sub(lastTick)) / float64(tickDuration)
+			lastTick = elem
+			for ; owed >= 1; owed-- {
+				diag.Debug("processing tick", "elem", elem)
+				if !emit() {
+					return
+				}
+			}
+		case <-lm.changed:
+			// credit the part of the tick that already passed at the old rate,
+			// or frequent updates to a slow rate would never let a tick fire
+			now := time.Now()
+			owed += logsPerTick * float64(now.Sub(lastTick)) / float64(tickDuration)
+			lastTick = now
+			tickDuration, logsPerTick = lm.tickSettings()
+			tickr.Reset(tickDuration)
+		case <-expired:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// tickSettings works out how often the rate engine ticks and how many events
+// it writes per tick to reach PerSecondRate. A rate of 0 or less writes
+// nothing.
+func (lm *LogMaker) tickSettings() (time.Duration, float64) {
+	o := lm.Options()
+	if o.PerSecondRate <= 0 {
+		// paused, but keep ticking so a later Update is picked up
+		return 100 * time.Millisecond, 0
+	}
 	// calculate duration based on PerSecondRate in cfg
 	// just always use microsecond precision
 	// microseconds between ticks
-	microsPerEvent := float64(microsPerSecond) / float64(lm.PerSecondRate)
-	lm.Logger.Debug("about to start logMaker", "microsPerEvent", microsPerEvent, "perSecondRate", lm.PerSecondRate)
-	logsPerTick := float64(1) // how many logs need to be emitted per tick
-	var ticksPerSecond int64
+	microsPerEvent := float64(microsPerSecond) / float64(o.PerSecondRate)
+	o.Diagnostics.Debug("about to start logMaker", "microsPerEvent", microsPerEvent, "perSecondRate", o.PerSecondRate)
 	var tickDuration time.Duration
 	if microsPerEvent < 5*float64(microsPerMilli) {
 		// if each event is < 5ms we have to do some tricks
 		// maximum resolution of go ticker is about 1ms on unix
-		lm.Logger.Debug("microsPerEvent less than microsPerMilli", "microsPerMilli", microsPerMilli)
+		o.Diagnostics.Debug("microsPerEvent less than microsPerMilli", "microsPerMilli", microsPerMilli)
 		tickDuration = time.Duration(5 * time.Millisecond)
 	} else {
 		// if time between events is more than 1ms, don't worry about, it just
 		// do one log per tick.
 		tickDuration = time.Duration(microsPerEvent) * time.Microsecond
 	}
-	ticksPerSecond = int64(time.Second / tickDuration)
-	logsPerTick = float64(lm.PerSecondRate) / float64(ticksPerSecond)
-	tickr := time.NewTicker(tickDuration)
-	startTime := time.Now()
-	logCount := 0
-
-	lm.Logger.Info("ticker settings", "microsPerEvent", microsPerEvent, "tickDuration", tickDuration, "logsPerTick", logsPerTick, "ticksPerSecond", ticksPerSecond, "logsPerSecond", lm.PerSecondRate)
-
-	// write logsPerTick each tick
-	for {
-		select {
-		case elem := <-tickr.C:
-			for i := 0; i < int(logsPerTick); i++ {
-				lm.Logger.Debug("processing tick", "elem", elem)
-				// actually write the log, and throw up if we can't
-				go func() {
-					sampleMessage := GetFakeSentence(int(lm.PerMessageSize))
-					if err := WriteLog(lm, sampleMessage); err != nil {
-						panic(err)
-					}
-					logCount++
-				}()
-			}
-
-		default:
-			// if we have reached our time limit, we can stop. otherwise, just spin on select
-			if time.Since(startTime) >= lm.BurstDuration {
-				tickr.Stop()
-				done <- logCount
-				// calculate effective logging rates and return them?
-				timeSpentSeconds := time.Since(startTime).Seconds()
-				lm.Logger.Info("completed burst",
-					"timeSpentSeconds", fmt.Sprintf("%.2f", timeSpentSeconds),
-					"logCount", logCount)
-				effectiveRateMessages := float64(logCount) / time.Since(startTime).Seconds()
-				effectiveRateMbs := (effectiveRateMessages * float64(lm.PerMessageSize) * 9) / (1024 * 1024)
-				lm.Logger.Info(fmt.Sprintf("Effective logging rate: %.2f logs per second", effectiveRateMessages))
-				lm.Logger.Info(fmt.Sprintf("Effective logging rate (Mb/s): %.2f Mb per second", effectiveRateMbs))
-				return nil
-			}
-		}
-	}
+	ticksPerSecond := int64(time.Second / tickDuration)
+	logsPerTick := float64(o.PerSecondRate) / float64(ticksPerSecond)
+	o.Diagnostics.Info("ticker settings", "microsPerEvent", microsPerEvent, "tickDuration", tickDuration, "logsPerTick", logsPerTick, "ticksPerSecond", ticksPerSecond, "logsPerSecond", o.PerSecondRate)
+	return tickDuration, logsPerTick
 }
 
+// WriteLog writes msg, or the Message template when one is set, at a level
+// picked from the LogMaker's level mix, along with a value for each
+// configured field and, when synthetic traces are enabled, the ids of one of
+// their spans. Fractions of events, set by the
+// multiline, fuzz and sensitive data options, are written as stack traces,
+// are mangled with unusual or malformed input and get a fake secret or PII
+// value.
 func WriteLog(lm *LogMaker, msg string) error {
+	o, fields := lm.current()
 	logTime := time.Now().Format(time.RFC3339)
-	lm.Logger.Info(msg, "Timestamp", logTime)
+	level := o.LevelMix.Pick()
+	// an event the handler would drop isn't made, so it isn't counted in the
+	// stats or recorded in the sensitive data manifest either
+	ctx := context.Background()
+	h := o.Logger.Handler()
+	if !h.Enabled(ctx, level) {
+		return nil
+	}
+	seq := lm.counters.nextSeq()
+	fieldAttrs := make([]slog.Attr, 0, len(fields))
+	for _, f := range fields {
+		fieldAttrs = append(fieldAttrs, f.attr())
+	}
+	if o.Message != "" {
+		msg = expandMessage(o.Message, fieldAttrs)
+	}
+	if ml := o.Multiline; ml.Fraction > 0 && rand.Float64() < ml.Fraction {
+		kinds := ml.Kinds
+		if len(kinds) == 0 {
+			kinds = StackTraceKinds
+		}
+		msg = GetFakeStackTrace(pick(kinds), msg, ml.Frames)
+	}
+	if fo := o.Fuzz; fo.Fraction > 0 && rand.Float64() < fo.Fraction {
+		msg = Fuzz(pickFuzzKind(fo.Mix), msg, fo.LongLineSize)
+		lm.counters.fuzzed.Add(1)
+	}
+	// sensitive values go in last, so that multiline and fuzz can't cut or
+	// split them and leave the manifest with a value the event never held
+	if so := o.Sensitive; so.Fraction > 0 && rand.Float64() < so.Fraction {
+		kinds := so.Kinds
+		if len(kinds) == 0 {
+			kinds = SensitiveKinds
+		}
+		kind := pick(kinds)
+		value, fragment := GetFakeSensitive(kind)
+		msg = msg + " " + fragment
+		if err := lm.manifest.record(ManifestEntry{Run: lm.runID, Seq: seq, Kind: kind, Value: value}); err != nil {
+			return err
+		}
+		lm.counters.sensitive.Add(1)
+	}
+	attrs := make([]slog.Attr, 0, len(fields)+4)
+	attrs = append(attrs, slog.String(TimestampKey, logTime))
+	if o.Sensitive.Fraction > 0 {
+		attrs = append(attrs, slog.String(RunKey, lm.runID), slog.Uint64(SeqKey, seq))
+	}
+	attrs = append(attrs, fieldAttrs...)
+	if lm.traces != nil {
+		attrs = append(attrs, traceAttrs(lm.traces.next())...)
+	}
+	// go through the handler rather than Logger.LogAttrs, which drops write
+	// errors
+	r := slog.NewRecord(time.Now(), level, msg, 0)
+	r.AddAttrs(attrs...)
+	start := time.Now()
+	err := h.Handle(ctx, r)
+	took := time.Since(start)
+	lm.latency.observe(took)
+	if o.Observer != nil {
+		o.Observer.Wrote(took, err)
+	}
+	if err != nil {
+		lm.counters.writeErrors.Add(1)
+		return err
+	}
+	lm.counters.record(level)
 	return nil
 }
@@ -1,8 +1,13 @@
 package logmaker
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"log/slog"
 	"os"
+	"runtime"
+	"runtime/metrics"
 	"strings"
 	"testing"
 	"time"
@@ -106,3 +111,175 @@ func TestThatLogMakerAppendsToExistingFile(t *testing.T) {
 		t.Errorf("expected file to contain more than just the initial content, but it didn't")
 	}
 }
+
+func TestThatLogMakerCountsLevelsFromMix(t *testing.T) {
+	var buf bytes.Buffer
+	hdl := slog.NewJSONHandler(&buf, HandlerOptions())
+	mkr := NewLogMaker(WithLogger(slog.New(hdl)),
+		WithLevelMix(LevelMix{Warn: 1, Fatal: 1}))
+	for i := 0; i < 200; i++ {
+		if err := WriteLog(mkr, GetFakeSentence(4)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats := mkr.Stats()
+	if stats.LogCount != 200 {
+		t.Errorf("expected 200 logs, got %d", stats.LogCount)
+	}
+	if stats.Levels["warn"]+stats.Levels["fatal"] != 200 {
+		t.Errorf("expected only warn and fatal levels, got %v", stats.Levels)
+	}
+	if stats.Levels["warn"] == 0 || stats.Levels["fatal"] == 0 {
+		t.Errorf("expected both warn and fatal levels to be used, got %v", stats.Levels)
+	}
+	if strings.Contains(buf.String(), "ERROR+4") {
+		t.Errorf("expected fatal level to be rendered as FATAL")
+	}
+	if !strings.Contains(buf.String(), `"level":"FATAL"`) {
+		t.Errorf("expected output to contain fatal events")
+	}
+}
+
+func TestThatLogMakerOnlyCountsEventsTheHandlerTakes(t *testing.T) {
+	var buf, manifest bytes.Buffer
+	hdl := slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn})
+	mkr := NewLogMaker(WithLogger(slog.New(hdl)),
+		WithLevelMix(LevelMix{Info: 1, Warn: 1}),
+		WithSensitive(SensitiveOpts{Fraction: 1, Manifest: &manifest}))
+	for i := 0; i < 200; i++ {
+		if err := WriteLog(mkr, GetFakeSentence(4)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	written := int64(strings.Count(buf.String(), "\n"))
+	stats := mkr.Stats()
+	if written == 0 || written == 200 {
+		t.Fatalf("expected the handler to drop only the info events, it wrote %d", written)
+	}
+	if stats.LogCount != written || stats.Levels["warn"] != written || stats.Levels["info"] != 0 {
+		t.Errorf("expected only the %d written events to be counted, got %+v", written, stats)
+	}
+	if stats.Sensitive != written || int64(strings.Count(manifest.String(), `"kind"`)) != written {
+		t.Errorf("expected a manifest entry for each of the %d written events, got %d in stats and:\n%s", written, stats.Sensitive, manifest.String())
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestThatRunStopsWhenContextIsDone(t *testing.T) {
+	var buf bytes.Buffer
+	h, _ := NewHandler(&buf, FormatJSON)
+	mkr := NewLogMaker(WithLogger(slog.New(h)), WithPerSecondRate(1000), WithBurstDuration(0))
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := mkr.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected run without a burst duration to stop with its context, took %s", elapsed)
+	}
+	if mkr.Stats().LogCount == 0 {
+		t.Error("expected some logs to be written")
+	}
+}
+
+func TestThatSlowRunsWaitRatherThanSpin(t *testing.T) {
+	var buf bytes.Buffer
+	h, _ := NewHandler(&buf, FormatJSON)
+	mkr := NewLogMaker(WithLogger(slog.New(h)), WithPerSecondRate(5), WithBurstDuration(time.Second))
+	// the runtime only accounts CPU time at the end of a GC
+	cpu := []metrics.Sample{{Name: "/cpu/classes/user:cpu-seconds"}}
+	runtime.GC()
+	metrics.Read(cpu)
+	before := cpu[0].Value.Float64()
+	start := time.Now()
+	if err := mkr.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second || elapsed > 2*time.Second {
+		t.Errorf("expected run to stop after its burst duration, took %s", elapsed)
+	}
+	runtime.GC()
+	metrics.Read(cpu)
+	// a busy loop would keep a core busy for the whole burst
+	if used := cpu[0].Value.Float64() - before; used > 0.5 {
+		t.Errorf("expected a slow run to be mostly idle, used %.2fs of CPU", used)
+	}
+}
+
+func TestThatRunReportsWriteErrors(t *testing.T) {
+	h, _ := NewHandler(failingWriter{}, FormatJSON)
+	mkr := NewLogMaker(WithLogger(slog.New(h)), WithPerSecondRate(100), WithBurstDuration(5*time.Second))
+	start := time.Now()
+	if err := mkr.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Fatalf("expected the write error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected run to stop at the first failed write, took %s", elapsed)
+	}
+}
+
+func TestThatUpdateChangesRateWhileRunning(t *testing.T) {
+	var buf bytes.Buffer
+	h, _ := NewHandler(&buf, FormatJSON)
+	mkr := NewLogMaker(WithLogger(slog.New(h)), WithPerSecondRate(100), WithBurstDuration(0))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- mkr.Run(ctx) }()
+
+	time.Sleep(500 * time.Millisecond)
+	before := mkr.Stats().LogCount
+	mkr.Update(WithPerSecondRate(2000), WithLevelMix(LevelMix{Error: 1}))
+	time.Sleep(500 * time.Millisecond)
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	stats := mkr.Stats()
+	if after := stats.LogCount - before; after < 5*before {
+		t.Errorf("expected the higher rate to apply right away, wrote %d before and %d after", before, after)
+	}
+	if stats.Levels["error"] == 0 || mkr.Options().PerSecondRate != 2000 {
+		t.Errorf("expected the new level mix and rate to apply, got %+v", stats)
+	}
+}
+
+func TestThatRunRecordsRateSeriesAndLatency(t *testing.T) {
+	var buf bytes.Buffer
+	h, _ := NewHandler(&buf, FormatJSON)
+	mkr := NewLogMaker(WithLogger(slog.New(h)), WithPerSecondRate(100), WithBurstDuration(1500*time.Millisecond))
+	if err := mkr.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	rec := mkr.Record()
+	if rec.FinishedAt.IsZero() || len(rec.Series) != 2 {
+		t.Fatalf("expected a finished run with a full and a partial second, got %+v", rec)
+	}
+	if first := rec.Series[0]; first.Target != 100 || first.Achieved < 90 {
+		t.Errorf("expected ~100 events in the first second, got %+v", first)
+	}
+	if last := rec.Series[1]; last.Target < 40 || last.Target > 60 {
+		t.Errorf("expected the target of the last half second to be ~50, got %+v", last)
+	}
+	if rec.Latency.Count != rec.Stats.LogCount || rec.Latency.Quantile(0.99) <= 0 || rec.Latency.Quantile(0.99) > rec.Latency.Max {
+		t.Errorf("expected a latency for every write, got %d writes, p99 %s, max %s", rec.Latency.Count, rec.Latency.Quantile(0.99), rec.Latency.Max)
+	}
+}
+
+func TestThatLongRunsKeepTheirLatestSeconds(t *testing.T) {
+	var tl timeline
+	tl.start(0)
+	now := time.Now()
+	for i := 1; i <= maxTimelineSamples+10; i++ {
+		tl.add(100, int64(i*100), now.Add(time.Duration(i)*time.Second))
+	}
+	if len(tl.samples) != maxTimelineSamples {
+		t.Fatalf("expected the timeline to keep %d seconds, got %d", maxTimelineSamples, len(tl.samples))
+	}
+	if first, last := tl.samples[0], tl.samples[len(tl.samples)-1]; first.Second != 10 || last.Second != maxTimelineSamples+9 || last.Achieved != 100 {
+		t.Errorf("expected the latest seconds, numbered from the start, got %+v to %+v", first, last)
+	}
+}
@@ -0,0 +1,228 @@
+package logmaker
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// latencyGrowth is the ratio between the bounds of neighbouring latency
+// buckets, so percentiles are accurate to within 10%.
+const latencyGrowth = 1.1
+
+// latencyBuckets covers latencies up to about 100s; slower writes land in
+// the last bucket.
+const latencyBuckets = 270
+
+// latencyHistogram counts how long writes take without locking, so it can be
+// shared by all of the goroutines writing for one LogMaker.
+type latencyHistogram struct {
+	buckets [latencyBuckets]atomic.Int64
+	count   atomic.Int64
+	sum     atomic.Int64
+	max     atomic.Int64
+}
+
+func latencyBucket(d time.Duration) int {
+	if d <= 1 {
+		return 0
+	}
+	return min(int(math.Log(float64(d))/math.Log(latencyGrowth)), latencyBuckets-1)
+}
+
+// latencyBucketBound is the largest latency counted in bucket i.
+func latencyBucketBound(i int) time.Duration {
+	return time.Duration(math.Pow(latencyGrowth, float64(i+1)))
+}
+
+func (h *latencyHistogram) observe(d time.Duration) {
+	h.buckets[latencyBucket(d)].Add(1)
+	h.count.Add(1)
+	h.sum.Add(int64(d))
+	for {
+		old := h.max.Load()
+		if int64(d) <= old || h.max.CompareAndSwap(old, int64(d)) {
+			return
+		}
+	}
+}
+
+func (h *latencyHistogram) snapshot() WriteLatency {
+	wl := WriteLatency{
+		Buckets: make([]int64, latencyBuckets),
+		Count:   h.count.Load(),
+		Sum:     time.Duration(h.sum.Load()),
+		Max:     time.Duration(h.max.Load()),
+	}
+	for i := range h.buckets {
+		wl.Buckets[i] = h.buckets[i].Load()
+	}
+	return wl
+}
+
+// WriteLatency is a snapshot of how long events took to write.
+type WriteLatency struct {
+	// Buckets are counts of writes by latency, in buckets that grow by 10%.
+	Buckets []int64       `json:"buckets"`
+	Count   int64         `json:"count"`
+	Sum     time.Duration `json:"sum"`
+	Max     time.Duration `json:"max"`
+}
+
+// Merge adds the writes counted by other.
+func (wl *WriteLatency) Merge(other WriteLatency) {
+	if wl.Buckets == nil {
+		wl.Buckets = make([]int64, latencyBuckets)
+	}
+	for i, n := range other.Buckets {
+		wl.Buckets[i] += n
+	}
+	wl.Count += other.Count
+	wl.Sum += other.Sum
+	wl.Max = max(wl.Max, other.Max)
+}
+
+// Mean is the average write latency.
+func (wl WriteLatency) Mean() time.Duration {
+	if wl.Count == 0 {
+		return 0
+	}
+	return wl.Sum / time.Duration(wl.Count)
+}
+
+// Quantile estimates the latency that a fraction q of writes were at or
+// under, e.g. 0.99 for the 99th percentile.
+func (wl WriteLatency) Quantile(q float64) time.Duration {
+	if wl.Count == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(q * float64(wl.Count)))
+	var seen int64
+	for i, n := range wl.Buckets {
+		seen += n
+		if seen >= rank {
+			return min(latencyBucketBound(i), wl.Max)
+		}
+	}
+	return wl.Max
+}
+
+// Bounds returns the largest latency counted in each bucket.
+func (wl WriteLatency) Bounds() []time.Duration {
+	bounds := make([]time.Duration, len(wl.Buckets))
+	for i := range bounds {
+		bounds[i] = latencyBucketBound(i)
+	}
+	return bounds
+}
+
+// SecondSample is the rate a LogMaker aimed for and the number of events it
+// wrote in one second of a run. Second counts from the start of the run.
+type SecondSample struct {
+	Second   int   `json:"second"`
+	Target   int64 `json:"target"`
+	Achieved int64 `json:"achieved"`
+}
+
+// maxTimelineSamples is how many seconds of a run its timeline keeps, so a
+// run that goes on for days, like the continuous job, holds the latest hour.
+const maxTimelineSamples = 3600
+
+// timeline samples the target and achieved rate of a run once a second.
+type timeline struct {
+	mu         sync.Mutex
+	startedAt  time.Time
+	finishedAt time.Time
+	// samples are the latest maxTimelineSamples seconds
+	samples   []SecondSample
+	seconds   int
+	lastCount int64
+	lastAt    time.Time
+}
+
+func (tl *timeline) start(count int64) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	tl.startedAt = time.Now()
+	tl.finishedAt = time.Time{}
+	tl.samples = nil
+	tl.seconds = 0
+	tl.lastCount = count
+	tl.lastAt = tl.startedAt
+}
+
+func (tl *timeline) sample(target, count int64) SecondSample {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	return tl.add(target, count, time.Now())
+}
+
+func (tl *timeline) add(target, count int64, now time.Time) SecondSample {
+	smp := SecondSample{
+		Second:   tl.seconds,
+		Target:   target,
+		Achieved: count - tl.lastCount,
+	}
+	if len(tl.samples) == maxTimelineSamples {
+		tl.samples = append(tl.samples[:0], tl.samples[1:]...)
+	}
+	tl.samples = append(tl.samples, smp)
+	tl.seconds++
+	tl.lastCount = count
+	tl.lastAt = now
+	return smp
+}
+
+// finish records the last, partial, second of the run, with its target
+// scaled to the part of the second that passed.
+func (tl *timeline) finish(target, count int64) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	now := time.Now()
+	part := now.Sub(tl.lastAt).Seconds()
+	if count > tl.lastCount || part >= 0.05 {
+		tl.add(int64(math.Round(float64(target)*min(part, 1))), count, now)
+	}
+	tl.finishedAt = now
+}
+
+// Observer is told what a LogMaker does as it runs. Its methods are called
+// from the goroutines writing events, so they must be safe for concurrent use
+// and quick.
+type Observer interface {
+	// Wrote is called after each event is handed to the sink, with how long
+	// that took and the error if it failed.
+	Wrote(latency time.Duration, err error)
+	// Dropped is called for each event skipped because MaxInFlight events
+	// were still being written.
+	Dropped()
+	// Second is called with the target and achieved events of each second
+	// of a run.
+	Second(SecondSample)
+}
+
+// RunRecord is what a LogMaker recorded about its latest run, for reports.
+type RunRecord struct {
+	StartedAt time.Time `json:"started_at"`
+	// FinishedAt is zero while the run is going.
+	FinishedAt time.Time `json:"finished_at"`
+	// Series holds up to the last hour of the run.
+	Series  []SecondSample `json:"series"`
+	Latency WriteLatency   `json:"latency"`
+	Stats   Stats          `json:"stats"`
+}
+
+// Record returns what the LogMaker recorded about its latest run.
+func (lm *LogMaker) Record() RunRecord {
+	lm.timeline.mu.Lock()
+	rec := RunRecord{
+		StartedAt:  lm.timeline.startedAt,
+		FinishedAt: lm.timeline.finishedAt,
+		Series:     append([]SecondSample{}, lm.timeline.samples...),
+	}
+	lm.timeline.mu.Unlock()
+	rec.Latency = lm.latency.snapshot()
+	rec.Stats = lm.Stats()
+	return rec
+}
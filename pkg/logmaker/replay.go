@@ -0,0 +1,213 @@
+package logmaker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timing modes for Replay
+const (
+	// ReplayOriginal keeps the gaps between the timestamps of the captured
+	// lines, divided by ReplayOpts.Speed.
+	ReplayOriginal = "original"
+	// ReplayRate emits lines at the LogMaker's PerSecondRate.
+	ReplayRate = "rate"
+)
+
+// ReplayTimings lists the timing modes Replay understands.
+var ReplayTimings = []string{ReplayOriginal, ReplayRate}
+
+// ReplayOpts controls how Replay re-emits a captured log file.
+type ReplayOpts struct {
+	// Timing is ReplayOriginal or ReplayRate. Empty means ReplayOriginal.
+	Timing string `json:"timing"`
+	// Speed scales original timing, e.g. 2 replays twice as fast. Values
+	// of 0 or less mean 1.
+	Speed float64 `json:"speed"`
+	// RewriteTimestamps replaces the timestamp of every line with the time
+	// it is replayed at, keeping its layout.
+	RewriteTimestamps bool `json:"rewrite_timestamps"`
+	// InjectSeq adds a unique seq field to every line.
+	InjectSeq bool `json:"inject_seq"`
+}
+
+// replayTimestamp matches the RFC 3339 style timestamps nearly every log
+// format starts with or carries in its time field. The groups are the date,
+// the separator, the time, the fraction and the zone.
+var replayTimestamp = regexp.MustCompile(`(\d{4}-\d{2}-\d{2})([T ])(\d{2}:\d{2}:\d{2})(\.\d+)?(Z|[+-]\d{2}:?\d{2})?`)
+
+// replayLevel finds the first level name in a line, which is the level field
+// in the formats logwild writes and most others.
+var replayLevel = regexp.MustCompile(`(?i)\b(debug|info|warn|warning|error|fatal)\b`)
+
+// Replay reads lines from src and writes them to dst, paced according to ro.
+// Each line counts as one event in the LogMaker's stats, at the level found
+// in it. Lines are written in order, and Replay returns once src is used up
+// or ctx is done.
+func (lm *LogMaker) Replay(ctx context.Context, src io.Reader, dst io.Writer, ro ReplayOpts) error {
+	switch ro.Timing {
+	case "", ReplayOriginal:
+		return lm.replayOriginal(ctx, bufio.NewReader(src), dst, ro)
+	case ReplayRate:
+		return lm.replayRate(ctx, bufio.NewReader(src), dst, ro)
+	}
+	return fmt.Errorf("unknown replay timing %q, expected one of %s", ro.Timing, strings.Join(ReplayTimings, ", "))
+}
+
+func (lm *LogMaker) replayRate(ctx context.Context, src *bufio.Reader, dst io.Writer, ro ReplayOpts) error {
+	var err error
+	lm.pace(ctx, 0, func() bool {
+		var line []byte
+		line, err = readLine(src)
+		if err != nil {
+			return false
+		}
+		err = lm.replayLine(dst, line, ro)
+		return err == nil
+	})
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	return err
+}
+
+func (lm *LogMaker) replayOriginal(ctx context.Context, src *bufio.Reader, dst io.Writer, ro ReplayOpts) error {
+	speed := ro.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+	var first time.Time
+	start := time.Now()
+	for {
+		line, err := readLine(src)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// lines without a timestamp, such as stack trace frames, go out
+		// straight after the line before them
+		if ts, ok := lineTime(line); ok {
+			if first.IsZero() {
+				first = ts
+			}
+			due := start.Add(time.Duration(float64(ts.Sub(first)) / speed))
+			if wait := time.Until(due); wait > 0 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(wait):
+				}
+			}
+		}
+		if err := lm.replayLine(dst, line, ro); err != nil {
+			return err
+		}
+	}
+}
+
+func (lm *LogMaker) replayLine(dst io.Writer, line []byte, ro ReplayOpts) error {
+	if ro.RewriteTimestamps {
+		line = rewriteTimestamp(line, time.Now())
+	}
+	if ro.InjectSeq {
+		line = injectSeq(line, lm.counters.nextSeq())
+	}
+	if _, err := dst.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if m := replayLevel.FindSubmatch(line); m != nil {
+		if i := levelIndex(string(m[1])); i >= 0 {
+			lm.counters.record(mixLevels[i])
+			return nil
+		}
+	}
+	lm.counters.lines.Add(1)
+	return nil
+}
+
+// readLine returns the next line of r without its line ending. Lines can be
+// of any length, unlike with bufio.Scanner.
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadBytes('\n')
+	if len(line) > 0 {
+		line = bytes.TrimSuffix(line, []byte("\n"))
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		return line, nil
+	}
+	return nil, err
+}
+
+func lineTime(line []byte) (time.Time, bool) {
+	m := replayTimestamp.FindSubmatch(line)
+	if m == nil {
+		return time.Time{}, false
+	}
+	ts, err := time.Parse(timestampLayout(m), string(m[0]))
+	return ts, err == nil
+}
+
+// timestampLayout builds the time layout a replayTimestamp match was
+// written with, so rewritten timestamps look like the originals.
+func timestampLayout(m [][]byte) string {
+	layout := "2006-01-02" + string(m[2]) + "15:04:05"
+	if frac := m[4]; len(frac) > 0 {
+		layout += "." + strings.Repeat("0", len(frac)-1)
+	}
+	switch zone := m[5]; {
+	case len(zone) == 0:
+	case zone[0] == 'Z':
+		layout += "Z07:00"
+	case bytes.Contains(zone, []byte(":")):
+		layout += "-07:00"
+	default:
+		layout += "-0700"
+	}
+	return layout
+}
+
+func rewriteTimestamp(line []byte, now time.Time) []byte {
+	loc := replayTimestamp.FindSubmatchIndex(line)
+	if loc == nil {
+		return line
+	}
+	m := replayTimestamp.FindSubmatch(line)
+	layout := timestampLayout(m)
+	if ts, err := time.Parse(layout, string(m[0])); err == nil {
+		now = now.In(ts.Location())
+	}
+	out := make([]byte, 0, len(line)+8)
+	out = append(out, line[:loc[0]]...)
+	out = now.AppendFormat(out, layout)
+	return append(out, line[loc[1]:]...)
+}
+
+// injectSeq adds a seq field in the style of the line: as the first member
+// of a JSON object, otherwise as a trailing seq=N.
+func injectSeq(line []byte, seq uint64) []byte {
+	trimmed := bytes.TrimLeft(line, " \t")
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		at := len(line) - len(trimmed) + 1
+		field := `"` + SeqKey + `":` + strconv.FormatUint(seq, 10)
+		if len(bytes.TrimSpace(trimmed[1:])) > 1 {
+			field += ","
+		}
+		out := make([]byte, 0, len(line)+len(field))
+		out = append(out, line[:at]...)
+		out = append(out, field...)
+		return append(out, line[at:]...)
+	}
+	return append(line, " "+SeqKey+"="+strconv.FormatUint(seq, 10)...)
+}
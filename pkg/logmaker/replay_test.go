@@ -0,0 +1,66 @@
+package logmaker
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+const capturedLog = `{"time":"2024-03-01T10:00:00.000Z","level":"INFO","msg":"starting"}
+{"time":"2024-03-01T10:00:00.200Z","level":"WARN","msg":"slow"}
+2024-03-01T10:00:00.400+00:00 ERROR boom
+  at com.example.Main.run(Main.java:12)
+`
+
+func TestReplayKeepsScaledOriginalTiming(t *testing.T) {
+	var out bytes.Buffer
+	mkr := NewLogMaker()
+	start := time.Now()
+	err := mkr.Replay(context.Background(), strings.NewReader(capturedLog), &out, ReplayOpts{Speed: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 400ms of captured time at twice the speed
+	if elapsed := time.Since(start); elapsed < 180*time.Millisecond || elapsed > time.Second {
+		t.Errorf("expected replay to take about 200ms, took %s", elapsed)
+	}
+	if out.String() != capturedLog {
+		t.Errorf("expected lines to be replayed unchanged, got:\n%s", out.String())
+	}
+	stats := mkr.Stats()
+	if stats.LogCount != 4 || stats.Levels["info"] != 1 || stats.Levels["warn"] != 1 || stats.Levels["error"] != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestReplayAtFixedRateRewritesTimestampsAndInjectsSeq(t *testing.T) {
+	var out bytes.Buffer
+	mkr := NewLogMaker(WithPerSecondRate(1000))
+	err := mkr.Replay(context.Background(), strings.NewReader(capturedLog), &out,
+		ReplayOpts{Timing: ReplayRate, RewriteTimestamps: true, InjectSeq: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 lines, got %d:\n%s", len(lines), out.String())
+	}
+	if !strings.HasPrefix(lines[0], `{"seq":1,"time":"`+time.Now().UTC().Format("2006-01-02")) {
+		t.Errorf("expected seq and a current timestamp, got %s", lines[0])
+	}
+	if !strings.HasSuffix(lines[2], "+00:00 ERROR boom seq=3") {
+		t.Errorf("expected zone to be kept and seq appended, got %s", lines[2])
+	}
+	if strings.Contains(out.String(), "2024-03-01") {
+		t.Errorf("expected every timestamp to be rewritten, got:\n%s", out.String())
+	}
+}
+
+func TestReplayRejectsUnknownTiming(t *testing.T) {
+	err := NewLogMaker().Replay(context.Background(), strings.NewReader(capturedLog), &bytes.Buffer{}, ReplayOpts{Timing: "warp"})
+	if err == nil {
+		t.Error("expected unknown timing to fail")
+	}
+}
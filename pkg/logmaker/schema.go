@@ -0,0 +1,318 @@
+package logmaker
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// names of the schema presets NewHandler understands
+const (
+	FormatECS        = "ecs"
+	FormatOTel       = "otel"
+	FormatCloudWatch = "cloudwatch"
+	FormatGCP        = "gcp"
+	FormatCRI        = "cri"
+)
+
+// ecsVersion is the Elastic Common Schema version ECS events claim to follow.
+const ecsVersion = "8.11.0"
+
+// gcpProject is used to build the fully qualified trace names Cloud Logging expects.
+var gcpProject = cmp.Or(os.Getenv("GOOGLE_CLOUD_PROJECT"), "logwild")
+
+// criMaxLineSize is the size at which the kubelet splits a line into partial
+// CRI log entries.
+const criMaxLineSize = 16 * 1024
+
+// encodeFunc appends one event, including its trailing newline, to b.
+type encodeFunc func(b *bytes.Buffer, r slog.Record, attrs []slog.Attr)
+
+// schemaHandler writes events shaped to a well-known log schema. The event is
+// handed to encode with the handler's and the record's attributes flattened
+// into one list.
+type schemaHandler struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	encode encodeFunc
+	prefix string
+	attrs  []slog.Attr
+}
+
+func newSchemaHandler(w io.Writer, encode encodeFunc) *schemaHandler {
+	return &schemaHandler{mu: &sync.Mutex{}, w: w, encode: encode}
+}
+
+func (h *schemaHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= slog.LevelDebug
+}
+
+func (h *schemaHandler) Handle(_ context.Context, r slog.Record) error {
+	attrs := make([]slog.Attr, 0, len(h.attrs)+r.NumAttrs())
+	attrs = append(attrs, h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key != TimestampKey {
+			a.Key = h.prefix + a.Key
+			attrs = append(attrs, a)
+		}
+		return true
+	})
+	var b bytes.Buffer
+	h.encode(&b, r, attrs)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.w.Write(b.Bytes())
+	return err
+}
+
+func (h *schemaHandler) WithAttrs(as []slog.Attr) slog.Handler {
+	nh := *h
+	nh.attrs = append([]slog.Attr{}, h.attrs...)
+	for _, a := range as {
+		a.Key = h.prefix + a.Key
+		nh.attrs = append(nh.attrs, a)
+	}
+	return &nh
+}
+
+func (h *schemaHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	nh := *h
+	nh.prefix = h.prefix + name + "."
+	return &nh
+}
+
+// encodeECS follows the Elastic Common Schema.
+func encodeECS(b *bytes.Buffer, r slog.Record, attrs []slog.Attr) {
+	traceID, spanID, attrs := splitTraceAttrs(attrs)
+	b.WriteString(`{"@timestamp":`)
+	writeJSONString(b, r.Time.UTC().Format(time.RFC3339Nano))
+	b.WriteString(`,"log.level":`)
+	writeJSONString(b, strings.ToLower(LevelName(r.Level)))
+	b.WriteString(`,"message":`)
+	writeJSONString(b, r.Message)
+	b.WriteString(`,"ecs.version":"` + ecsVersion + `"`)
+	if traceID != "" {
+		b.WriteString(`,"trace.id":"` + traceID + `","span.id":"` + spanID + `"`)
+	}
+	writeJSONAttrs(b, attrs)
+	b.WriteString("}\n")
+}
+
+// encodeOTel follows the JSON mapping of the OpenTelemetry log data model as
+// used by OTLP/JSON log records.
+func encodeOTel(b *bytes.Buffer, r slog.Record, attrs []slog.Attr) {
+	traceID, spanID, attrs := splitTraceAttrs(attrs)
+	ts := strconv.FormatInt(r.Time.UnixNano(), 10)
+	b.WriteString(`{"timeUnixNano":"` + ts + `","observedTimeUnixNano":"` + ts + `"`)
+	b.WriteString(`,"severityNumber":` + strconv.Itoa(otelSeverityNumber(r.Level)))
+	b.WriteString(`,"severityText":`)
+	writeJSONString(b, LevelName(r.Level))
+	b.WriteString(`,"body":{"stringValue":`)
+	writeJSONString(b, r.Message)
+	b.WriteString(`},"attributes":[`)
+	for i, a := range attrs {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(`{"key":`)
+		writeJSONString(b, a.Key)
+		b.WriteString(`,"value":`)
+		writeOTelAnyValue(b, a.Value)
+		b.WriteByte('}')
+	}
+	b.WriteByte(']')
+	if traceID != "" {
+		b.WriteString(`,"traceId":"` + traceID + `","spanId":"` + spanID + `"`)
+	}
+	b.WriteString("}\n")
+}
+
+// encodeCloudWatch follows the AWS Lambda JSON log format as it lands in
+// CloudWatch Logs.
+func encodeCloudWatch(b *bytes.Buffer, r slog.Record, attrs []slog.Attr) {
+	b.WriteString(`{"timestamp":`)
+	writeJSONString(b, r.Time.UTC().Format("2006-01-02T15:04:05.000Z"))
+	b.WriteString(`,"level":`)
+	writeJSONString(b, LevelName(r.Level))
+	b.WriteString(`,"message":`)
+	writeJSONString(b, r.Message)
+	writeJSONAttrs(b, attrs)
+	b.WriteString("}\n")
+}
+
+// encodeGCP follows Google Cloud structured logging, where the special fields
+// are lifted out of jsonPayload by the logging agent.
+func encodeGCP(b *bytes.Buffer, r slog.Record, attrs []slog.Attr) {
+	traceID, spanID, attrs := splitTraceAttrs(attrs)
+	b.WriteString(`{"severity":`)
+	writeJSONString(b, gcpSeverity(r.Level))
+	b.WriteString(`,"time":`)
+	writeJSONString(b, r.Time.UTC().Format(time.RFC3339Nano))
+	b.WriteString(`,"message":`)
+	writeJSONString(b, r.Message)
+	if traceID != "" {
+		b.WriteString(`,"logging.googleapis.com/trace":`)
+		writeJSONString(b, gcpTraceName(traceID))
+		b.WriteString(`,"logging.googleapis.com/spanId":"` + spanID + `","logging.googleapis.com/trace_sampled":true`)
+	}
+	writeJSONAttrs(b, attrs)
+	b.WriteString("}\n")
+}
+
+// encodeCRI writes the Kubernetes CRI container log format,
+// "<time> <stream> <P|F> <content>", as the kubelet stores it on the node.
+// Every line of a multiline message becomes its own entry, and lines longer
+// than the kubelet's buffer are split into partial (P) entries.
+func encodeCRI(b *bytes.Buffer, r slog.Record, attrs []slog.Attr) {
+	stream := "stdout"
+	if r.Level >= slog.LevelError {
+		stream = "stderr"
+	}
+	prefix := r.Time.UTC().Format(time.RFC3339Nano) + " " + stream + " "
+
+	var content strings.Builder
+	content.WriteString(LevelName(r.Level))
+	content.WriteByte(' ')
+	if len(attrs) > 0 {
+		content.WriteByte('[')
+		for i, a := range attrs {
+			if i > 0 {
+				content.WriteByte(' ')
+			}
+			content.WriteString(a.Key + "=" + a.Value.String())
+		}
+		content.WriteString("] ")
+	}
+	content.WriteString(r.Message)
+
+	for _, line := range strings.Split(content.String(), "\n") {
+		for len(line) > criMaxLineSize {
+			cut := criRuneBoundary(line)
+			b.WriteString(prefix + "P " + line[:cut] + "\n")
+			line = line[cut:]
+		}
+		b.WriteString(prefix + "F " + line + "\n")
+	}
+}
+
+// criRuneBoundary is where to end a partial entry of line, which is longer
+// than criMaxLineSize: at the limit, or a little before it when that would
+// cut a UTF-8 character in two. Bytes that aren't valid UTF-8 are cut at the
+// limit.
+func criRuneBoundary(line string) int {
+	for cut := criMaxLineSize; cut > criMaxLineSize-utf8.UTFMax; cut-- {
+		if utf8.RuneStart(line[cut]) {
+			return cut
+		}
+	}
+	return criMaxLineSize
+}
+
+func otelSeverityNumber(l slog.Level) int {
+	switch {
+	case l >= LevelFatal:
+		return 21
+	case l >= slog.LevelError:
+		return 17
+	case l >= slog.LevelWarn:
+		return 13
+	case l >= slog.LevelInfo:
+		return 9
+	default:
+		return 5
+	}
+}
+
+func gcpSeverity(l slog.Level) string {
+	switch {
+	case l >= LevelFatal:
+		return "CRITICAL"
+	case l >= slog.LevelError:
+		return "ERROR"
+	case l >= slog.LevelWarn:
+		return "WARNING"
+	case l >= slog.LevelInfo:
+		return "INFO"
+	default:
+		return "DEBUG"
+	}
+}
+
+func writeJSONAttrs(b *bytes.Buffer, attrs []slog.Attr) {
+	for _, a := range attrs {
+		b.WriteByte(',')
+		writeJSONString(b, a.Key)
+		b.WriteByte(':')
+		writeJSONValue(b, a.Value)
+	}
+}
+
+func writeJSONString(b *bytes.Buffer, s string) {
+	// json.Marshal of a string can't fail
+	enc, _ := json.Marshal(s)
+	b.Write(enc)
+}
+
+func writeJSONValue(b *bytes.Buffer, v slog.Value) {
+	v = v.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		writeJSONString(b, v.String())
+	case slog.KindFloat64:
+		// JSON has no NaN or infinities, so they are written as strings
+		if f := v.Float64(); math.IsNaN(f) || math.IsInf(f, 0) {
+			writeJSONString(b, v.String())
+			return
+		}
+		b.WriteString(v.String())
+	case slog.KindInt64, slog.KindUint64, slog.KindBool:
+		b.WriteString(v.String())
+	default:
+		enc, err := json.Marshal(v.Any())
+		if err != nil {
+			writeJSONString(b, v.String())
+			return
+		}
+		b.Write(enc)
+	}
+}
+
+func writeOTelAnyValue(b *bytes.Buffer, v slog.Value) {
+	v = v.Resolve()
+	switch v.Kind() {
+	case slog.KindInt64, slog.KindUint64:
+		b.WriteString(`{"intValue":"` + v.String() + `"}`)
+	case slog.KindFloat64:
+		// as in the OTLP JSON encoding, which spells these as strings
+		switch f := v.Float64(); {
+		case math.IsNaN(f):
+			b.WriteString(`{"doubleValue":"NaN"}`)
+		case math.IsInf(f, 1):
+			b.WriteString(`{"doubleValue":"Infinity"}`)
+		case math.IsInf(f, -1):
+			b.WriteString(`{"doubleValue":"-Infinity"}`)
+		default:
+			b.WriteString(`{"doubleValue":` + v.String() + `}`)
+		}
+	case slog.KindBool:
+		b.WriteString(`{"boolValue":` + v.String() + `}`)
+	default:
+		b.WriteString(`{"stringValue":`)
+		writeJSONString(b, v.String())
+		b.WriteByte('}')
+	}
+}
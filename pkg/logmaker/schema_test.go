@@ -0,0 +1,138 @@
+package logmaker
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"regexp"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func writeOneEvent(t *testing.T, format string, opts ...OptFunc) string {
+	t.Helper()
+	var buf bytes.Buffer
+	h, err := NewHandler(&buf, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts = append(opts, WithLogger(slog.New(h)),
+		WithLevelMix(LevelMix{Warn: 1}),
+		WithFields([]FieldSpec{{Name: "tenant", Cardinality: 1}}))
+	if err := WriteLog(NewLogMaker(opts...), "hello \"world\""); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestJSONSchemaPresets(t *testing.T) {
+	cases := map[string]map[string]any{
+		FormatECS:        {"log.level": "warn", "message": "hello \"world\"", "ecs.version": ecsVersion, "tenant": "tenant-0"},
+		FormatCloudWatch: {"level": "WARN", "message": "hello \"world\"", "tenant": "tenant-0"},
+		FormatGCP:        {"severity": "WARNING", "message": "hello \"world\"", "tenant": "tenant-0"},
+		FormatOTel:       {"severityNumber": float64(13), "severityText": "WARN"},
+	}
+	for format, want := range cases {
+		var got map[string]any
+		out := writeOneEvent(t, format)
+		if err := json.Unmarshal([]byte(out), &got); err != nil {
+			t.Errorf("%s output is not valid json: %s\n%s", format, err, out)
+			continue
+		}
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("%s field %q = %v, want %v", format, k, got[k], v)
+			}
+		}
+		if _, ok := got[TimestampKey]; ok {
+			t.Errorf("%s output should not repeat the timestamp attribute", format)
+		}
+	}
+}
+
+func TestOTelPresetNestsBodyAndAttributes(t *testing.T) {
+	var got struct {
+		Body struct {
+			StringValue string `json:"stringValue"`
+		} `json:"body"`
+		Attributes []struct {
+			Key   string            `json:"key"`
+			Value map[string]string `json:"value"`
+		} `json:"attributes"`
+	}
+	if err := json.Unmarshal([]byte(writeOneEvent(t, FormatOTel)), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Body.StringValue != "hello \"world\"" {
+		t.Errorf("unexpected body %q", got.Body.StringValue)
+	}
+	if len(got.Attributes) != 1 || got.Attributes[0].Key != "tenant" || got.Attributes[0].Value["stringValue"] != "tenant-0" {
+		t.Errorf("unexpected attributes %+v", got.Attributes)
+	}
+}
+
+func TestJSONSchemaPresetsWriteNonFiniteFloats(t *testing.T) {
+	for _, format := range []string{FormatECS, FormatOTel, FormatCloudWatch, FormatGCP} {
+		var buf bytes.Buffer
+		h, err := NewHandler(&buf, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		slog.New(h).Info("ratio", "nan", math.NaN(), "inf", math.Inf(1), "neg_inf", math.Inf(-1), "half", 0.5)
+		out := buf.Bytes()
+		if !json.Valid(out) {
+			t.Errorf("%s output is not valid json:\n%s", format, out)
+		}
+		if !bytes.Contains(out, []byte("0.5")) {
+			t.Errorf("%s output should keep finite floats as numbers:\n%s", format, out)
+		}
+	}
+}
+
+func TestCRIPresetWritesOneEntryPerLine(t *testing.T) {
+	out := writeOneEvent(t, FormatCRI, WithMultiline(MultilineOpts{Fraction: 1, Frames: 2, Kinds: []string{StackTracePython}}))
+	entry := regexp.MustCompile(`^\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d(\.\d+)?Z stdout F `)
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) < 4 {
+		t.Fatalf("expected one entry per traceback line, got:\n%s", out)
+	}
+	for _, line := range lines {
+		if !entry.MatchString(line) {
+			t.Errorf("line is not a CRI entry: %q", line)
+		}
+	}
+}
+
+func TestCRIPresetSplitsBetweenCharacters(t *testing.T) {
+	var buf bytes.Buffer
+	h, _ := NewHandler(&buf, FormatCRI)
+	// "ERROR " puts the 16KiB limit in the middle of a three byte character
+	slog.New(h).Error(strings.Repeat("€", criMaxLineSize/3+10))
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected a partial and a full entry, got %d lines", len(lines))
+	}
+	var joined string
+	for _, line := range lines {
+		if !utf8.ValidString(line) {
+			t.Errorf("expected each entry to be valid UTF-8, got one ending %q", line[len(line)-4:])
+		}
+		_, content, _ := strings.Cut(line, " stderr ")
+		joined += content[2:]
+	}
+	if want := "ERROR " + strings.Repeat("€", criMaxLineSize/3+10); joined != want {
+		t.Errorf("expected the entries to join up to the message")
+	}
+}
+
+func TestCRIPresetSplitsLongLines(t *testing.T) {
+	var buf bytes.Buffer
+	h, _ := NewHandler(&buf, FormatCRI)
+	slog.New(h).Error(strings.Repeat("x", criMaxLineSize+10))
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], " stderr P ") || !strings.Contains(lines[1], " stderr F ") {
+		t.Errorf("expected a partial and a full entry, got %d lines", len(lines))
+	}
+}
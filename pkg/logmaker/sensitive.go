@@ -0,0 +1,227 @@
+package logmaker
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+)
+
+// names of the kinds of sensitive data that can be injected into messages
+const (
+	SensitiveEmail       = "email"
+	SensitiveCreditCard  = "credit_card"
+	SensitiveSSN         = "ssn"
+	SensitivePhone       = "phone"
+	SensitiveIPv4        = "ipv4"
+	SensitiveIPv6        = "ipv6"
+	SensitiveJWT         = "jwt"
+	SensitiveAWSKey      = "aws_key"
+	SensitiveBearerToken = "bearer_token"
+)
+
+// SensitiveKinds lists every kind of sensitive data that can be injected.
+var SensitiveKinds = []string{SensitiveEmail, SensitiveCreditCard, SensitiveSSN, SensitivePhone, SensitiveIPv4, SensitiveIPv6, SensitiveJWT, SensitiveAWSKey, SensitiveBearerToken}
+
+// SeqKey is the attribute holding an event's sequence number. It is added to
+// events whenever sensitive data injection is on, so the manifest can refer
+// to them.
+const SeqKey = "seq"
+
+// RunKey is the attribute holding the id of the LogMaker that wrote an event.
+// Sequence numbers start again for every LogMaker while output files and
+// manifests are appended to, so an event is identified by both.
+const RunKey = "run_id"
+
+// SensitiveOpts controls injection of fake but valid-looking PII and secrets.
+type SensitiveOpts struct {
+	// Fraction of events, between 0 and 1, that get a sensitive value.
+	Fraction float64 `json:"fraction"`
+	// Kinds restricts which kinds are injected. Empty means all of them.
+	Kinds []string `json:"kinds,omitempty"`
+	// Manifest receives one ManifestEntry per injected value as JSON lines.
+	Manifest io.Writer `json:"-"`
+}
+
+// ManifestEntry is the ground truth for one injected value.
+type ManifestEntry struct {
+	Run   string `json:"run_id,omitempty"`
+	Seq   uint64 `json:"seq"`
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+// manifestWriter serializes manifest entries from concurrent writers.
+type manifestWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func newManifestWriter(w io.Writer) *manifestWriter {
+	if w == nil {
+		return nil
+	}
+	return &manifestWriter{enc: json.NewEncoder(w)}
+}
+
+func (m *manifestWriter) record(e ManifestEntry) error {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.enc.Encode(e)
+}
+
+// ParseSensitiveKinds parses a comma separated list of sensitive data kinds.
+func ParseSensitiveKinds(s string) ([]string, error) {
+	var kinds []string
+	for _, kind := range strings.Split(s, ",") {
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		if kind == "" {
+			continue
+		}
+		if !slices.Contains(SensitiveKinds, kind) {
+			return nil, fmt.Errorf("unknown sensitive data kind %q, expected one of %s", kind, strings.Join(SensitiveKinds, ", "))
+		}
+		kinds = append(kinds, kind)
+	}
+	return kinds, nil
+}
+
+// GetFakeSensitive returns a fake value of the given kind and the message
+// fragment it would typically appear in.
+func GetFakeSensitive(kind string) (value string, fragment string) {
+	switch kind {
+	case SensitiveCreditCard:
+		value = fakeLuhnNumber()
+		return value, "charged card " + value
+	case SensitiveSSN:
+		value = fakeSSN()
+		return value, "verified ssn " + value
+	case SensitivePhone:
+		value = fakePhone()
+		return value, "sms sent to " + value
+	case SensitiveIPv4:
+		value = gofakeit.IPv4Address()
+		return value, "request from " + value
+	case SensitiveIPv6:
+		value = gofakeit.IPv6Address()
+		return value, "request from " + value
+	case SensitiveJWT:
+		value = fakeJWT()
+		return value, "session token " + value
+	case SensitiveAWSKey:
+		value = fakeAWSAccessKey()
+		return value, "aws_access_key_id=" + value
+	case SensitiveBearerToken:
+		value = fakeBase62(40)
+		return value, "Authorization: Bearer " + value
+	default:
+		value = gofakeit.Email()
+		return value, "user email " + value
+	}
+}
+
+// fakeLuhnNumber returns a 16 digit Visa-style card number with a valid
+// Luhn check digit.
+func fakeLuhnNumber() string {
+	digits := make([]int, 16)
+	digits[0] = 4
+	for i := 1; i < 15; i++ {
+		digits[i] = rand.IntN(10)
+	}
+	digits[15] = luhnCheckDigit(digits[:15])
+	var b strings.Builder
+	for _, d := range digits {
+		b.WriteByte(byte('0' + d))
+	}
+	return b.String()
+}
+
+// luhnCheckDigit computes the digit that makes payload pass the Luhn check.
+func luhnCheckDigit(payload []int) int {
+	sum := 0
+	for i := len(payload) - 1; i >= 0; i-- {
+		d := payload[i]
+		// double every second digit, counting from the right of the full number
+		if (len(payload)-i)%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return (10 - sum%10) % 10
+}
+
+// LuhnValid reports whether number passes the Luhn check.
+func LuhnValid(number string) bool {
+	if len(number) < 2 {
+		return false
+	}
+	digits := make([]int, 0, len(number))
+	for _, r := range number {
+		if r < '0' || r > '9' {
+			return false
+		}
+		digits = append(digits, int(r-'0'))
+	}
+	return luhnCheckDigit(digits[:len(digits)-1]) == digits[len(digits)-1]
+}
+
+// fakeSSN avoids the area, group and serial numbers that are never issued.
+func fakeSSN() string {
+	area := rand.IntN(899) + 1
+	if area == 666 {
+		area = 667
+	}
+	return fmt.Sprintf("%03d-%02d-%04d", area, rand.IntN(99)+1, rand.IntN(9999)+1)
+}
+
+func fakePhone() string {
+	return fmt.Sprintf("(%d%02d) %d%02d-%04d", rand.IntN(8)+2, rand.IntN(100), rand.IntN(8)+2, rand.IntN(100), rand.IntN(10000))
+}
+
+func fakeJWT() string {
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	claims, _ := json.Marshal(map[string]any{
+		"sub":   gofakeit.UUID(),
+		"email": gofakeit.Email(),
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	})
+	sig := make([]byte, 32)
+	for i := range sig {
+		sig[i] = byte(rand.IntN(256))
+	}
+	return header + "." + enc.EncodeToString(claims) + "." + enc.EncodeToString(sig)
+}
+
+func fakeAWSAccessKey() string {
+	const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
+	var b strings.Builder
+	b.WriteString("AKIA")
+	for i := 0; i < 16; i++ {
+		b.WriteByte(alphabet[rand.IntN(len(alphabet))])
+	}
+	return b.String()
+}
+
+func fakeBase62(n int) string {
+	const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = alphabet[rand.IntN(len(alphabet))]
+	}
+	return string(b)
+}
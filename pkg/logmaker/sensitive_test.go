@@ -0,0 +1,190 @@
+package logmaker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFakeSensitiveValuesLookValid(t *testing.T) {
+	patterns := map[string]*regexp.Regexp{
+		SensitiveEmail:       regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[a-z]+$`),
+		SensitiveCreditCard:  regexp.MustCompile(`^4\d{15}$`),
+		SensitiveSSN:         regexp.MustCompile(`^\d{3}-\d{2}-\d{4}$`),
+		SensitivePhone:       regexp.MustCompile(`^\([2-9]\d\d\) [2-9]\d\d-\d{4}$`),
+		SensitiveIPv4:        regexp.MustCompile(`^\d+\.\d+\.\d+\.\d+$`),
+		SensitiveIPv6:        regexp.MustCompile(`^[0-9a-f:]+$`),
+		SensitiveJWT:         regexp.MustCompile(`^eyJ[\w-]+\.[\w-]+\.[\w-]+$`),
+		SensitiveAWSKey:      regexp.MustCompile(`^AKIA[A-Z2-7]{16}$`),
+		SensitiveBearerToken: regexp.MustCompile(`^[A-Za-z0-9]{40}$`),
+	}
+	for _, kind := range SensitiveKinds {
+		for i := 0; i < 50; i++ {
+			value, fragment := GetFakeSensitive(kind)
+			if !patterns[kind].MatchString(value) {
+				t.Fatalf("%s value %q does not look valid", kind, value)
+			}
+			if !strings.Contains(fragment, value) {
+				t.Fatalf("%s fragment %q does not contain the value", kind, fragment)
+			}
+			if kind == SensitiveCreditCard && !LuhnValid(value) {
+				t.Fatalf("card number %s does not pass the Luhn check", value)
+			}
+		}
+	}
+}
+
+func TestLuhnValid(t *testing.T) {
+	if !LuhnValid("4539578763621486") || !LuhnValid("79927398713") {
+		t.Errorf("expected known good numbers to pass")
+	}
+	if LuhnValid("4539578763621487") || LuhnValid("12a4") {
+		t.Errorf("expected bad numbers to fail")
+	}
+}
+
+func TestThatRedactionCanBeVerifiedAgainstManifest(t *testing.T) {
+	for _, format := range Formats {
+		if format == FormatRaw {
+			// raw output has no seq to match manifest entries against
+			continue
+		}
+		var out, manifest bytes.Buffer
+		h, _ := NewHandler(&out, format)
+		mkr := NewLogMaker(WithLogger(slog.New(h)),
+			WithMultiline(MultilineOpts{Fraction: 0.5, Frames: 2}),
+			WithSensitive(SensitiveOpts{Fraction: 1, Manifest: &manifest}))
+		for i := 0; i < 20; i++ {
+			if err := WriteLog(mkr, GetFakeSentence(3)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if mkr.Stats().Sensitive != 20 {
+			t.Fatalf("expected 20 injected values, got %d", mkr.Stats().Sensitive)
+		}
+
+		// redact the values of every other manifest entry, as a pipeline would
+		var entries []ManifestEntry
+		dec := json.NewDecoder(bytes.NewReader(manifest.Bytes()))
+		for dec.More() {
+			var e ManifestEntry
+			if err := dec.Decode(&e); err != nil {
+				t.Fatal(err)
+			}
+			entries = append(entries, e)
+		}
+		redacted := out.String()
+		for i, e := range entries {
+			if i%2 == 0 {
+				redacted = strings.ReplaceAll(redacted, e.Value, "[REDACTED]")
+				redacted = strings.ReplaceAll(redacted, jsonEscaped(e.Value), "[REDACTED]")
+			}
+		}
+
+		report, err := VerifyRedaction(bytes.NewReader(manifest.Bytes()), strings.NewReader(redacted))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Total != 20 || report.Redacted != 10 || report.Leaked != 10 || report.Missing != 0 {
+			t.Errorf("%s: unexpected report %+v", format, report)
+		}
+		if report.Passed() {
+			t.Errorf("%s: expected report with leaks to fail", format)
+		}
+	}
+}
+
+func TestThatMangledEventsAreNotReportedAsRedacted(t *testing.T) {
+	for _, format := range Formats {
+		if format == FormatRaw {
+			continue
+		}
+		var out, manifest bytes.Buffer
+		h, _ := NewHandler(&out, format)
+		// long lines past the CRI buffer, so values land in partial entries
+		mkr := NewLogMaker(WithLogger(slog.New(h)),
+			WithMultiline(MultilineOpts{Fraction: 0.5, Frames: 2}),
+			WithFuzz(FuzzOpts{Fraction: 1, LongLineSize: 3 * criMaxLineSize / 2}),
+			WithSensitive(SensitiveOpts{Fraction: 1, Manifest: &manifest}))
+		for i := 0; i < 200; i++ {
+			if err := WriteLog(mkr, GetFakeSentence(6)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// nothing redacted the output, so every value has to be found
+		report, err := VerifyRedaction(bytes.NewReader(manifest.Bytes()), bytes.NewReader(out.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Total != 200 || report.Leaked != 200 || report.Redacted != 0 {
+			t.Errorf("%s: expected every fuzzed value to leak, got %d redacted and %d missing of %d", format, report.Redacted, report.Missing, report.Total)
+		}
+	}
+}
+
+func TestThatValuesSplitAcrossCRIEntriesAreFound(t *testing.T) {
+	entry := ManifestEntry{Run: "abc123", Seq: 7, Kind: SensitiveEmail, Value: "someone@example.com"}
+	write := func(out *bytes.Buffer, msg string) {
+		h, _ := NewHandler(out, FormatCRI)
+		r := slog.NewRecord(time.Now(), slog.LevelInfo, msg, 0)
+		r.AddAttrs(slog.String(RunKey, entry.Run), slog.Uint64(SeqKey, entry.Seq))
+		if err := h.Handle(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// pad the message so the kubelet's limit falls inside the value
+	var empty bytes.Buffer
+	write(&empty, "")
+	_, content, _ := strings.Cut(strings.TrimSuffix(empty.String(), "\n"), " F ")
+	var out bytes.Buffer
+	write(&out, strings.Repeat("x", criMaxLineSize-len(content)-5)+entry.Value)
+	if strings.Contains(out.String(), entry.Value) {
+		t.Fatalf("expected the value to be split between entries:\n%s", out.String())
+	}
+
+	manifest, _ := json.Marshal(entry)
+	report, err := VerifyRedaction(bytes.NewReader(manifest), bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Leaked != 1 {
+		t.Errorf("expected the split value to be found, got %+v", report)
+	}
+}
+
+func TestThatRedactionIsVerifiedPerRun(t *testing.T) {
+	// two runs appended to the same output and manifest, as repeated bursts
+	// to one file are
+	var out, manifest bytes.Buffer
+	for run := 0; run < 2; run++ {
+		h, _ := NewHandler(&out, FormatJSON)
+		mkr := NewLogMaker(WithLogger(slog.New(h)), WithSensitive(SensitiveOpts{Fraction: 1, Manifest: &manifest}))
+		for i := 0; i < 10; i++ {
+			if err := WriteLog(mkr, GetFakeSentence(3)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	report, err := VerifyRedaction(bytes.NewReader(manifest.Bytes()), bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// nothing was redacted, so every value of both runs has to be found
+	if report.Total != 20 || report.Leaked != 20 {
+		t.Errorf("expected the values of both runs to leak, got %+v", report)
+	}
+
+	stripped := regexp.MustCompile(`"seq":\d+,?`).ReplaceAll(out.Bytes(), nil)
+	report, err = VerifyRedaction(bytes.NewReader(manifest.Bytes()), bytes.NewReader(stripped))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Missing != 20 || report.Passed() {
+		t.Errorf("expected output without seqs to fail as missing, got %+v", report)
+	}
+}
@@ -0,0 +1,21 @@
+package logmaker
+
+import (
+	"io"
+	"os"
+)
+
+// OpenSink opens the file generated events are appended to, creating it if
+// needed. A path of "-" means stdout, which is left open on Close.
+func OpenSink(path string) (io.WriteCloser, error) {
+	if path == "-" {
+		return nopCloser{os.Stdout}, nil
+	}
+	return os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,169 @@
+package logmaker
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/brianvoe/gofakeit/v7"
+)
+
+// names of the exception styles GetFakeStackTrace knows how to produce
+const (
+	StackTraceJava   = "java"
+	StackTracePython = "python"
+	StackTraceGo     = "go"
+	StackTraceDotNet = "dotnet"
+)
+
+// StackTraceKinds lists every supported exception style.
+var StackTraceKinds = []string{StackTraceJava, StackTracePython, StackTraceGo, StackTraceDotNet}
+
+// MultilineOpts controls how many generated events are multiline exceptions.
+type MultilineOpts struct {
+	// Fraction of events, between 0 and 1, emitted as stack traces.
+	Fraction float64 `json:"fraction"`
+	// Frames is the number of stack frames in each trace (per cause for java).
+	Frames int `json:"frames"`
+	// Kinds restricts which exception styles are used. Empty means all of them.
+	Kinds []string `json:"kinds,omitempty"`
+}
+
+var (
+	javaExceptions   = []string{"java.lang.IllegalStateException", "java.lang.NullPointerException", "java.lang.IllegalArgumentException", "java.util.concurrent.TimeoutException"}
+	javaCauses       = []string{"java.io.IOException", "java.net.SocketTimeoutException", "java.sql.SQLTransientConnectionException", "java.net.ConnectException"}
+	pythonExceptions = []string{"ValueError", "KeyError", "TypeError", "ConnectionResetError", "RuntimeError"}
+	goPanics         = []string{"runtime error: index out of range [%d] with length %d", "runtime error: invalid memory address or nil pointer dereference", "assignment to entry in nil map", "send on closed channel"}
+	dotnetExceptions = []string{"System.InvalidOperationException", "System.NullReferenceException", "System.ArgumentException", "System.TimeoutException"}
+	dotnetInner      = []string{"System.IO.IOException", "System.Net.Sockets.SocketException", "System.Data.SqlClient.SqlException"}
+)
+
+// GetFakeStackTrace renders msg as a multiline exception in the given style.
+func GetFakeStackTrace(kind string, msg string, frames int) string {
+	if frames < 1 {
+		frames = 1
+	}
+	switch kind {
+	case StackTracePython:
+		return fakePythonTraceback(msg, frames)
+	case StackTraceGo:
+		return fakeGoPanic(msg, frames)
+	case StackTraceDotNet:
+		return fakeDotNetException(msg, frames)
+	default:
+		return fakeJavaStackTrace(msg, frames)
+	}
+}
+
+func fakeJavaStackTrace(msg string, frames int) string {
+	var b strings.Builder
+	pkg := "com." + identWord() + "." + identWord()
+	fmt.Fprintf(&b, "%s: %s", pick(javaExceptions), msg)
+	for i := 0; i < frames; i++ {
+		class := title(identWord()) + title(identWord())
+		fmt.Fprintf(&b, "\n\tat %s.%s.%s(%s.java:%d)", pkg, class, identWord(), class, rand.IntN(900)+10)
+	}
+	fmt.Fprintf(&b, "\nCaused by: %s: %s", pick(javaCauses), GetFakeSentence(6))
+	for i := 0; i < frames; i++ {
+		class := title(identWord()) + title(identWord())
+		fmt.Fprintf(&b, "\n\tat %s.%s.%s(%s.java:%d)", pkg, class, identWord(), class, rand.IntN(900)+10)
+	}
+	fmt.Fprintf(&b, "\n\t... %d more", rand.IntN(40)+1)
+	return b.String()
+}
+
+func fakePythonTraceback(msg string, frames int) string {
+	var b strings.Builder
+	b.WriteString("Traceback (most recent call last):")
+	for i := 0; i < frames; i++ {
+		fmt.Fprintf(&b, "\n  File \"/app/%s/%s.py\", line %d, in %s", identWord(), identWord(), rand.IntN(900)+10, identWord())
+		fmt.Fprintf(&b, "\n    %s = %s(%s)", identWord(), identWord(), identWord())
+	}
+	fmt.Fprintf(&b, "\n%s: %s", pick(pythonExceptions), msg)
+	return b.String()
+}
+
+func fakeGoPanic(msg string, frames int) string {
+	var b strings.Builder
+	reason := pick(goPanics)
+	if strings.Contains(reason, "%d") {
+		n := rand.IntN(16) + 1
+		reason = fmt.Sprintf(reason, n, n)
+	}
+	fmt.Fprintf(&b, "panic: %s [recovered]\n\tpanic: %s\n", reason, msg)
+	pkg := "github.com/" + identWord() + "/" + identWord()
+	goroutines := rand.IntN(2) + 1
+	for g := 0; g < goroutines; g++ {
+		state := "running"
+		if g > 0 {
+			state = "chan receive"
+		}
+		fmt.Fprintf(&b, "\ngoroutine %d [%s]:", rand.IntN(2000)+1, state)
+		for i := 0; i < frames; i++ {
+			fmt.Fprintf(&b, "\n%s/%s.(*%s).%s(0x%x)", pkg, identWord(), title(identWord()), title(identWord()), rand.Uint32())
+			fmt.Fprintf(&b, "\n\t/go/src/%s/%s.go:%d +0x%x", pkg, identWord(), rand.IntN(900)+10, rand.IntN(0x400))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("exit status 2")
+	return b.String()
+}
+
+func fakeDotNetException(msg string, frames int) string {
+	var b strings.Builder
+	ns := title(identWord()) + "." + title(identWord())
+	fmt.Fprintf(&b, "%s: %s ---> %s: %s", pick(dotnetExceptions), msg, pick(dotnetInner), GetFakeSentence(6))
+	for i := 0; i < frames; i++ {
+		class := title(identWord())
+		fmt.Fprintf(&b, "\n   at %s.%s.%s() in /src/%s/%s.cs:line %d", ns, class, title(identWord()), ns, class, rand.IntN(900)+10)
+	}
+	b.WriteString("\n   --- End of inner exception stack trace ---")
+	for i := 0; i < frames; i++ {
+		class := title(identWord())
+		fmt.Fprintf(&b, "\n   at %s.%s.%s() in /src/%s/%s.cs:line %d", ns, class, title(identWord()), ns, class, rand.IntN(900)+10)
+	}
+	return b.String()
+}
+
+// identWord returns a random lowercase word that is safe to use in identifiers and paths.
+func identWord() string {
+	word := strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII || !unicode.IsLetter(r) {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, gofakeit.HackerNoun())
+	if word == "" {
+		return "handler"
+	}
+	return word
+}
+
+func title(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+func pick(choices []string) string {
+	return choices[rand.IntN(len(choices))]
+}
+
+// ParseStackTraceKinds parses a comma separated list of exception styles.
+func ParseStackTraceKinds(s string) ([]string, error) {
+	var kinds []string
+	for _, kind := range strings.Split(s, ",") {
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		if kind == "" {
+			continue
+		}
+		if !slices.Contains(StackTraceKinds, kind) {
+			return nil, fmt.Errorf("unknown stack trace kind %q, expected one of %s", kind, strings.Join(StackTraceKinds, ", "))
+		}
+		kinds = append(kinds, kind)
+	}
+	return kinds, nil
+}
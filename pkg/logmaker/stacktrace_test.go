@@ -0,0 +1,40 @@
+package logmaker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetFakeStackTraceIsMultiline(t *testing.T) {
+	markers := map[string]string{
+		StackTraceJava:   "Caused by: ",
+		StackTracePython: "Traceback (most recent call last):",
+		StackTraceGo:     "goroutine ",
+		StackTraceDotNet: "--- End of inner exception stack trace ---",
+	}
+	for _, kind := range StackTraceKinds {
+		trace := GetFakeStackTrace(kind, "unique message", 5)
+		if !strings.Contains(trace, "unique message") {
+			t.Errorf("%s trace does not contain the original message:\n%s", kind, trace)
+		}
+		if !strings.Contains(trace, markers[kind]) {
+			t.Errorf("%s trace does not contain %q:\n%s", kind, markers[kind], trace)
+		}
+		if lines := strings.Count(trace, "\n") + 1; lines < 5 {
+			t.Errorf("%s trace only has %d lines:\n%s", kind, lines, trace)
+		}
+	}
+}
+
+func TestParseStackTraceKinds(t *testing.T) {
+	kinds, err := ParseStackTraceKinds("java, Python,,go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(kinds, ",") != "java,python,go" {
+		t.Errorf("unexpected kinds %v", kinds)
+	}
+	if _, err := ParseStackTraceKinds("cobol"); err == nil {
+		t.Errorf("expected unknown stack trace kind to fail")
+	}
+}
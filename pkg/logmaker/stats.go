@@ -0,0 +1,79 @@
+package logmaker
+
+import (
+	"log/slog"
+	"strings"
+	"sync/atomic"
+)
+
+// Stats is a snapshot of what a LogMaker has written so far.
+type Stats struct {
+	LogCount int64            `json:"log_count"`
+	Levels   map[string]int64 `json:"levels"`
+	// Sensitive is the number of events that had a sensitive value injected.
+	Sensitive int64 `json:"sensitive,omitempty"`
+	// Fuzzed is the number of events mutated by fuzz mode.
+	Fuzzed int64 `json:"fuzzed,omitempty"`
+	// WriteErrors is the number of events that could not be written.
+	WriteErrors int64 `json:"write_errors,omitempty"`
+	// Dropped is the number of events skipped because too many writes were
+	// in flight.
+	Dropped int64 `json:"dropped,omitempty"`
+}
+
+// counters are shared by all of the goroutines writing for one LogMaker.
+type counters struct {
+	lines       atomic.Int64
+	levels      [5]atomic.Int64
+	seq         atomic.Uint64
+	sensitive   atomic.Int64
+	fuzzed      atomic.Int64
+	writeErrors atomic.Int64
+	dropped     atomic.Int64
+}
+
+// nextSeq hands out event sequence numbers, starting at 1.
+func (c *counters) nextSeq() uint64 {
+	return c.seq.Add(1)
+}
+
+func (c *counters) record(l slog.Level) {
+	c.lines.Add(1)
+	for i, ml := range mixLevels {
+		if ml == l {
+			c.levels[i].Add(1)
+			return
+		}
+	}
+}
+
+func (c *counters) snapshot() Stats {
+	st := Stats{
+		LogCount:    c.lines.Load(),
+		Levels:      make(map[string]int64, len(mixLevels)),
+		Sensitive:   c.sensitive.Load(),
+		Fuzzed:      c.fuzzed.Load(),
+		WriteErrors: c.writeErrors.Load(),
+		Dropped:     c.dropped.Load(),
+	}
+	for i, l := range mixLevels {
+		st.Levels[strings.ToLower(LevelName(l))] = c.levels[i].Load()
+	}
+	return st
+}
+
+// Add counts other's events in st too, e.g. to total up LogMakers on
+// several hosts.
+func (st *Stats) Add(other Stats) {
+	st.LogCount += other.LogCount
+	if st.Levels == nil {
+		st.Levels = make(map[string]int64, len(other.Levels))
+	}
+	for level, n := range other.Levels {
+		st.Levels[level] += n
+	}
+	st.Sensitive += other.Sensitive
+	st.Fuzzed += other.Fuzzed
+	st.WriteErrors += other.WriteErrors
+	st.Dropped += other.Dropped
+}
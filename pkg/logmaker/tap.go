@@ -0,0 +1,208 @@
+package logmaker
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// TapFilter picks the events a tap subscriber gets.
+type TapFilter struct {
+	// Sample is the fraction of matching events to pass on, 1 for all of
+	// them.
+	Sample float64
+	// Levels are the levels to pass on, all of them when empty.
+	Levels []slog.Level
+	// Fields are attributes an event must have, by key and value as text.
+	Fields map[string]string
+}
+
+// Tap copies events written through its handlers to subscribers, encoded
+// exactly as for the sink. Without subscribers a tapped handler costs next to
+// nothing, and a subscriber that falls behind misses events rather than
+// slowing down writing.
+type Tap struct {
+	mu sync.Mutex
+	// subs is replaced rather than changed, so writers read it without
+	// locking
+	subs atomic.Pointer[[]*TapSubscription]
+}
+
+// TapSubscription receives the lines a Tap passes on until it is closed.
+type TapSubscription struct {
+	tap    *Tap
+	filter TapFilter
+	lines  chan []byte
+	missed atomic.Int64
+}
+
+func NewTap() *Tap {
+	return &Tap{}
+}
+
+// Subscribe starts passing events that match f on, buffering up to buffer
+// lines for the subscriber.
+func (t *Tap) Subscribe(f TapFilter, buffer int) *TapSubscription {
+	sub := &TapSubscription{tap: t, filter: f, lines: make(chan []byte, buffer)}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var subs []*TapSubscription
+	if cur := t.subs.Load(); cur != nil {
+		subs = slices.Clone(*cur)
+	}
+	subs = append(subs, sub)
+	t.subs.Store(&subs)
+	return sub
+}
+
+// Lines are the encoded events passed on, each ending in a newline.
+func (sub *TapSubscription) Lines() <-chan []byte {
+	return sub.lines
+}
+
+// Missed is the number of events that matched but were skipped because the
+// subscriber's buffer was full.
+func (sub *TapSubscription) Missed() int64 {
+	return sub.missed.Load()
+}
+
+// Close stops passing events on. Lines already buffered can still be read.
+func (sub *TapSubscription) Close() {
+	t := sub.tap
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if cur := t.subs.Load(); cur != nil {
+		subs := slices.DeleteFunc(slices.Clone(*cur), func(s *TapSubscription) bool { return s == sub })
+		t.subs.Store(&subs)
+	}
+}
+
+// Handler wraps next, which writes in format, so that the events it writes
+// are passed on to the tap's subscribers.
+func (t *Tap) Handler(next slog.Handler, format string) (slog.Handler, error) {
+	if _, err := NewHandler(io.Discard, format); err != nil {
+		return nil, err
+	}
+	th := &tapHandler{next: next, tap: t}
+	th.encoders = newEncoderPool(func(w io.Writer) slog.Handler {
+		h, _ := NewHandler(w, format)
+		return h
+	})
+	return th, nil
+}
+
+// tapHandler is a handler whose events are passed on to a Tap.
+type tapHandler struct {
+	next slog.Handler
+	tap  *Tap
+	// attrs are the handler's own attributes, which field filters match
+	// along with the record's
+	attrs    []slog.Attr
+	encoders *encoderPool
+}
+
+func (h *tapHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.next.Enabled(ctx, l)
+}
+
+func (h *tapHandler) Handle(ctx context.Context, r slog.Record) error {
+	if err := h.next.Handle(ctx, r); err != nil {
+		return err
+	}
+	subs := h.tap.subs.Load()
+	if subs == nil || len(*subs) == 0 {
+		return nil
+	}
+	var line []byte
+	for _, sub := range *subs {
+		if !sub.filter.matches(r, h.attrs) {
+			continue
+		}
+		if line == nil {
+			line = h.encoders.encode(ctx, r)
+		}
+		select {
+		case sub.lines <- line:
+		default:
+			sub.missed.Add(1)
+		}
+	}
+	return nil
+}
+
+func (h *tapHandler) WithAttrs(as []slog.Attr) slog.Handler {
+	nh := *h
+	nh.next = h.next.WithAttrs(as)
+	nh.attrs = append(slices.Clone(h.attrs), as...)
+	nh.encoders = newEncoderPool(func(w io.Writer) slog.Handler {
+		return h.encoders.newHandler(w).WithAttrs(as)
+	})
+	return &nh
+}
+
+func (h *tapHandler) WithGroup(name string) slog.Handler {
+	nh := *h
+	nh.next = h.next.WithGroup(name)
+	nh.encoders = newEncoderPool(func(w io.Writer) slog.Handler {
+		return h.encoders.newHandler(w).WithGroup(name)
+	})
+	return &nh
+}
+
+func (f TapFilter) matches(r slog.Record, handlerAttrs []slog.Attr) bool {
+	if len(f.Levels) > 0 && !slices.Contains(f.Levels, r.Level) {
+		return false
+	}
+	if len(f.Fields) > 0 {
+		found := 0
+		check := func(a slog.Attr) bool {
+			if want, ok := f.Fields[a.Key]; ok && a.Value.String() == want {
+				found++
+			}
+			return true
+		}
+		for _, a := range handlerAttrs {
+			check(a)
+		}
+		r.Attrs(check)
+		if found < len(f.Fields) {
+			return false
+		}
+	}
+	return f.Sample >= 1 || rand.Float64() < f.Sample
+}
+
+// encoderPool keeps handlers writing to their own buffer, so events can be
+// encoded again for subscribers without the writers waiting on each other.
+type encoderPool struct {
+	newHandler func(io.Writer) slog.Handler
+	pool       sync.Pool
+}
+
+type encoder struct {
+	buf bytes.Buffer
+	h   slog.Handler
+}
+
+func newEncoderPool(newHandler func(io.Writer) slog.Handler) *encoderPool {
+	ep := &encoderPool{newHandler: newHandler}
+	ep.pool.New = func() any {
+		e := &encoder{}
+		e.h = newHandler(&e.buf)
+		return e
+	}
+	return ep
+}
+
+func (ep *encoderPool) encode(ctx context.Context, r slog.Record) []byte {
+	e := ep.pool.Get().(*encoder)
+	defer ep.pool.Put(e)
+	e.buf.Reset()
+	e.h.Handle(ctx, r)
+	return bytes.Clone(e.buf.Bytes())
+}
@@ -0,0 +1,85 @@
+package logmaker
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestTapPassesOnLinesAsWritten(t *testing.T) {
+	var buf bytes.Buffer
+	h, err := NewHandler(&buf, FormatECS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tap := NewTap()
+	th, err := tap.Handler(h, FormatECS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := tap.Subscribe(TapFilter{Sample: 1}, 100)
+	errors := tap.Subscribe(TapFilter{Sample: 1, Levels: []slog.Level{slog.LevelError}}, 100)
+	tenant := tap.Subscribe(TapFilter{Sample: 1, Fields: map[string]string{"tenant": "tenant-2"}}, 100)
+	mkr := NewLogMaker(WithLogger(slog.New(th)),
+		WithLevelMix(LevelMix{Info: 1, Error: 1}),
+		WithFields([]FieldSpec{{Name: "tenant", Cardinality: 3, Distribution: DistributionSequential}}))
+	for i := 0; i < 30; i++ {
+		if err := WriteLog(mkr, "hello"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var tapped strings.Builder
+	for len(all.Lines()) > 0 {
+		tapped.Write(<-all.Lines())
+	}
+	if tapped.String() != buf.String() {
+		t.Errorf("expected the tapped lines to match the sink:\n%s\ngot:\n%s", buf.String(), tapped.String())
+	}
+	if n := len(errors.Lines()); n == 0 || n == 30 {
+		t.Errorf("expected only error events, got %d", n)
+	}
+	for len(errors.Lines()) > 0 {
+		if line := string(<-errors.Lines()); !strings.Contains(line, `"log.level":"error"`) {
+			t.Errorf("expected an error event, got %s", line)
+		}
+	}
+	if n := len(tenant.Lines()); n != 10 {
+		t.Errorf("expected every third event, got %d", n)
+	}
+}
+
+func TestTapSkipsLinesForSlowSubscribers(t *testing.T) {
+	tap := NewTap()
+	th, err := tap.Handler(slog.NewJSONHandler(&bytes.Buffer{}, nil), FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := tap.Subscribe(TapFilter{Sample: 1}, 5)
+	mkr := NewLogMaker(WithLogger(slog.New(th)))
+	for i := 0; i < 20; i++ {
+		if err := WriteLog(mkr, "hello"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(sub.Lines()) != 5 || sub.Missed() != 15 {
+		t.Errorf("expected a full buffer and the rest missed, got %d and %d", len(sub.Lines()), sub.Missed())
+	}
+	sub.Close()
+	if err := WriteLog(mkr, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if sub.Missed() != 15 {
+		t.Error("expected nothing to be passed on after closing")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	if l, err := ParseLevel("FATAL"); err != nil || l != LevelFatal {
+		t.Errorf("expected fatal, got %v %v", l, err)
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Error("expected an unknown level to fail")
+	}
+}
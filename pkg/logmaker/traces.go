@@ -0,0 +1,170 @@
+package logmaker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// keys of the attributes that carry trace context on generated events.
+// Schema presets map them to their own field names.
+const (
+	TraceIDKey = "trace_id"
+	SpanIDKey  = "span_id"
+)
+
+// TraceOpts controls synthetic traces. When enabled, the LogMaker creates
+// trees of spans, exports them through SpanProcessor, and stamps each event
+// with the trace and span id of one of the spans.
+type TraceOpts struct {
+	Enabled bool `json:"enabled"`
+	// Depth is the number of levels in each trace, including the root span.
+	Depth int `json:"depth"`
+	// Fanout is the number of children of every span above the last level.
+	Fanout int `json:"fanout"`
+	// Services are assigned to spans at random. Each one is exported as a
+	// service of its own, with service.name set on its resource.
+	Services []string `json:"services,omitempty"`
+	// Inherit makes every synthetic trace a child of ParentContext, e.g.
+	// the context of the request that started the LogMaker.
+	Inherit bool `json:"inherit"`
+}
+
+var defaultTraceServices = []string{"frontend", "checkout", "payments", "inventory", "shipping"}
+
+// spanOperations are used to name synthetic spans.
+var spanOperations = []string{"GET /api/orders", "POST /api/checkout", "SELECT orders", "publish order.created", "GET /api/inventory", "authorize", "render"}
+
+// traceSource hands out the spans of synthetic traces one at a time, building
+// a new trace whenever the previous one is used up.
+type traceSource struct {
+	mu      sync.Mutex
+	opts    TraceOpts
+	tracers map[string]trace.Tracer
+	parent  context.Context
+	pending []trace.SpanContext
+}
+
+func newTraceSource(opts TraceOpts, processor sdktrace.SpanProcessor, parent context.Context) *traceSource {
+	if opts.Depth < 1 {
+		opts.Depth = 1
+	}
+	if opts.Fanout < 1 {
+		opts.Fanout = 1
+	}
+	if len(opts.Services) == 0 {
+		opts.Services = defaultTraceServices
+	}
+	// backends take the service from the resource rather than the span, so
+	// each service needs a provider of its own. They share processor, which
+	// whoever made it flushes and shuts down; without one spans are not sent
+	// anywhere, but they still get valid ids to stamp on events.
+	tracers := make(map[string]trace.Tracer, len(opts.Services))
+	for _, service := range opts.Services {
+		providerOpts := []sdktrace.TracerProviderOption{
+			sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(service))),
+		}
+		if processor != nil {
+			providerOpts = append(providerOpts, sdktrace.WithSpanProcessor(processor))
+		}
+		tracers[service] = sdktrace.NewTracerProvider(providerOpts...).Tracer(service)
+	}
+	if parent == nil || !opts.Inherit {
+		parent = context.Background()
+	}
+	return &traceSource{opts: opts, tracers: tracers, parent: parent}
+}
+
+// next returns the span context the next event should be stamped with.
+func (ts *traceSource) next() trace.SpanContext {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if len(ts.pending) == 0 {
+		ts.pending = ts.buildTrace()
+	}
+	sc := ts.pending[0]
+	ts.pending = ts.pending[1:]
+	return sc
+}
+
+// buildTrace creates and ends one synthetic trace, returning all of its span
+// contexts in the order they were started.
+func (ts *traceSource) buildTrace() []trace.SpanContext {
+	var spans []trace.SpanContext
+	start := time.Now()
+	// each level of the trace is given a slice of the parent's duration
+	total := time.Duration(rand.IntN(400)+100) * time.Millisecond
+	ts.buildSpan(ts.parent, 1, start, total, &spans)
+	return spans
+}
+
+func (ts *traceSource) buildSpan(ctx context.Context, level int, start time.Time, dur time.Duration, spans *[]trace.SpanContext) {
+	tracer := ts.tracers[ts.opts.Services[rand.IntN(len(ts.opts.Services))]]
+	kind := trace.SpanKindServer
+	if level > 1 {
+		kind = trace.SpanKindInternal
+	}
+	ctx, span := tracer.Start(ctx, spanOperations[rand.IntN(len(spanOperations))],
+		trace.WithTimestamp(start),
+		trace.WithSpanKind(kind),
+		trace.WithAttributes(attribute.Bool("logwild.synthetic", true)))
+	*spans = append(*spans, span.SpanContext())
+	if level < ts.opts.Depth {
+		child := dur / time.Duration(ts.opts.Fanout+1)
+		for i := 0; i < ts.opts.Fanout; i++ {
+			ts.buildSpan(ctx, level+1, start.Add(time.Duration(i)*child+child/2), child, spans)
+		}
+	}
+	span.End(trace.WithTimestamp(start.Add(dur)))
+}
+
+func traceAttrs(sc trace.SpanContext) []slog.Attr {
+	return []slog.Attr{
+		slog.String(TraceIDKey, sc.TraceID().String()),
+		slog.String(SpanIDKey, sc.SpanID().String()),
+	}
+}
+
+// ParseTraceServices parses a comma separated list of service names.
+func ParseTraceServices(s string) []string {
+	var services []string
+	for _, svc := range strings.Split(s, ",") {
+		if svc = strings.TrimSpace(svc); svc != "" {
+			services = append(services, svc)
+		}
+	}
+	return services
+}
+
+// splitTraceAttrs pulls the trace context attributes out of attrs so schema
+// presets can write them under their own names.
+func splitTraceAttrs(attrs []slog.Attr) (traceID, spanID string, rest []slog.Attr) {
+	rest = attrs[:0:0]
+	for _, a := range attrs {
+		switch a.Key {
+		case TraceIDKey:
+			traceID = a.Value.String()
+		case SpanIDKey:
+			spanID = a.Value.String()
+		default:
+			rest = append(rest, a)
+		}
+	}
+	return traceID, spanID, rest
+}
+
+// gcpTraceName formats a trace id the way Cloud Logging expects it in
+// logging.googleapis.com/trace.
+func gcpTraceName(traceID string) string {
+	return fmt.Sprintf("projects/%s/traces/%s", gcpProject, traceID)
+}
@@ -0,0 +1,107 @@
+package logmaker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+)
+
+func TestThatLogMakerStampsEventsWithExportedSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	var buf bytes.Buffer
+	mkr := NewLogMaker(WithLogger(slog.New(slog.NewJSONHandler(&buf, HandlerOptions()))),
+		WithSpanProcessor(sdktrace.NewSimpleSpanProcessor(exporter)),
+		WithTraces(TraceOpts{Enabled: true, Depth: 3, Fanout: 2, Services: []string{"a", "b"}}))
+	for i := 0; i < 7; i++ {
+		if err := WriteLog(mkr, "traced"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 7 {
+		t.Fatalf("expected one trace of 7 spans, got %d spans", len(spans))
+	}
+	exported := map[string]bool{}
+	for _, s := range spans {
+		exported[s.SpanContext.TraceID().String()+"/"+s.SpanContext.SpanID().String()] = true
+	}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var ev map[string]any
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Fatal(err)
+		}
+		key, _ := ev[TraceIDKey].(string)
+		span, _ := ev[SpanIDKey].(string)
+		if !exported[key+"/"+span] {
+			t.Errorf("event is stamped with a span that was not exported: %s", line)
+		}
+	}
+}
+
+func TestThatEachServiceIsExportedAsItsOwn(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	mkr := NewLogMaker(WithLogger(slog.New(slog.NewJSONHandler(&bytes.Buffer{}, HandlerOptions()))),
+		WithSpanProcessor(sdktrace.NewSimpleSpanProcessor(exporter)),
+		WithTraces(TraceOpts{Enabled: true, Depth: 4, Fanout: 3, Services: []string{"web", "cart", "db"}}))
+	if err := WriteLog(mkr, "traced"); err != nil {
+		t.Fatal(err)
+	}
+	services := map[string]bool{}
+	for _, s := range exporter.GetSpans() {
+		name, ok := s.Resource.Set().Value(semconv.ServiceNameKey)
+		if !ok {
+			t.Fatalf("expected span %s to have a service on its resource", s.Name)
+		}
+		services[name.AsString()] = true
+	}
+	// 40 spans over 3 services leave one out about 3 times in 10 million
+	if len(services) != 3 || !services["web"] || !services["cart"] || !services["db"] {
+		t.Errorf("expected spans from web, cart and db, got %v", services)
+	}
+}
+
+func TestThatSyntheticTracesCanInheritParent(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "request")
+	defer parent.End()
+	mkr := NewLogMaker(WithLogger(slog.New(slog.NewJSONHandler(&bytes.Buffer{}, HandlerOptions()))),
+		WithSpanProcessor(sdktrace.NewSimpleSpanProcessor(exporter)),
+		WithParentContext(ctx),
+		WithTraces(TraceOpts{Enabled: true, Depth: 1, Inherit: true}))
+	if err := WriteLog(mkr, "traced"); err != nil {
+		t.Fatal(err)
+	}
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	if spans[0].Parent.SpanID() != parent.SpanContext().SpanID() || spans[0].SpanContext.TraceID() != parent.SpanContext().TraceID() {
+		t.Errorf("expected synthetic span to be a child of the request span")
+	}
+}
+
+func TestSchemaPresetsMapTraceContext(t *testing.T) {
+	cases := map[string][]string{
+		FormatECS:  {`"trace.id":"`, `"span.id":"`},
+		FormatOTel: {`"traceId":"`, `"spanId":"`},
+		FormatGCP:  {`"logging.googleapis.com/trace":"projects/`, `"logging.googleapis.com/spanId":"`},
+		FormatCRI:  {TraceIDKey + "=", SpanIDKey + "="},
+	}
+	for format, wants := range cases {
+		out := writeOneEvent(t, format, WithTraces(TraceOpts{Enabled: true}))
+		for _, want := range wants {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s output does not contain %s:\n%s", format, want, out)
+			}
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package logmaker
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RedactionReport compares a sensitive data manifest with pipeline output.
+type RedactionReport struct {
+	// Total is the number of values in the manifest.
+	Total int `json:"total"`
+	// Redacted values belong to events found in the output without them.
+	Redacted int `json:"redacted"`
+	// Leaked values were still present in their event.
+	Leaked int `json:"leaked"`
+	// Missing values belong to events that were not found in the output at all.
+	Missing int                     `json:"missing"`
+	ByKind  map[string]*KindSummary `json:"by_kind"`
+	// LeakedEntries lists every value that made it through unredacted.
+	LeakedEntries []ManifestEntry `json:"leaked_entries,omitempty"`
+}
+
+type KindSummary struct {
+	Total    int `json:"total"`
+	Redacted int `json:"redacted"`
+	Leaked   int `json:"leaked"`
+	Missing  int `json:"missing"`
+}
+
+// Passed is true when no injected value leaked and every event with one was
+// found, since a pipeline that drops events or their seq would otherwise pass
+// without having been checked.
+func (r RedactionReport) Passed() bool {
+	return r.Leaked == 0 && r.Missing == 0
+}
+
+// seqPatterns find the sequence number of an event in any of the output
+// formats: json and the json presets, slog text and plain/cri attributes, and
+// the otel attribute list.
+var seqPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`"` + SeqKey + `":\s*"?(\d+)`),
+	regexp.MustCompile(`(?:^|[\s\[])` + SeqKey + `=(\d+)`),
+	regexp.MustCompile(`"key":"` + SeqKey + `","value":\{"intValue":"(\d+)"`),
+}
+
+// runPatterns find the run id of an event in the same formats.
+var runPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`"` + RunKey + `":\s*"([0-9a-f]+)"`),
+	regexp.MustCompile(`(?:^|[\s\[])` + RunKey + `=([0-9a-f]+)`),
+	regexp.MustCompile(`"key":"` + RunKey + `","value":\{"stringValue":"([0-9a-f]+)"`),
+}
+
+// criPartial matches a partial CRI entry, which the kubelet writes for each
+// full buffer of a long line and which continues in the entries after it.
+var criPartial = regexp.MustCompile(`^\S+ (?:stdout|stderr) P `)
+
+// criContent matches the prefix of the entries continuing a partial one.
+var criContent = regexp.MustCompile(`^\S+ (?:stdout|stderr) [PF] `)
+
+// eventKey identifies an event across runs appended to the same output.
+// Manifests written before run ids were added have an empty run.
+type eventKey struct {
+	run string
+	seq uint64
+}
+
+// VerifyRedaction reads the manifest written during a run and the output of
+// a redaction pipeline, and reports which injected values were removed.
+// Lines of the output without a sequence number are treated as continuations
+// of the previous event, so multiline events are checked as a whole, and
+// partial CRI entries are joined back into the line they were split from.
+func VerifyRedaction(manifest io.Reader, output io.Reader) (RedactionReport, error) {
+	var entries []ManifestEntry
+	dec := json.NewDecoder(manifest)
+	for {
+		var e ManifestEntry
+		if err := dec.Decode(&e); err == io.EOF {
+			break
+		} else if err != nil {
+			return RedactionReport{}, fmt.Errorf("reading manifest: %w", err)
+		}
+		entries = append(entries, e)
+	}
+
+	// only keep the events the manifest refers to
+	wanted := make(map[eventKey]bool, len(entries))
+	for _, e := range entries {
+		wanted[eventKey{e.Run, e.Seq}] = true
+	}
+	events := make(map[eventKey]*strings.Builder, len(entries))
+	var current *strings.Builder
+	add := func(line string) {
+		if seq, ok := findSeq(line); ok {
+			current = nil
+			key := eventKey{findRun(line), seq}
+			if wanted[key] {
+				current = &strings.Builder{}
+				events[key] = current
+			}
+		}
+		if current != nil {
+			current.WriteString(line)
+			current.WriteByte('\n')
+		}
+	}
+	scanner := bufio.NewScanner(output)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	// partial holds the CRI entries of a line that isn't complete yet
+	var partial strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		more := criPartial.MatchString(line)
+		if partial.Len() > 0 {
+			line = partial.String() + criContent.ReplaceAllLiteralString(line, "")
+			partial.Reset()
+		}
+		if more {
+			partial.WriteString(line)
+			continue
+		}
+		add(line)
+	}
+	if err := scanner.Err(); err != nil {
+		return RedactionReport{}, fmt.Errorf("reading output: %w", err)
+	}
+	if partial.Len() > 0 {
+		add(partial.String())
+	}
+
+	report := RedactionReport{Total: len(entries), ByKind: map[string]*KindSummary{}}
+	for _, e := range entries {
+		ks, ok := report.ByKind[e.Kind]
+		if !ok {
+			ks = &KindSummary{}
+			report.ByKind[e.Kind] = ks
+		}
+		ks.Total++
+		ev, found := events[eventKey{e.Run, e.Seq}]
+		switch {
+		case !found:
+			report.Missing++
+			ks.Missing++
+		case strings.Contains(ev.String(), e.Value) || strings.Contains(ev.String(), jsonEscaped(e.Value)):
+			report.Leaked++
+			ks.Leaked++
+			report.LeakedEntries = append(report.LeakedEntries, e)
+		default:
+			report.Redacted++
+			ks.Redacted++
+		}
+	}
+	return report, nil
+}
+
+func findSeq(line string) (uint64, bool) {
+	for _, re := range seqPatterns {
+		if m := re.FindStringSubmatch(line); m != nil {
+			seq, err := strconv.ParseUint(m[1], 10, 64)
+			return seq, err == nil
+		}
+	}
+	return 0, false
+}
+
+func findRun(line string) string {
+	for _, re := range runPatterns {
+		if m := re.FindStringSubmatch(line); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// jsonEscaped is how a value looks inside a json string, which matters for
+// values like emails that encoders may escape.
+func jsonEscaped(s string) string {
+	enc, _ := json.Marshal(s)
+	return string(enc[1 : len(enc)-1])
+}
@@ -0,0 +1,216 @@
+// Package metrics exports Prometheus metrics about the log generator itself:
+// what it wrote, how fast, and what it lost, labelled by job, sink and format
+// so they can be lined up with the metrics of the pipeline reading the logs.
+// The job label is logwild_job, as Prometheus sets job to the scrape target's
+// job and would otherwise rename ours to exported_job.
+package metrics
+
+import (
+	"io"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"mcgaunn.com/logwild/pkg/logmaker"
+)
+
+// kinds of work counted by the active jobs gauge
+const (
+	KindLoggen   = "loggen"
+	KindJob      = "job"
+	KindScenario = "scenario"
+)
+
+var streamLabels = []string{"logwild_job", "sink", "format"}
+
+// Generator holds the generator metrics. A nil *Generator records nothing,
+// so callers don't need to check whether metrics are enabled.
+type Generator struct {
+	lines      *prometheus.CounterVec
+	bytes      *prometheus.CounterVec
+	errors     *prometheus.CounterVec
+	dropped    *prometheus.CounterVec
+	target     *prometheus.GaugeVec
+	achieved   *prometheus.GaugeVec
+	latency    *prometheus.HistogramVec
+	activeJobs *prometheus.GaugeVec
+}
+
+// NewGenerator creates the generator metrics and registers them with reg.
+func NewGenerator(reg prometheus.Registerer) *Generator {
+	g := &Generator{
+		lines: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "logwild",
+			Name:      "lines_generated_total",
+			Help:      "The total number of log lines generated.",
+		}, streamLabels),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "logwild",
+			Name:      "bytes_written_total",
+			Help:      "The total number of bytes written to sinks.",
+		}, streamLabels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "logwild",
+			Name:      "write_errors_total",
+			Help:      "The total number of log lines that could not be written.",
+		}, streamLabels),
+		dropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "logwild",
+			Name:      "dropped_total",
+			Help:      "The total number of log lines skipped because too many writes were in flight.",
+		}, streamLabels),
+		target: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "logwild",
+			Name:      "target_rate",
+			Help:      "Log lines per second the generator is aiming for.",
+		}, streamLabels),
+		achieved: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "logwild",
+			Name:      "achieved_rate",
+			Help:      "Log lines written in the last second.",
+		}, streamLabels),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "logwild",
+			Name:      "write_latency_seconds",
+			Help:      "Seconds spent writing each log line to its sink.",
+			// 1µs to about 1s
+			Buckets: prometheus.ExponentialBuckets(1e-6, 4, 11),
+		}, streamLabels),
+		activeJobs: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "logwild",
+			Name:      "active_jobs",
+			Help:      "Log generation runs in progress, by kind.",
+		}, []string{"kind"}),
+	}
+	reg.MustRegister(g.lines, g.bytes, g.errors, g.dropped, g.target, g.achieved, g.latency, g.activeJobs)
+	return g
+}
+
+// Stream is the metrics of one LogMaker writing to one sink in one format.
+// It is a logmaker.Observer.
+type Stream struct {
+	g      *Generator
+	labels prometheus.Labels
+	// resolved once, since they are used for every line
+	lines    prometheus.Counter
+	bytes    prometheus.Counter
+	errors   prometheus.Counter
+	dropped  prometheus.Counter
+	latency  prometheus.Observer
+	target   prometheus.Gauge
+	achieved prometheus.Gauge
+}
+
+// Stream returns the metrics for a LogMaker labelled with job, sink and
+// format.
+func (g *Generator) Stream(job, sink, format string) *Stream {
+	if g == nil {
+		return nil
+	}
+	labels := prometheus.Labels{"logwild_job": job, "sink": sink, "format": format}
+	st := &Stream{
+		g:        g,
+		labels:   labels,
+		lines:    g.lines.With(labels),
+		bytes:    g.bytes.With(labels),
+		errors:   g.errors.With(labels),
+		dropped:  g.dropped.With(labels),
+		latency:  g.latency.With(labels),
+		target:   g.target.With(labels),
+		achieved: g.achieved.With(labels),
+	}
+	return st
+}
+
+// Wrote counts a written line, or a failed write, and its latency.
+func (st *Stream) Wrote(latency time.Duration, err error) {
+	if st == nil {
+		return
+	}
+	st.latency.Observe(latency.Seconds())
+	if err != nil {
+		st.errors.Inc()
+		return
+	}
+	st.lines.Inc()
+}
+
+// Dropped counts a line skipped because too many writes were in flight.
+func (st *Stream) Dropped() {
+	if st == nil {
+		return
+	}
+	st.dropped.Inc()
+}
+
+// Second sets the rate gauges from a second of the run.
+func (st *Stream) Second(smp logmaker.SecondSample) {
+	if st == nil {
+		return
+	}
+	st.target.Set(float64(smp.Target))
+	st.achieved.Set(float64(smp.Achieved))
+}
+
+// SetTarget sets the target rate gauge, when a LogMaker starts and when its
+// rate changes between seconds.
+func (st *Stream) SetTarget(rate int64) {
+	if st == nil {
+		return
+	}
+	st.target.Set(float64(rate))
+}
+
+// Writer wraps w so bytes written through it are counted.
+func (st *Stream) Writer(w io.Writer) io.Writer {
+	if st == nil {
+		return w
+	}
+	return &countingWriter{w: w, bytes: st.bytes}
+}
+
+// Close removes the rate gauges of a stream that is done, so it no longer
+// shows up as aiming for or achieving anything. Counters are kept.
+func (st *Stream) Close() {
+	if st == nil {
+		return
+	}
+	st.g.target.Delete(st.labels)
+	st.g.achieved.Delete(st.labels)
+}
+
+// Observer returns st as a logmaker.Observer, or nil when metrics are off so
+// the LogMaker skips calling it.
+func (st *Stream) Observer() logmaker.Observer {
+	if st == nil {
+		return nil
+	}
+	return st
+}
+
+// JobStarted counts a run of kind as active until JobFinished.
+func (g *Generator) JobStarted(kind string) {
+	if g == nil {
+		return
+	}
+	g.activeJobs.WithLabelValues(kind).Inc()
+}
+
+// JobFinished counts a run of kind as no longer active.
+func (g *Generator) JobFinished(kind string) {
+	if g == nil {
+		return
+	}
+	g.activeJobs.WithLabelValues(kind).Dec()
+}
+
+type countingWriter struct {
+	w     io.Writer
+	bytes prometheus.Counter
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.bytes.Add(float64(n))
+	return n, err
+}
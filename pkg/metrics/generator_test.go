@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"mcgaunn.com/logwild/pkg/logmaker"
+)
+
+func TestThatStreamRecordsWhatALogMakerWrote(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	g := NewGenerator(reg)
+	st := g.Stream("job-1", "-", logmaker.FormatJSON)
+
+	var buf bytes.Buffer
+	h, _ := logmaker.NewHandler(st.Writer(&buf), logmaker.FormatJSON)
+	lm := logmaker.NewLogMaker(logmaker.WithLogger(slog.New(h)),
+		logmaker.WithPerSecondRate(200),
+		logmaker.WithBurstDuration(1100*time.Millisecond),
+		logmaker.WithObserver(st.Observer()))
+	st.SetTarget(lm.PerSecondRate)
+	g.JobStarted(KindJob)
+	if err := lm.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	labels := prometheus.Labels{"logwild_job": "job-1", "sink": "-", "format": logmaker.FormatJSON}
+	if got, want := testutil.ToFloat64(g.lines.With(labels)), float64(lm.Stats().LogCount); got != want {
+		t.Errorf("expected %v lines, got %v", want, got)
+	}
+	if got, want := testutil.ToFloat64(g.bytes.With(labels)), float64(buf.Len()); got != want {
+		t.Errorf("expected %v bytes, got %v", want, got)
+	}
+	if got := testutil.ToFloat64(g.target.With(labels)); got != 200 {
+		t.Errorf("expected a target rate of 200, got %v", got)
+	}
+	if got := testutil.ToFloat64(g.achieved.With(labels)); got < 150 {
+		t.Errorf("expected an achieved rate near 200, got %v", got)
+	}
+	if got := testutil.ToFloat64(g.activeJobs.WithLabelValues(KindJob)); got != 1 {
+		t.Errorf("expected one active job, got %v", got)
+	}
+	if n := testutil.CollectAndCount(g.latency); n != 1 {
+		t.Errorf("expected one latency histogram, got %d", n)
+	}
+
+	st.Close()
+	g.JobFinished(KindJob)
+	if n := testutil.CollectAndCount(g.target); n != 0 {
+		t.Errorf("expected the target rate of a closed stream to be removed, got %d series", n)
+	}
+	if got := testutil.ToFloat64(g.activeJobs.WithLabelValues(KindJob)); got != 0 {
+		t.Errorf("expected no active jobs, got %v", got)
+	}
+}
+
+func TestThatStreamCountsErrorsAndDrops(t *testing.T) {
+	g := NewGenerator(prometheus.NewRegistry())
+	st := g.Stream("job-1", "/tmp/out.log", logmaker.FormatText)
+	st.Wrote(time.Millisecond, errors.New("disk full"))
+	st.Dropped()
+	st.Dropped()
+	labels := prometheus.Labels{"logwild_job": "job-1", "sink": "/tmp/out.log", "format": logmaker.FormatText}
+	if got := testutil.ToFloat64(g.errors.With(labels)); got != 1 {
+		t.Errorf("expected one write error, got %v", got)
+	}
+	if got := testutil.ToFloat64(g.dropped.With(labels)); got != 2 {
+		t.Errorf("expected two drops, got %v", got)
+	}
+	if got := testutil.ToFloat64(g.lines.With(labels)); got != 0 {
+		t.Errorf("expected failed writes not to count as lines, got %v", got)
+	}
+}
+
+func TestThatNilGeneratorRecordsNothing(t *testing.T) {
+	var g *Generator
+	st := g.Stream("job-1", "-", logmaker.FormatJSON)
+	if st.Observer() != nil {
+		t.Error("expected no observer without metrics")
+	}
+	var buf bytes.Buffer
+	if w := st.Writer(&buf); w != io.Writer(&buf) {
+		t.Error("expected the sink to be used as is without metrics")
+	}
+	st.Wrote(time.Millisecond, nil)
+	st.SetTarget(10)
+	st.Close()
+	g.JobStarted(KindLoggen)
+	g.JobFinished(KindLoggen)
+}
@@ -0,0 +1,419 @@
+package nodesim
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	mrand "math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"mcgaunn.com/logwild/pkg/logmaker"
+)
+
+// tickInterval is how often the simulator writes a batch of lines for every
+// running container.
+const tickInterval = 100 * time.Millisecond
+
+type OptFunc func(*Opts)
+
+type Opts struct {
+	// Root stands in for /var/log on the node. Pod logs go to Root/pods and
+	// the symlinks to Root/containers.
+	Root             string
+	Pods             int
+	ContainersPerPod int
+	Namespaces       []string
+	// PerSecondRate is the number of lines each container writes per second.
+	PerSecondRate  int64
+	PerMessageSize int64
+	// ChurnInterval is how often a pod is deleted and replaced by a new one.
+	// Zero disables churn.
+	ChurnInterval time.Duration
+	// RestartInterval is how often a container restarts. Zero disables restarts.
+	RestartInterval time.Duration
+	// MaxFileSize and MaxFiles mirror the kubelet's containerLogMaxSize and
+	// containerLogMaxFiles settings.
+	MaxFileSize int64
+	MaxFiles    int
+	// Duration of the simulation. Zero runs until the context is cancelled.
+	Duration time.Duration
+	// LogMakerOpts are applied to the LogMaker of every container, e.g. to
+	// set a level mix or fields.
+	LogMakerOpts []logmaker.OptFunc
+	Logger       *slog.Logger
+}
+
+// Stats counts what happened during a simulation.
+type Stats struct {
+	LogCount    int64 `json:"log_count"`
+	PodsCreated int64 `json:"pods_created"`
+	PodsDeleted int64 `json:"pods_deleted"`
+	Restarts    int64 `json:"restarts"`
+	Rotations   int64 `json:"rotations"`
+}
+
+type Simulator struct {
+	Opts
+	pods []*pod
+
+	logCount    atomic.Int64
+	podsCreated atomic.Int64
+	podsDeleted atomic.Int64
+	restarts    atomic.Int64
+	rotations   atomic.Int64
+}
+
+type pod struct {
+	namespace  string
+	name       string
+	uid        string
+	dir        string
+	containers []*container
+}
+
+type container struct {
+	name     string
+	id       string
+	restarts int
+	link     string
+	out      *rotatingFile
+	lm       *logmaker.LogMaker
+}
+
+var (
+	defaultNamespaces = []string{"default", "kube-system", "payments", "checkout", "monitoring"}
+	workloadNames     = []string{"api", "web", "worker", "checkout", "cart", "auth", "search", "billing", "ingest", "scheduler"}
+	containerNames    = []string{"app", "sidecar", "istio-proxy", "log-shipper"}
+)
+
+func defaultOpts() Opts {
+	return Opts{
+		Root:             filepath.Join(os.TempDir(), "logwild-node"),
+		Pods:             10,
+		ContainersPerPod: 1,
+		Namespaces:       defaultNamespaces,
+		PerSecondRate:    10,
+		PerMessageSize:   12,
+		ChurnInterval:    30 * time.Second,
+		RestartInterval:  time.Minute,
+		MaxFileSize:      10 * 1024 * 1024,
+		MaxFiles:         5,
+		Logger:           slog.Default(),
+	}
+}
+
+func WithRoot(root string) OptFunc {
+	return func(opts *Opts) {
+		opts.Root = root
+	}
+}
+
+func WithPods(n int) OptFunc {
+	return func(opts *Opts) {
+		opts.Pods = n
+	}
+}
+
+func WithContainersPerPod(n int) OptFunc {
+	return func(opts *Opts) {
+		opts.ContainersPerPod = n
+	}
+}
+
+func WithNamespaces(ns []string) OptFunc {
+	return func(opts *Opts) {
+		opts.Namespaces = ns
+	}
+}
+
+func WithPerSecondRate(psr int64) OptFunc {
+	return func(opts *Opts) {
+		opts.PerSecondRate = psr
+	}
+}
+
+func WithPerMessageSize(n int64) OptFunc {
+	return func(opts *Opts) {
+		opts.PerMessageSize = n
+	}
+}
+
+func WithChurnInterval(d time.Duration) OptFunc {
+	return func(opts *Opts) {
+		opts.ChurnInterval = d
+	}
+}
+
+func WithRestartInterval(d time.Duration) OptFunc {
+	return func(opts *Opts) {
+		opts.RestartInterval = d
+	}
+}
+
+func WithRotation(maxFileSize int64, maxFiles int) OptFunc {
+	return func(opts *Opts) {
+		opts.MaxFileSize = maxFileSize
+		opts.MaxFiles = maxFiles
+	}
+}
+
+func WithDuration(d time.Duration) OptFunc {
+	return func(opts *Opts) {
+		opts.Duration = d
+	}
+}
+
+func WithLogMakerOpts(lmOpts ...logmaker.OptFunc) OptFunc {
+	return func(opts *Opts) {
+		opts.LogMakerOpts = lmOpts
+	}
+}
+
+func WithLogger(l *slog.Logger) OptFunc {
+	return func(opts *Opts) {
+		opts.Logger = l
+	}
+}
+
+func NewSimulator(opts ...OptFunc) *Simulator {
+	o := defaultOpts()
+	for _, fn := range opts {
+		fn(&o)
+	}
+	if len(o.Namespaces) == 0 {
+		o.Namespaces = defaultNamespaces
+	}
+	return &Simulator{Opts: o}
+}
+
+// Stats returns what the simulator has done so far.
+func (s *Simulator) Stats() Stats {
+	return Stats{
+		LogCount:    s.logCount.Load(),
+		PodsCreated: s.podsCreated.Load(),
+		PodsDeleted: s.podsDeleted.Load(),
+		Restarts:    s.restarts.Load(),
+		Rotations:   s.rotations.Load(),
+	}
+}
+
+// Run creates the initial pods and writes their logs until the context is
+// cancelled or the configured duration has passed. Pods are left on disk
+// when Run returns so they can be inspected.
+func (s *Simulator) Run(ctx context.Context) error {
+	if s.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Duration)
+		defer cancel()
+	}
+	// symlink targets are resolved from the link's directory, not the
+	// working directory, so they have to be absolute as the kubelet's are
+	root, err := filepath.Abs(s.Root)
+	if err != nil {
+		return err
+	}
+	s.Root = root
+	for _, dir := range []string{s.podsDir(), s.containersDir()} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	defer s.closeAll()
+	for i := 0; i < s.Pods; i++ {
+		p, err := s.createPod()
+		if err != nil {
+			return err
+		}
+		s.pods = append(s.pods, p)
+	}
+	s.Logger.Info("node simulation started", "root", s.Root, "pods", s.Pods, "containersPerPod", s.ContainersPerPod)
+
+	tickr := time.NewTicker(tickInterval)
+	defer tickr.Stop()
+	churn := newOptionalTicker(s.ChurnInterval)
+	defer churn.stop()
+	restart := newOptionalTicker(s.RestartInterval)
+	defer restart.stop()
+
+	linesPerTick := float64(s.PerSecondRate) * tickInterval.Seconds()
+	owed := 0.0
+	for {
+		select {
+		case <-ctx.Done():
+			s.Logger.Info("node simulation finished", "stats", s.Stats())
+			return nil
+		case <-churn.c:
+			if err := s.churnPod(); err != nil {
+				return err
+			}
+		case <-restart.c:
+			if err := s.restartContainer(); err != nil {
+				return err
+			}
+		case <-tickr.C:
+			// carry fractional lines over so low rates still produce output
+			owed += linesPerTick
+			n := int(owed)
+			owed -= float64(n)
+			for _, p := range s.pods {
+				for _, c := range p.containers {
+					for i := 0; i < n; i++ {
+						if err := logmaker.WriteLog(c.lm, logmaker.GetFakeSentence(int(s.PerMessageSize))); err != nil {
+							return err
+						}
+						s.logCount.Add(1)
+					}
+				}
+			}
+		}
+	}
+}
+
+func (s *Simulator) podsDir() string {
+	return filepath.Join(s.Root, "pods")
+}
+
+func (s *Simulator) containersDir() string {
+	return filepath.Join(s.Root, "containers")
+}
+
+func (s *Simulator) createPod() (*pod, error) {
+	workload := workloadNames[mrand.IntN(len(workloadNames))]
+	p := &pod{
+		namespace: s.Namespaces[mrand.IntN(len(s.Namespaces))],
+		name:      fmt.Sprintf("%s-%s-%s", workload, randomHex(5), randomHex(3)[:5]),
+		uid:       newUID(),
+	}
+	p.dir = filepath.Join(s.podsDir(), p.namespace+"_"+p.name+"_"+p.uid)
+	for i := 0; i < s.ContainersPerPod; i++ {
+		name := containerNames[i%len(containerNames)]
+		if i >= len(containerNames) {
+			name += "-" + strconv.Itoa(i/len(containerNames))
+		}
+		c := &container{name: name}
+		if err := os.MkdirAll(filepath.Join(p.dir, name), 0755); err != nil {
+			return nil, err
+		}
+		if err := s.startContainer(p, c); err != nil {
+			return nil, err
+		}
+		p.containers = append(p.containers, c)
+	}
+	s.podsCreated.Add(1)
+	return p, nil
+}
+
+// startContainer opens the log file for the container's current restart
+// count and points a fresh containers/ symlink at it.
+func (s *Simulator) startContainer(p *pod, c *container) error {
+	c.id = randomHex(32)
+	logPath := filepath.Join(p.dir, c.name, strconv.Itoa(c.restarts)+".log")
+	out, err := openRotatingFile(logPath, s.MaxFileSize, s.MaxFiles, func() { s.rotations.Add(1) })
+	if err != nil {
+		return err
+	}
+	c.out = out
+	h, err := logmaker.NewHandler(out, logmaker.FormatCRI)
+	if err != nil {
+		return err
+	}
+	lmOpts := append([]logmaker.OptFunc{}, s.LogMakerOpts...)
+	lmOpts = append(lmOpts, logmaker.WithLogger(slog.New(h)), logmaker.WithDiagnosticLogger(s.Logger))
+	c.lm = logmaker.NewLogMaker(lmOpts...)
+
+	c.link = filepath.Join(s.containersDir(), fmt.Sprintf("%s_%s_%s-%s.log", p.name, p.namespace, c.name, c.id))
+	return os.Symlink(logPath, c.link)
+}
+
+func (s *Simulator) churnPod() error {
+	if len(s.pods) == 0 {
+		return nil
+	}
+	i := mrand.IntN(len(s.pods))
+	old := s.pods[i]
+	for _, c := range old.containers {
+		c.out.Close()
+		os.Remove(c.link)
+	}
+	if err := os.RemoveAll(old.dir); err != nil {
+		return err
+	}
+	s.podsDeleted.Add(1)
+	p, err := s.createPod()
+	if err != nil {
+		return err
+	}
+	s.pods[i] = p
+	s.Logger.Debug("churned pod", "deleted", old.name, "created", p.name)
+	return nil
+}
+
+// restartContainer restarts a random container. Like the kubelet, the logs of
+// the previous instance are kept and anything older is removed.
+func (s *Simulator) restartContainer() error {
+	if len(s.pods) == 0 {
+		return nil
+	}
+	p := s.pods[mrand.IntN(len(s.pods))]
+	if len(p.containers) == 0 {
+		return nil
+	}
+	c := p.containers[mrand.IntN(len(p.containers))]
+	c.out.Close()
+	os.Remove(c.link)
+	if c.restarts > 0 {
+		stale, _ := filepath.Glob(filepath.Join(p.dir, c.name, strconv.Itoa(c.restarts-1)+".log*"))
+		for _, f := range stale {
+			os.Remove(f)
+		}
+	}
+	c.restarts++
+	s.restarts.Add(1)
+	s.Logger.Debug("restarted container", "pod", p.name, "container", c.name, "restarts", c.restarts)
+	return s.startContainer(p, c)
+}
+
+func (s *Simulator) closeAll() {
+	for _, p := range s.pods {
+		for _, c := range p.containers {
+			c.out.Close()
+		}
+	}
+}
+
+// optionalTicker is a ticker whose channel never fires when its interval is zero.
+type optionalTicker struct {
+	t *time.Ticker
+	c <-chan time.Time
+}
+
+func newOptionalTicker(d time.Duration) optionalTicker {
+	if d <= 0 {
+		return optionalTicker{}
+	}
+	t := time.NewTicker(d)
+	return optionalTicker{t: t, c: t.C}
+}
+
+func (o optionalTicker) stop() {
+	if o.t != nil {
+		o.t.Stop()
+	}
+}
+
+func randomHex(nBytes int) string {
+	b := make([]byte, nBytes)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func newUID() string {
+	h := randomHex(16)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
@@ -0,0 +1,134 @@
+package nodesim
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSimulatorWritesKubeletLayout(t *testing.T) {
+	root := t.TempDir()
+	sim := NewSimulator(
+		WithRoot(root),
+		WithPods(3),
+		WithContainersPerPod(2),
+		WithPerSecondRate(200),
+		WithChurnInterval(0),
+		WithRestartInterval(0),
+		WithDuration(500*time.Millisecond))
+	if err := sim.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	podDirs, err := os.ReadDir(filepath.Join(root, "pods"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(podDirs) != 3 {
+		t.Errorf("expected 3 pod dirs, got %d", len(podDirs))
+	}
+	podDir := regexp.MustCompile(`^[a-z0-9-]+_[a-z0-9-]+_[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+	for _, d := range podDirs {
+		if !podDir.MatchString(d.Name()) {
+			t.Errorf("pod dir %q is not named <ns>_<pod>_<uid>", d.Name())
+		}
+	}
+
+	links, err := os.ReadDir(filepath.Join(root, "containers"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 6 {
+		t.Errorf("expected 6 container symlinks, got %d", len(links))
+	}
+	criLine := regexp.MustCompile(`^\S+Z (stdout|stderr) F `)
+	for _, l := range links {
+		content, err := os.ReadFile(filepath.Join(root, "containers", l.Name()))
+		if err != nil {
+			t.Fatalf("symlink %s does not resolve: %s", l.Name(), err)
+		}
+		first, _, _ := strings.Cut(string(content), "\n")
+		if !criLine.MatchString(first) {
+			t.Errorf("expected CRI formatted logs, got %q", first)
+		}
+	}
+	if sim.Stats().LogCount == 0 {
+		t.Errorf("expected some lines to be written")
+	}
+}
+
+func TestSimulatorRotatesRestartsAndChurns(t *testing.T) {
+	root := t.TempDir()
+	sim := NewSimulator(
+		WithRoot(root),
+		WithPods(2),
+		WithPerSecondRate(500),
+		WithRotation(4096, 3),
+		WithChurnInterval(200*time.Millisecond),
+		WithRestartInterval(150*time.Millisecond),
+		WithDuration(time.Second))
+	if err := sim.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	stats := sim.Stats()
+	if stats.Rotations == 0 || stats.Restarts == 0 || stats.PodsDeleted == 0 {
+		t.Errorf("expected rotations, restarts and churn, got %+v", stats)
+	}
+
+	podDirs, _ := os.ReadDir(filepath.Join(root, "pods"))
+	if len(podDirs) != 2 {
+		t.Errorf("expected churn to keep 2 pods, got %d", len(podDirs))
+	}
+	links, _ := os.ReadDir(filepath.Join(root, "containers"))
+	if len(links) != 2 {
+		t.Errorf("expected one symlink per live container, got %d", len(links))
+	}
+	for _, d := range podDirs {
+		files, _ := filepath.Glob(filepath.Join(root, "pods", d.Name(), "app", "*.log*"))
+		live := 0
+		for _, f := range files {
+			if strings.HasSuffix(f, ".log") {
+				live++
+			}
+		}
+		if live > 2 {
+			t.Errorf("expected at most the current and previous container logs, got %v", files)
+		}
+	}
+}
+
+func TestSimulatorLinksResolveWithARelativeRoot(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	sim := NewSimulator(
+		WithRoot("rel"),
+		WithPods(1),
+		WithContainersPerPod(1),
+		WithPerSecondRate(100),
+		WithChurnInterval(0),
+		WithRestartInterval(0),
+		WithDuration(200*time.Millisecond))
+	if err := sim.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	links, err := os.ReadDir(filepath.Join("rel", "containers"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 1 {
+		t.Fatalf("expected 1 container symlink, got %d", len(links))
+	}
+	if _, err := os.Stat(filepath.Join("rel", "containers", links[0].Name())); err != nil {
+		t.Errorf("symlink %s does not resolve: %s", links[0].Name(), err)
+	}
+}
@@ -0,0 +1,154 @@
+package nodesim
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatingFile is an io.Writer over a container log file that rotates the
+// way the kubelet does: once the file grows past maxSize it is renamed to
+// "<name>.<timestamp>", the previously rotated file is gzipped, and only
+// maxFiles files (including the live one) are kept.
+type rotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	f        *os.File
+	size     int64
+	onRotate func()
+}
+
+func openRotatingFile(path string, maxSize int64, maxFiles int, onRotate func()) (*rotatingFile, error) {
+	rf := &rotatingFile{path: path, maxSize: maxSize, maxFiles: maxFiles, onRotate: onRotate}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *rotatingFile) open() error {
+	f, err := os.OpenFile(rf.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	rf.f = f
+	rf.size = info.Size()
+	return nil
+}
+
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.f == nil {
+		return 0, os.ErrClosed
+	}
+	if rf.maxSize > 0 && rf.size > 0 && rf.size+int64(len(p)) > rf.maxSize {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rf.f.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+func (rf *rotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.f == nil {
+		return nil
+	}
+	err := rf.f.Close()
+	rf.f = nil
+	return err
+}
+
+// rotate must be called with rf.mu held.
+func (rf *rotatingFile) rotate() error {
+	if err := rf.f.Close(); err != nil {
+		return err
+	}
+	rotated := rf.path + "." + time.Now().UTC().Format("20060102-150405.000000")
+	if err := os.Rename(rf.path, rotated); err != nil {
+		return err
+	}
+	if err := rf.compressAndPrune(rotated); err != nil {
+		return err
+	}
+	if rf.onRotate != nil {
+		rf.onRotate()
+	}
+	return rf.open()
+}
+
+// compressAndPrune gzips every rotated file except the newest one, like the
+// kubelet does, then deletes the oldest files beyond maxFiles.
+func (rf *rotatingFile) compressAndPrune(newest string) error {
+	matches, err := filepath.Glob(rf.path + ".*")
+	if err != nil {
+		return err
+	}
+	for _, m := range matches {
+		if m == newest || strings.HasSuffix(m, ".gz") {
+			continue
+		}
+		if err := gzipFile(m); err != nil {
+			return err
+		}
+	}
+	matches, err = filepath.Glob(rf.path + ".*")
+	if err != nil {
+		return err
+	}
+	// timestamps sort lexically, so the oldest files come first
+	sort.Strings(matches)
+	keep := rf.maxFiles - 1
+	if keep < 1 {
+		keep = 1
+	}
+	for len(matches) > keep {
+		if err := os.Remove(matches[0]); err != nil {
+			return err
+		}
+		matches = matches[1:]
+	}
+	return nil
+}
+
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(path + ".gz")
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	if _, err := io.Copy(zw, in); err != nil {
+		out.Close()
+		return fmt.Errorf("compressing %s: %w", path, err)
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,237 @@
+// Package report summarises a log generation run: target against achieved
+// rate over time, write latency, errors by sink, and whether the run met its
+// service level objectives. Reports are written as JSON, Markdown or JUnit
+// XML, so CI can show a run as test results.
+package report
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"mcgaunn.com/logwild/pkg/logmaker"
+)
+
+// formats a report can be written in
+const (
+	FormatJSON     = "json"
+	FormatMarkdown = "markdown"
+	FormatJUnit    = "junit"
+)
+
+// Formats lists every report format.
+var Formats = []string{FormatJSON, FormatMarkdown, FormatJUnit}
+
+// SLO holds the thresholds a run is checked against.
+type SLO struct {
+	// MinRateRatio is the lowest ratio of achieved to target events that
+	// passes, e.g. 0.95. 0 skips the check.
+	MinRateRatio float64
+	// MaxP99Latency is the slowest 99th percentile write latency that
+	// passes. 0 skips the check.
+	MaxP99Latency time.Duration
+	// MaxErrors is the most write errors that pass.
+	MaxErrors int64
+}
+
+// Source is the part of a run written by one LogMaker, and the sink it wrote
+// to.
+type Source struct {
+	Sink   string             `json:"sink"`
+	Record logmaker.RunRecord `json:"record"`
+}
+
+// Latency summarises how long writes took, in microseconds.
+type Latency struct {
+	Count      int64   `json:"count"`
+	MeanMicros float64 `json:"mean_us"`
+	P50Micros  float64 `json:"p50_us"`
+	P90Micros  float64 `json:"p90_us"`
+	P99Micros  float64 `json:"p99_us"`
+	MaxMicros  float64 `json:"max_us"`
+}
+
+// Check is the outcome of one SLO threshold.
+type Check struct {
+	Name      string `json:"name"`
+	Threshold string `json:"threshold"`
+	Actual    string `json:"actual"`
+	Pass      bool   `json:"pass"`
+}
+
+// Report is the summary of a run.
+type Report struct {
+	Name      string    `json:"name"`
+	StartedAt time.Time `json:"started_at"`
+	// FinishedAt is unset while the run is going.
+	FinishedAt        *time.Time              `json:"finished_at,omitempty"`
+	DurationSeconds   float64                 `json:"duration_seconds"`
+	LogCount          int64                   `json:"log_count"`
+	TargetCount       int64                   `json:"target_count"`
+	AchievedPerSecond float64                 `json:"achieved_per_second"`
+	RateRatio         float64                 `json:"rate_ratio"`
+	Series            []logmaker.SecondSample `json:"series"`
+	Latency           Latency                 `json:"write_latency"`
+	Errors            map[string]int64        `json:"errors"`
+	Checks            []Check                 `json:"checks"`
+	Pass              bool                    `json:"pass"`
+}
+
+// New builds a report called name from the sources of a run, summing their
+// series second by second, and checks it against slo.
+func New(name string, slo SLO, sources ...Source) Report {
+	rep := Report{Name: name, Errors: map[string]int64{}}
+	var latency logmaker.WriteLatency
+	var finishedAt time.Time
+	running := false
+	for _, src := range sources {
+		rec := src.Record
+		if rep.StartedAt.IsZero() || (!rec.StartedAt.IsZero() && rec.StartedAt.Before(rep.StartedAt)) {
+			rep.StartedAt = rec.StartedAt
+		}
+		if rec.FinishedAt.IsZero() {
+			running = true
+		} else if rec.FinishedAt.After(finishedAt) {
+			finishedAt = rec.FinishedAt
+		}
+		// long runs only keep their latest seconds, so line sources up by
+		// second rather than by position
+		for _, smp := range rec.Series {
+			i, found := slices.BinarySearchFunc(rep.Series, smp.Second, func(a logmaker.SecondSample, second int) int { return a.Second - second })
+			if !found {
+				rep.Series = slices.Insert(rep.Series, i, logmaker.SecondSample{Second: smp.Second})
+			}
+			rep.Series[i].Target += smp.Target
+			rep.Series[i].Achieved += smp.Achieved
+		}
+		latency.Merge(rec.Latency)
+		rep.LogCount += rec.Stats.LogCount
+		rep.Errors[src.Sink] += rec.Stats.WriteErrors
+	}
+	end := time.Now()
+	if !running && !finishedAt.IsZero() {
+		end = finishedAt
+		rep.FinishedAt = &finishedAt
+	}
+	if !rep.StartedAt.IsZero() {
+		rep.DurationSeconds = end.Sub(rep.StartedAt).Seconds()
+	}
+	if rep.DurationSeconds > 0 {
+		rep.AchievedPerSecond = float64(rep.LogCount) / rep.DurationSeconds
+	}
+	// the ratio only covers seconds that have been sampled, so it isn't
+	// thrown off by events written since the last sample
+	var sampled int64
+	for _, smp := range rep.Series {
+		rep.TargetCount += smp.Target
+		sampled += smp.Achieved
+	}
+	if rep.TargetCount > 0 {
+		rep.RateRatio = float64(sampled) / float64(rep.TargetCount)
+	}
+	rep.Latency = Latency{
+		Count:      latency.Count,
+		MeanMicros: micros(latency.Mean()),
+		P50Micros:  micros(latency.Quantile(0.5)),
+		P90Micros:  micros(latency.Quantile(0.9)),
+		P99Micros:  micros(latency.Quantile(0.99)),
+		MaxMicros:  micros(latency.Max),
+	}
+	rep.check(slo, latency.Quantile(0.99))
+	return rep
+}
+
+func micros(d time.Duration) float64 {
+	return float64(d) / float64(time.Microsecond)
+}
+
+func (rep *Report) check(slo SLO, p99 time.Duration) {
+	if slo.MinRateRatio > 0 {
+		rep.Checks = append(rep.Checks, Check{
+			Name:      "achieved rate",
+			Threshold: fmt.Sprintf(">= %.1f%% of target", slo.MinRateRatio*100),
+			Actual:    fmt.Sprintf("%.1f%% of target", rep.RateRatio*100),
+			Pass:      rep.RateRatio >= slo.MinRateRatio,
+		})
+	}
+	if slo.MaxP99Latency > 0 {
+		rep.Checks = append(rep.Checks, Check{
+			Name:      "p99 write latency",
+			Threshold: fmt.Sprintf("<= %s", slo.MaxP99Latency),
+			Actual:    p99.String(),
+			Pass:      p99 <= slo.MaxP99Latency,
+		})
+	}
+	var errs int64
+	for _, n := range rep.Errors {
+		errs += n
+	}
+	rep.Checks = append(rep.Checks, Check{
+		Name:      "write errors",
+		Threshold: fmt.Sprintf("<= %d", slo.MaxErrors),
+		Actual:    fmt.Sprint(errs),
+		Pass:      errs <= slo.MaxErrors,
+	})
+	rep.Pass = true
+	for _, c := range rep.Checks {
+		rep.Pass = rep.Pass && c.Pass
+	}
+}
+
+// sinks lists the sinks in the report in name order.
+func (rep Report) sinks() []string {
+	sinks := make([]string, 0, len(rep.Errors))
+	for sink := range rep.Errors {
+		sinks = append(sinks, sink)
+	}
+	sort.Strings(sinks)
+	return sinks
+}
+
+// Write writes the report to w in format.
+func (rep Report) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatJSON:
+		return rep.writeJSON(w)
+	case FormatMarkdown:
+		return rep.writeMarkdown(w)
+	case FormatJUnit:
+		return rep.writeJUnit(w)
+	}
+	return fmt.Errorf("unknown report format %q, expected one of %s", format, strings.Join(Formats, ", "))
+}
+
+// WriteFile writes the report to path in format, replacing the file if it
+// exists. A path of "-" writes to stdout.
+func (rep Report) WriteFile(path, format string) error {
+	if !slices.Contains(Formats, format) {
+		return fmt.Errorf("unknown report format %q, expected one of %s", format, strings.Join(Formats, ", "))
+	}
+	if path == "-" {
+		return rep.Write(os.Stdout, format)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := rep.Write(f, format); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ContentType is the media type of a report written in format.
+func ContentType(format string) string {
+	switch format {
+	case FormatMarkdown:
+		return "text/markdown; charset=utf-8"
+	case FormatJUnit:
+		return "application/xml; charset=utf-8"
+	}
+	return "application/json; charset=utf-8"
+}
@@ -0,0 +1,108 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"mcgaunn.com/logwild/pkg/logmaker"
+)
+
+func testSources() []Source {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	return []Source{
+		{
+			Sink: "/var/log/web.log",
+			Record: logmaker.RunRecord{
+				StartedAt:  start,
+				FinishedAt: start.Add(2 * time.Second),
+				Series:     []logmaker.SecondSample{{Second: 0, Target: 100, Achieved: 100}, {Second: 1, Target: 100, Achieved: 90}},
+				Stats:      logmaker.Stats{LogCount: 190},
+			},
+		},
+		{
+			Sink: "/var/log/payments.log",
+			Record: logmaker.RunRecord{
+				StartedAt:  start,
+				FinishedAt: start.Add(time.Second),
+				Series:     []logmaker.SecondSample{{Second: 0, Target: 10, Achieved: 9}},
+				Stats:      logmaker.Stats{LogCount: 9, WriteErrors: 1},
+			},
+		},
+	}
+}
+
+func TestThatNewSumsSourcesAndChecksSLO(t *testing.T) {
+	rep := New("checkout", SLO{MinRateRatio: 0.9}, testSources()...)
+	if rep.LogCount != 199 || rep.TargetCount != 210 || rep.DurationSeconds != 2 {
+		t.Errorf("expected sources to be summed, got %+v", rep)
+	}
+	if len(rep.Series) != 2 || rep.Series[0].Target != 110 || rep.Series[0].Achieved != 109 {
+		t.Errorf("expected series summed by second, got %+v", rep.Series)
+	}
+	if rep.FinishedAt == nil || rep.Errors["/var/log/payments.log"] != 1 {
+		t.Errorf("expected a finished run with errors by sink, got %+v", rep)
+	}
+	if len(rep.Checks) != 2 || !rep.Checks[0].Pass || rep.Checks[1].Pass || rep.Pass {
+		t.Errorf("expected the rate check to pass and the error check to fail, got %+v", rep.Checks)
+	}
+}
+
+func TestThatNewLinesUpSeriesBySecond(t *testing.T) {
+	// a long run only has its latest seconds, a short one all of them
+	long := Source{Record: logmaker.RunRecord{Series: []logmaker.SecondSample{{Second: 5, Target: 10, Achieved: 10}, {Second: 6, Target: 10, Achieved: 8}}}}
+	short := Source{Record: logmaker.RunRecord{Series: []logmaker.SecondSample{{Second: 0, Target: 1, Achieved: 1}, {Second: 6, Target: 1, Achieved: 1}}}}
+	rep := New("long", SLO{}, long, short)
+	want := []logmaker.SecondSample{{Second: 0, Target: 1, Achieved: 1}, {Second: 5, Target: 10, Achieved: 10}, {Second: 6, Target: 11, Achieved: 9}}
+	if len(rep.Series) != len(want) {
+		t.Fatalf("expected series %+v, got %+v", want, rep.Series)
+	}
+	for i := range want {
+		if rep.Series[i] != want[i] {
+			t.Errorf("expected series %+v, got %+v", want, rep.Series)
+			break
+		}
+	}
+}
+
+func TestThatReportsAreWrittenInEveryFormat(t *testing.T) {
+	rep := New("checkout", SLO{MinRateRatio: 0.99, MaxErrors: 1}, testSources()...)
+
+	var buf bytes.Buffer
+	if err := rep.Write(&buf, FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Report
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || decoded.LogCount != 199 {
+		t.Errorf("expected a JSON report, got %v: %s", err, buf.String())
+	}
+
+	buf.Reset()
+	if err := rep.Write(&buf, FormatMarkdown); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"**Result: FAIL**", "| achieved rate | >= 99.0% of target | 94.8% of target | FAIL |", "| 1 | 100 | 90 |"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected markdown to contain %q, got:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := rep.Write(&buf, FormatJUnit); err != nil {
+		t.Fatal(err)
+	}
+	var suites junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Fatal(err)
+	}
+	if suites.Tests != 2 || suites.Failures != 1 || suites.Suites[0].Cases[0].Failure == nil {
+		t.Errorf("expected one failing and one passing test case, got:\n%s", buf.String())
+	}
+
+	if err := rep.Write(&buf, "yaml"); err == nil {
+		t.Error("expected an unknown format to be an error")
+	}
+}
@@ -0,0 +1,149 @@
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+func (rep Report) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rep)
+}
+
+func passFail(pass bool) string {
+	if pass {
+		return "pass"
+	}
+	return "FAIL"
+}
+
+func (rep Report) writeMarkdown(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# logwild run report: %s\n\n", rep.Name)
+	fmt.Fprintf(&b, "**Result: %s**\n\n", passFail(rep.Pass))
+	b.WriteString("| | |\n|---|---|\n")
+	fmt.Fprintf(&b, "| started | %s |\n", rep.StartedAt.Format("2006-01-02 15:04:05 MST"))
+	if rep.FinishedAt == nil {
+		b.WriteString("| finished | still running |\n")
+	} else {
+		fmt.Fprintf(&b, "| finished | %s |\n", rep.FinishedAt.Format("2006-01-02 15:04:05 MST"))
+	}
+	fmt.Fprintf(&b, "| duration | %.1fs |\n", rep.DurationSeconds)
+	fmt.Fprintf(&b, "| logs written | %d |\n", rep.LogCount)
+	fmt.Fprintf(&b, "| achieved rate | %.1f/s |\n", rep.AchievedPerSecond)
+	fmt.Fprintf(&b, "| achieved / target | %.1f%% |\n", rep.RateRatio*100)
+
+	b.WriteString("\n## SLO checks\n\n| check | threshold | actual | result |\n|---|---|---|---|\n")
+	for _, c := range rep.Checks {
+		fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", c.Name, c.Threshold, c.Actual, passFail(c.Pass))
+	}
+
+	l := rep.Latency
+	b.WriteString("\n## Write latency\n\n| writes | mean | p50 | p90 | p99 | max |\n|---|---|---|---|---|---|\n")
+	fmt.Fprintf(&b, "| %d | %.1fµs | %.1fµs | %.1fµs | %.1fµs | %.1fµs |\n",
+		l.Count, l.MeanMicros, l.P50Micros, l.P90Micros, l.P99Micros, l.MaxMicros)
+
+	b.WriteString("\n## Errors by sink\n\n| sink | errors |\n|---|---|\n")
+	for _, sink := range rep.sinks() {
+		fmt.Fprintf(&b, "| %s | %d |\n", sink, rep.Errors[sink])
+	}
+
+	b.WriteString("\n## Rate per second\n\n| second | target | achieved |\n|---|---|---|\n")
+	for _, smp := range rep.Series {
+		fmt.Fprintf(&b, "| %d | %d | %d |\n", smp.Second, smp.Target, smp.Achieved)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     float64          `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Time       float64         `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property"`
+	Cases      []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnit writes every SLO check as a test case, so a failed check fails
+// the CI step that reads the report.
+func (rep Report) writeJUnit(w io.Writer) error {
+	suite := junitTestSuite{
+		Name:      "logwild " + rep.Name,
+		Tests:     len(rep.Checks),
+		Time:      rep.DurationSeconds,
+		Timestamp: rep.StartedAt.Format("2006-01-02T15:04:05"),
+		Properties: []junitProperty{
+			{Name: "log_count", Value: fmt.Sprint(rep.LogCount)},
+			{Name: "achieved_per_second", Value: fmt.Sprintf("%.1f", rep.AchievedPerSecond)},
+			{Name: "rate_ratio", Value: fmt.Sprintf("%.3f", rep.RateRatio)},
+			{Name: "p50_write_latency_us", Value: fmt.Sprintf("%.1f", rep.Latency.P50Micros)},
+			{Name: "p99_write_latency_us", Value: fmt.Sprintf("%.1f", rep.Latency.P99Micros)},
+		},
+	}
+	for _, c := range rep.Checks {
+		tc := junitTestCase{
+			Name:      c.Name,
+			Classname: "logwild." + rep.Name,
+			Time:      rep.DurationSeconds,
+			SystemOut: fmt.Sprintf("threshold %s, actual %s", c.Threshold, c.Actual),
+		}
+		if !c.Pass {
+			suite.Failures++
+			tc.Failure = &junitFailure{
+				Message: fmt.Sprintf("%s was %s, expected %s", c.Name, c.Actual, c.Threshold),
+				Text:    fmt.Sprintf("threshold %s, actual %s", c.Threshold, c.Actual),
+			}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	suites := junitTestSuites{
+		Name:     "logwild",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,89 @@
+package scenario
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+)
+
+// presetFiles are the built-in scenarios, one per file named after the
+// preset.
+//
+//go:embed presets/*.yaml
+var presetFiles embed.FS
+
+// presets are parsed once, in name order.
+var presets = mustLoadPresets()
+
+func mustLoadPresets() []*Scenario {
+	entries, err := presetFiles.ReadDir("presets")
+	if err != nil {
+		panic(err)
+	}
+	var scs []*Scenario
+	for _, entry := range entries {
+		data, err := presetFiles.ReadFile(path.Join("presets", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+		sc, err := Parse(data)
+		if err != nil {
+			panic(fmt.Sprintf("preset %s: %v", entry.Name(), err))
+		}
+		scs = append(scs, sc)
+	}
+	sort.Slice(scs, func(a, b int) bool { return scs[a].Name < scs[b].Name })
+	return scs
+}
+
+// Presets returns the built-in scenarios. Callers get their own copies, so
+// they are free to change them.
+func Presets() []*Scenario {
+	scs := make([]*Scenario, 0, len(presets))
+	for _, sc := range presets {
+		scs = append(scs, sc.clone())
+	}
+	return scs
+}
+
+// Preset returns the built-in scenario called name, or nil if there isn't
+// one.
+func Preset(name string) *Scenario {
+	for _, sc := range presets {
+		if sc.Name == name {
+			return sc.clone()
+		}
+	}
+	return nil
+}
+
+// PresetNames lists the names of the built-in scenarios.
+func PresetNames() []string {
+	names := make([]string, 0, len(presets))
+	for _, sc := range presets {
+		names = append(names, sc.Name)
+	}
+	return names
+}
+
+func (sc *Scenario) clone() *Scenario {
+	c := *sc
+	c.Streams = append([]Stream{}, sc.Streams...)
+	c.Phases = make([]Phase, len(sc.Phases))
+	for i, ph := range sc.Phases {
+		if ph.Multiplier != nil {
+			m := *ph.Multiplier
+			ph.Multiplier = &m
+		}
+		if ph.Rates != nil {
+			rates := make(map[string]int64, len(ph.Rates))
+			for k, v := range ph.Rates {
+				rates[k] = v
+			}
+			ph.Rates = rates
+		}
+		c.Phases[i] = ph
+	}
+	return &c
+}
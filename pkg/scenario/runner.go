@@ -0,0 +1,292 @@
+package scenario
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"mcgaunn.com/logwild/pkg/logmaker"
+	"mcgaunn.com/logwild/pkg/metrics"
+	"mcgaunn.com/logwild/pkg/report"
+)
+
+type OptFunc func(*Opts)
+
+type Opts struct {
+	// DefaultSink is used by streams that don't name a sink.
+	DefaultSink string
+	// DefaultFormat is used by streams that don't name a format.
+	DefaultFormat string
+	// RampInterval is how often rates are recalculated during a linear
+	// transition.
+	RampInterval time.Duration
+	// LogMakerOpts are applied to every stream's LogMaker before the
+	// stream's own settings.
+	LogMakerOpts []logmaker.OptFunc
+	Logger       *slog.Logger
+	// Metrics records generator metrics for every stream, labelled with
+	// MetricsJob and the stream name.
+	Metrics    *metrics.Generator
+	MetricsJob string
+	// StartAt delays the first phase until then, so runs on several hosts
+	// can start together. Zero starts right away.
+	StartAt time.Time
+}
+
+// Result is what a scenario run wrote, per stream.
+type Result struct {
+	Scenario       string                    `json:"scenario"`
+	ElapsedSeconds float64                   `json:"elapsed_seconds"`
+	Phase          string                    `json:"phase,omitempty"`
+	Streams        map[string]logmaker.Stats `json:"streams"`
+}
+
+// Runner runs a scenario.
+type Runner struct {
+	Opts
+	scenario *Scenario
+
+	mu      sync.Mutex
+	phase   string
+	start   time.Time
+	streams []*streamRun
+}
+
+type streamRun struct {
+	stream  Stream
+	sink    string
+	lm      *logmaker.LogMaker
+	metrics *metrics.Stream
+}
+
+func defaultOpts() Opts {
+	return Opts{
+		DefaultSink:   "-",
+		DefaultFormat: logmaker.FormatJSON,
+		RampInterval:  250 * time.Millisecond,
+		Logger:        slog.Default(),
+	}
+}
+
+func WithDefaultSink(path string) OptFunc {
+	return func(opts *Opts) {
+		opts.DefaultSink = path
+	}
+}
+
+func WithDefaultFormat(format string) OptFunc {
+	return func(opts *Opts) {
+		opts.DefaultFormat = format
+	}
+}
+
+func WithRampInterval(d time.Duration) OptFunc {
+	return func(opts *Opts) {
+		opts.RampInterval = d
+	}
+}
+
+func WithLogMakerOpts(lmOpts ...logmaker.OptFunc) OptFunc {
+	return func(opts *Opts) {
+		opts.LogMakerOpts = lmOpts
+	}
+}
+
+func WithLogger(l *slog.Logger) OptFunc {
+	return func(opts *Opts) {
+		opts.Logger = l
+	}
+}
+
+// WithMetrics records generator metrics for the run's streams, labelled as
+// job/stream.
+func WithMetrics(g *metrics.Generator, job string) OptFunc {
+	return func(opts *Opts) {
+		opts.Metrics = g
+		opts.MetricsJob = job
+	}
+}
+
+func WithStartAt(t time.Time) OptFunc {
+	return func(opts *Opts) {
+		opts.StartAt = t
+	}
+}
+
+func NewRunner(sc *Scenario, opts ...OptFunc) *Runner {
+	o := defaultOpts()
+	for _, fn := range opts {
+		fn(&o)
+	}
+	return &Runner{Opts: o, scenario: sc}
+}
+
+// Run opens the sinks, starts every stream, and steps through the phases,
+// adjusting stream rates as it goes. It returns when the last phase is over
+// or ctx is done, with what each stream wrote either way.
+func (rn *Runner) Run(ctx context.Context) (Result, error) {
+	sinks := map[string]io.WriteCloser{}
+	defer func() {
+		for _, sink := range sinks {
+			sink.Close()
+		}
+	}()
+	streams := make([]*streamRun, 0, len(rn.scenario.Streams))
+	for _, st := range rn.scenario.Streams {
+		path := st.Sink
+		if path == "" {
+			path = rn.DefaultSink
+		}
+		// streams writing to the same file share one handle
+		sink, ok := sinks[path]
+		if !ok {
+			var err error
+			if sink, err = logmaker.OpenSink(path); err != nil {
+				return Result{}, err
+			}
+			sinks[path] = sink
+		}
+		sr := &streamRun{stream: st, sink: path}
+		if err := rn.newLogMaker(sr, sink); err != nil {
+			return Result{}, err
+		}
+		defer sr.metrics.Close()
+		streams = append(streams, sr)
+	}
+
+	if wait := time.Until(rn.StartAt); wait > 0 {
+		rn.Logger.Info("scenario waiting to start", "scenario", rn.scenario.Name, "start_at", rn.StartAt)
+		start := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			start.Stop()
+			return rn.Result(), ctx.Err()
+		case <-start.C:
+		}
+	}
+
+	rn.mu.Lock()
+	rn.streams = streams
+	rn.start = time.Now()
+	rn.mu.Unlock()
+
+	runCtx, stopStreams := context.WithCancel(ctx)
+	defer stopStreams()
+	var wg sync.WaitGroup
+	errs := make([]error, len(streams))
+	for i, sr := range streams {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = sr.lm.Run(runCtx)
+		}()
+	}
+
+	err := rn.runPhases(ctx)
+	stopStreams()
+	wg.Wait()
+	if err == nil {
+		err = errors.Join(errs...)
+	}
+	return rn.Result(), err
+}
+
+func (rn *Runner) newLogMaker(sr *streamRun, sink io.Writer) error {
+	st := sr.stream
+	format := st.Format
+	if format == "" {
+		format = rn.DefaultFormat
+	}
+	sr.metrics = rn.Metrics.Stream(rn.MetricsJob+"/"+st.Name, sr.sink, format)
+	h, err := logmaker.NewHandler(sr.metrics.Writer(sink), format)
+	if err != nil {
+		return err
+	}
+	optFuncs := append([]logmaker.OptFunc{}, rn.LogMakerOpts...)
+	optFuncs = append(optFuncs, st.LogMakerOpts()...)
+	optFuncs = append(optFuncs,
+		logmaker.WithPerSecondRate(rn.scenario.RateAt(0, 0, st)),
+		logmaker.WithBurstDuration(0),
+		logmaker.WithLogger(slog.New(h)),
+		logmaker.WithDiagnosticLogger(rn.Logger),
+		logmaker.WithObserver(sr.metrics.Observer()))
+	sr.lm = logmaker.NewLogMaker(optFuncs...)
+	sr.metrics.SetTarget(sr.lm.PerSecondRate)
+	return nil
+}
+
+func (rn *Runner) runPhases(ctx context.Context) error {
+	tickr := time.NewTicker(rn.RampInterval)
+	defer tickr.Stop()
+	for i, ph := range rn.scenario.Phases {
+		rn.mu.Lock()
+		rn.phase = ph.Name
+		rn.mu.Unlock()
+		rn.Logger.Info("scenario phase started", "scenario", rn.scenario.Name, "phase", ph.Name, "duration", ph.Duration)
+		phaseStart := time.Now()
+		end := time.NewTimer(ph.Duration)
+		rn.setRates(i, 0)
+		for done := false; !done; {
+			select {
+			case <-ctx.Done():
+				end.Stop()
+				return ctx.Err()
+			case <-end.C:
+				done = true
+			case <-tickr.C:
+				if ph.Transition == TransitionLinear {
+					rn.setRates(i, time.Since(phaseStart))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (rn *Runner) setRates(phase int, elapsed time.Duration) {
+	for _, sr := range rn.streams {
+		rate := rn.scenario.RateAt(phase, elapsed, sr.stream)
+		if rate != sr.lm.Options().PerSecondRate {
+			sr.lm.Update(logmaker.WithPerSecondRate(rate))
+			sr.metrics.SetTarget(rate)
+		}
+	}
+}
+
+// Result reports what each stream has written so far and the current phase.
+func (rn *Runner) Result() Result {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	res := Result{
+		Scenario: rn.scenario.Name,
+		Phase:    rn.phase,
+		Streams:  make(map[string]logmaker.Stats, len(rn.streams)),
+	}
+	if !rn.start.IsZero() {
+		res.ElapsedSeconds = time.Since(rn.start).Seconds()
+	}
+	for _, sr := range rn.streams {
+		res.Streams[sr.stream.Name] = sr.lm.Stats()
+	}
+	return res
+}
+
+// Report summarises the run so far, with every stream's sink as a source.
+func (rn *Runner) Report(slo report.SLO) report.Report {
+	return report.New(rn.scenario.Name, slo, rn.Sources()...)
+}
+
+// Sources is what each stream recorded so far, with its sink, for reports
+// that combine several runs.
+func (rn *Runner) Sources() []report.Source {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	sources := make([]report.Source, 0, len(rn.streams))
+	for _, sr := range rn.streams {
+		sources = append(sources, report.Source{Sink: sr.sink, Record: sr.lm.Record()})
+	}
+	return sources
+}
@@ -0,0 +1,254 @@
+// Package scenario describes multi-stream, multi-phase load plans and runs
+// them with one LogMaker per stream.
+package scenario
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"mcgaunn.com/logwild/pkg/logmaker"
+)
+
+// transitions between the rates of one phase and the next
+const (
+	// TransitionStep switches to the phase's rates as soon as it starts.
+	TransitionStep = "step"
+	// TransitionLinear ramps from the previous phase's rates to this one's
+	// over the phase's duration. The first phase ramps up from 0.
+	TransitionLinear = "linear"
+)
+
+// Scenario is a load plan: streams of generated logs, and phases that shape
+// their rates over time.
+type Scenario struct {
+	Name        string   `yaml:"name" json:"name"`
+	Description string   `yaml:"description" json:"description,omitempty"`
+	Streams     []Stream `yaml:"streams" json:"streams"`
+	Phases      []Phase  `yaml:"phases" json:"phases"`
+}
+
+// Stream is one source of logs, written by its own LogMaker.
+type Stream struct {
+	Name string `yaml:"name" json:"name"`
+	// Format is one of logmaker.Formats. Empty uses the runner's default.
+	Format string `yaml:"format" json:"format,omitempty"`
+	// Sink is the file the stream appends to, or "-" for stdout. Empty uses
+	// the runner's default.
+	Sink string `yaml:"sink" json:"sink,omitempty"`
+	// Rate is the stream's events per second before phases scale it.
+	Rate        int64  `yaml:"rate" json:"rate"`
+	MessageSize int64  `yaml:"message_size" json:"message_size,omitempty"`
+	LevelMix    string `yaml:"level_mix" json:"level_mix,omitempty"`
+	// Fields uses the --log-fields syntax, e.g. "tenant:50:zipf,pod:500".
+	Fields string `yaml:"fields" json:"fields,omitempty"`
+	// Message is a template for the message of every event, where {name} is
+	// the event's value of field name, e.g. `{client_ip} "GET {path}"`.
+	// Empty writes random sentences of MessageSize words.
+	Message           string  `yaml:"message" json:"message,omitempty"`
+	MultilineFraction float64 `yaml:"multiline_fraction" json:"multiline_fraction,omitempty"`
+}
+
+// LogMakerOpts are the options that give a LogMaker the stream's rate and the
+// shape of its events. The stream must be valid.
+func (st Stream) LogMakerOpts() []logmaker.OptFunc {
+	optFuncs := []logmaker.OptFunc{logmaker.WithPerSecondRate(st.Rate)}
+	if st.MessageSize > 0 {
+		optFuncs = append(optFuncs, logmaker.WithPerMessageSize(st.MessageSize))
+	}
+	// already validated, so these can't fail
+	if st.LevelMix != "" {
+		mix, _ := logmaker.ParseLevelMix(st.LevelMix)
+		optFuncs = append(optFuncs, logmaker.WithLevelMix(mix))
+	}
+	if st.Fields != "" {
+		fields, _ := logmaker.ParseFieldSpecs(st.Fields)
+		optFuncs = append(optFuncs, logmaker.WithFields(fields))
+	}
+	if st.Message != "" {
+		optFuncs = append(optFuncs, logmaker.WithMessage(st.Message))
+	}
+	if st.MultilineFraction > 0 {
+		optFuncs = append(optFuncs, logmaker.WithMultiline(logmaker.MultilineOpts{Fraction: st.MultilineFraction, Frames: 8}))
+	}
+	return optFuncs
+}
+
+// Phase is a stretch of the scenario with its own rates.
+type Phase struct {
+	Name     string        `yaml:"name" json:"name"`
+	Duration time.Duration `yaml:"duration" json:"duration"`
+	// Multiplier scales the rate of every stream. Unset means 1.
+	Multiplier *float64 `yaml:"multiplier" json:"multiplier,omitempty"`
+	// Rates sets the rate of individual streams, by name, overriding
+	// Multiplier.
+	Rates map[string]int64 `yaml:"rates" json:"rates,omitempty"`
+	// Transition is TransitionStep or TransitionLinear. Empty means step.
+	Transition string `yaml:"transition" json:"transition,omitempty"`
+}
+
+// Parse reads a scenario from YAML or JSON and validates it.
+func Parse(data []byte) (*Scenario, error) {
+	var sc Scenario
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&sc); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("scenario is empty")
+		}
+		return nil, fmt.Errorf("could not parse scenario: %w", err)
+	}
+	if err := sc.Validate(); err != nil {
+		return nil, err
+	}
+	return &sc, nil
+}
+
+// Load reads a scenario from r, as Parse does.
+func Load(r io.Reader) (*Scenario, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Validate reports the first problem that would stop the scenario from
+// running.
+func (sc *Scenario) Validate() error {
+	if len(sc.Streams) == 0 {
+		return errors.New("scenario has no streams")
+	}
+	if len(sc.Phases) == 0 {
+		return errors.New("scenario has no phases")
+	}
+	names := map[string]bool{}
+	for i, st := range sc.Streams {
+		if st.Name == "" {
+			return fmt.Errorf("stream %d has no name", i+1)
+		}
+		if names[st.Name] {
+			return fmt.Errorf("stream name %q is used more than once", st.Name)
+		}
+		names[st.Name] = true
+		if st.Rate < 0 {
+			return fmt.Errorf("stream %q has a negative rate", st.Name)
+		}
+		if st.Format != "" && !slices.Contains(logmaker.Formats, st.Format) {
+			return fmt.Errorf("stream %q has unknown format %q, expected one of %s", st.Name, st.Format, strings.Join(logmaker.Formats, ", "))
+		}
+		if st.LevelMix != "" {
+			if _, err := logmaker.ParseLevelMix(st.LevelMix); err != nil {
+				return fmt.Errorf("stream %q: %w", st.Name, err)
+			}
+		}
+		fields, err := logmaker.ParseFieldSpecs(st.Fields)
+		if err != nil {
+			return fmt.Errorf("stream %q: %w", st.Name, err)
+		}
+		if err := logmaker.CheckMessage(st.Message, fields); err != nil {
+			return fmt.Errorf("stream %q: %w", st.Name, err)
+		}
+		if st.MultilineFraction < 0 || st.MultilineFraction > 1 {
+			return fmt.Errorf("stream %q has a multiline fraction outside 0 to 1", st.Name)
+		}
+	}
+	for i, ph := range sc.Phases {
+		name := ph.Name
+		if name == "" {
+			name = fmt.Sprint(i + 1)
+		}
+		if ph.Duration <= 0 {
+			return fmt.Errorf("phase %s needs a positive duration", name)
+		}
+		if ph.Multiplier != nil && *ph.Multiplier < 0 {
+			return fmt.Errorf("phase %s has a negative multiplier", name)
+		}
+		switch ph.Transition {
+		case "", TransitionStep, TransitionLinear:
+		default:
+			return fmt.Errorf("phase %s has unknown transition %q, expected %s or %s", name, ph.Transition, TransitionStep, TransitionLinear)
+		}
+		for stream, rate := range ph.Rates {
+			if !names[stream] {
+				return fmt.Errorf("phase %s sets the rate of unknown stream %q", name, stream)
+			}
+			if rate < 0 {
+				return fmt.Errorf("phase %s sets a negative rate for stream %q", name, stream)
+			}
+		}
+	}
+	return nil
+}
+
+// Split divides the scenario into n scenarios whose stream and phase rates
+// add up to the original's, for running across n hosts. Rates that don't
+// divide evenly give the remainder to the first parts.
+func (sc *Scenario) Split(n int) []*Scenario {
+	parts := make([]*Scenario, n)
+	for i := range parts {
+		part := sc.clone()
+		for j := range part.Streams {
+			part.Streams[j].Rate = share(sc.Streams[j].Rate, n, i)
+		}
+		for _, ph := range part.Phases {
+			for name, rate := range ph.Rates {
+				ph.Rates[name] = share(rate, n, i)
+			}
+		}
+		parts[i] = part
+	}
+	return parts
+}
+
+// share is part i of rate divided into n.
+func share(rate int64, n, i int) int64 {
+	s := rate / int64(n)
+	if int64(i) < rate%int64(n) {
+		s++
+	}
+	return s
+}
+
+// Duration is the total length of all phases.
+func (sc *Scenario) Duration() time.Duration {
+	var d time.Duration
+	for _, ph := range sc.Phases {
+		d += ph.Duration
+	}
+	return d
+}
+
+// phaseRate is the rate a stream settles at in a phase.
+func (sc *Scenario) phaseRate(phase int, st Stream) float64 {
+	ph := sc.Phases[phase]
+	if rate, ok := ph.Rates[st.Name]; ok {
+		return float64(rate)
+	}
+	if ph.Multiplier != nil {
+		return float64(st.Rate) * *ph.Multiplier
+	}
+	return float64(st.Rate)
+}
+
+// RateAt is the rate of stream st after elapsed time into phase, taking the
+// phase's transition into account.
+func (sc *Scenario) RateAt(phase int, elapsed time.Duration, st Stream) int64 {
+	target := sc.phaseRate(phase, st)
+	ph := sc.Phases[phase]
+	if ph.Transition != TransitionLinear {
+		return int64(target)
+	}
+	var from float64
+	if phase > 0 {
+		from = sc.phaseRate(phase-1, st)
+	}
+	progress := min(float64(elapsed)/float64(ph.Duration), 1)
+	return int64(from + (target-from)*progress)
+}
@@ -0,0 +1,223 @@
+package scenario
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/metrics"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const checkoutScenario = `
+name: checkout
+streams:
+  - name: web
+    format: plain
+    rate: 200
+    level_mix: info=90,error=10
+    fields: tenant:5
+  - name: payments
+    format: ecs
+    rate: 100
+phases:
+  - name: warmup
+    duration: 200ms
+    transition: linear
+  - name: spike
+    duration: 200ms
+    multiplier: 3
+    rates:
+      payments: 0
+`
+
+func TestParseValidatesScenario(t *testing.T) {
+	sc, err := Parse([]byte(checkoutScenario))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.Duration() != 400*time.Millisecond {
+		t.Errorf("expected a 400ms scenario, got %s", sc.Duration())
+	}
+	bad := map[string]string{
+		"no streams":       "phases: [{name: a, duration: 1s}]",
+		"no phases":        "streams: [{name: a, rate: 1}]",
+		"duplicate stream": "streams: [{name: a}, {name: a}]\nphases: [{duration: 1s}]",
+		"unknown format":   "streams: [{name: a, format: xml}]\nphases: [{duration: 1s}]",
+		"unknown stream":   "streams: [{name: a}]\nphases: [{duration: 1s, rates: {b: 1}}]",
+		"no duration":      "streams: [{name: a}]\nphases: [{name: p}]",
+		"unknown field":    "streams: [{name: a, speed: 1}]\nphases: [{duration: 1s}]",
+		"bad transition":   "streams: [{name: a}]\nphases: [{duration: 1s, transition: wobble}]",
+		"unknown template": "streams: [{name: a, fields: 'pod:3', message: '{pod} {node}'}]\nphases: [{duration: 1s}]",
+	}
+	for name, doc := range bad {
+		if _, err := Parse([]byte(doc)); err == nil {
+			t.Errorf("%s: expected scenario to be rejected", name)
+		}
+	}
+}
+
+func TestParseAcceptsJSON(t *testing.T) {
+	sc, err := Parse([]byte(`{"name":"j","streams":[{"name":"a","rate":5}],"phases":[{"name":"p","duration":"1s"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.Phases[0].Duration != time.Second {
+		t.Errorf("expected a 1s phase, got %s", sc.Phases[0].Duration)
+	}
+}
+
+func TestRateAtFollowsTransitions(t *testing.T) {
+	sc, err := Parse([]byte(checkoutScenario))
+	if err != nil {
+		t.Fatal(err)
+	}
+	web, payments := sc.Streams[0], sc.Streams[1]
+	if r := sc.RateAt(0, 100*time.Millisecond, web); r != 100 {
+		t.Errorf("expected warmup to be halfway to 200, got %d", r)
+	}
+	if r := sc.RateAt(1, 0, web); r != 600 {
+		t.Errorf("expected spike to triple the rate, got %d", r)
+	}
+	if r := sc.RateAt(1, 0, payments); r != 0 {
+		t.Errorf("expected spike to silence payments, got %d", r)
+	}
+}
+
+func TestRunnerWritesEveryStream(t *testing.T) {
+	dir := t.TempDir()
+	sc, err := Parse([]byte(checkoutScenario))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc.Streams[1].Sink = filepath.Join(dir, "payments.log")
+	rn := NewRunner(sc, WithDefaultSink(filepath.Join(dir, "web.log")), WithRampInterval(20*time.Millisecond))
+	res, err := rn.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Streams["web"].LogCount == 0 || res.Streams["payments"].LogCount == 0 {
+		t.Errorf("expected both streams to write, got %+v", res.Streams)
+	}
+	// payments only writes during the warmup ramp, web through both phases
+	if res.Streams["web"].LogCount <= res.Streams["payments"].LogCount {
+		t.Errorf("expected web to outpace payments, got %+v", res.Streams)
+	}
+	web, err := os.ReadFile(filepath.Join(dir, "web.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(web), "tenant=tenant-") {
+		t.Errorf("expected plain lines with fields, got:\n%s", web)
+	}
+	payments, err := os.ReadFile(filepath.Join(dir, "payments.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(payments), `"ecs.version"`) {
+		t.Errorf("expected ecs lines, got:\n%s", payments)
+	}
+}
+
+func TestSlowStreamsLeaveTheCPUIdle(t *testing.T) {
+	dir := t.TempDir()
+	sc, err := Parse([]byte(`
+name: quiet
+streams:
+  - {name: a, rate: 10}
+  - {name: b, rate: 10}
+  - {name: c, rate: 10}
+  - {name: d, rate: 10}
+phases:
+  - {name: steady, duration: 1s}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cpu := []metrics.Sample{{Name: "/cpu/classes/user:cpu-seconds"}}
+	// the runtime only accounts CPU time at the end of a GC
+	runtime.GC()
+	metrics.Read(cpu)
+	before := cpu[0].Value.Float64()
+	if _, err := NewRunner(sc, WithDefaultSink(filepath.Join(dir, "quiet.log"))).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	runtime.GC()
+	metrics.Read(cpu)
+	// every stream's LogMaker would keep a core busy if it spun between events
+	if used := cpu[0].Value.Float64() - before; used > 0.5 {
+		t.Errorf("expected four slow streams to be mostly idle, used %.2fs of CPU", used)
+	}
+}
+
+func TestThatPresetsAreBuiltIn(t *testing.T) {
+	expected := []string{
+		"auth-failures-bruteforce",
+		"batch-job-burst",
+		"chatty-debug-service",
+		"java-microservice-errors",
+		"k8s-audit-log",
+		"nginx-web-tier",
+	}
+	if names := PresetNames(); !slices.Equal(names, expected) {
+		t.Fatalf("expected presets %v, got %v", expected, names)
+	}
+	for _, sc := range Presets() {
+		if sc.Description == "" {
+			t.Errorf("expected preset %s to have a description", sc.Name)
+		}
+		for _, st := range sc.Streams {
+			if st.Format == "" || st.LevelMix == "" || st.Fields == "" || st.Message == "" {
+				t.Errorf("expected preset %s stream %s to set format, level mix, fields and message", sc.Name, st.Name)
+			}
+		}
+	}
+	if Preset("no-such-preset") != nil {
+		t.Error("expected an unknown preset to be nil")
+	}
+	// presets are copies, so changing one doesn't change the library
+	Preset("k8s-audit-log").Streams[0].Rate = 1
+	if Preset("k8s-audit-log").Streams[0].Rate == 1 {
+		t.Error("expected changes to a preset not to leak into the library")
+	}
+}
+
+func TestSplitSharesRatesEvenly(t *testing.T) {
+	sc, err := Parse([]byte(checkoutScenario))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := sc.Split(3)
+	if len(parts) != 3 {
+		t.Fatalf("expected 3 parts, got %d", len(parts))
+	}
+	for i, st := range sc.Streams {
+		var sum int64
+		for _, part := range parts {
+			sum += part.Streams[i].Rate
+		}
+		if sum != st.Rate {
+			t.Errorf("expected the parts of stream %s to add up to %d, got %d", st.Name, st.Rate, sum)
+		}
+	}
+	for i, ph := range sc.Phases {
+		for name, rate := range ph.Rates {
+			var sum int64
+			for _, part := range parts {
+				sum += part.Phases[i].Rates[name]
+			}
+			if sum != rate {
+				t.Errorf("expected the parts of phase %s to add up to %d for %s, got %d", ph.Name, rate, name, sum)
+			}
+		}
+	}
+	if rates := []int64{parts[0].Streams[0].Rate, parts[1].Streams[0].Rate, parts[2].Streams[0].Rate}; !slices.Equal(rates, []int64{67, 67, 66}) {
+		t.Errorf("expected the remainder to go to the first parts, got %v", rates)
+	}
+	if sc.Streams[0].Rate != 200 {
+		t.Error("expected the original scenario to be left as it was")
+	}
+}